@@ -1,16 +1,30 @@
 package main
 
 import (
+	"bytes"
+	"crypto/rand"
+	"expvar"
 	"fmt"
 	"log"
 	"net/http"
-	"regexp"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+	_ "time/tzdata" // the docker image contains no time zone database, which is required for German dates
 
 	"github.com/seiferma/docker_mediathek2rss/internal"
-	"github.com/seiferma/docker_mediathek2rss/internal/ardapi"
 	"github.com/seiferma/docker_mediathek2rss/internal/ardfeed"
+	"github.com/seiferma/docker_mediathek2rss/internal/artefeed"
+	"github.com/seiferma/docker_mediathek2rss/internal/audiothekfeed"
+	"github.com/seiferma/docker_mediathek2rss/internal/funkfeed"
+	"github.com/seiferma/docker_mediathek2rss/internal/manifest"
+	"github.com/seiferma/docker_mediathek2rss/internal/mediaprobe"
+	"github.com/seiferma/docker_mediathek2rss/internal/nexxapi"
+	"github.com/seiferma/docker_mediathek2rss/internal/provider"
+	"github.com/seiferma/docker_mediathek2rss/internal/rssfeed"
+	"github.com/seiferma/docker_mediathek2rss/internal/transcoder"
 	"github.com/seiferma/docker_mediathek2rss/internal/zdfapi"
 	"github.com/seiferma/docker_mediathek2rss/internal/zdffeed"
 )
@@ -19,108 +33,207 @@ import (
 const listenAddress = ":8080"
 const cacheDuration = 5 * time.Minute
 const maxEpisodes = 50
-const ardShowByIDPathPrefix = "/ard/show/"
-const zdfShowByPathPrefix = "/zdf/show/byPath/"
+const validatePathPrefix = "/validate/"
+const debugVarsPath = "/debug/vars"
+const defaultTranscodeCacheSizeInMB = 2048
+const probeCacheDuration = 24 * time.Hour
+
+// Environment variables for configuring the optional audio extraction
+const envFfmpegPath = "FFMPEG_PATH"
+const envBaseURL = "BASE_URL"
+const envTranscodeCacheDirectory = "TRANSCODE_CACHE_DIR"
+const envTranscodeCacheSizeInMB = "TRANSCODE_CACHE_SIZE_MB"
+const envTranscodeSecret = "TRANSCODE_SECRET"
+
+// Environment variable for configuring the suffixes of higher resolution ZDF streams
+const envZdfURLSuffixes = "ZDF_URL_SUFFIXES"
+
+// Environment variable for configuring the validation of feeds before caching them
+const envFeedValidation = "FEED_VALIDATION"
+
+// Platforms on the infrastructure of the ZDF, whose shows are served at /{platform}/show/byPath/{showPath}
+var zdfPlatforms = []zdfapi.Platform{zdfapi.PlatformZDF, zdfapi.Platform3sat, zdfapi.PlatformPhoenix, zdfapi.PlatformKiKA}
 
 // Global state
 var feedCache internal.Cache
+var mediaServices internal.MediaServices
+var zdfVariantProber *zdffeed.VariantProber
+var feedRegistry = provider.CreateRegistry(&feedCache, &mediaServices)
 
 func main() {
 	feedCache = internal.CreateCache(cacheDuration)
-	http.HandleFunc(ardShowByIDPathPrefix, ardShowByIDServer)
-	http.HandleFunc(zdfShowByPathPrefix, zdfShowByPathServer)
+	mediaServices.Prober = mediaprobe.CreateProber(probeCacheDuration)
+	mediaServices.ManifestReader = manifest.CreateReader(probeCacheDuration)
+	initZdfVariantProber()
+	initFeedValidation()
+	mux := createServeMux()
 	log.Printf("Starting HTTP server on %v", listenAddress)
-	http.ListenAndServe(listenAddress, nil)
+	http.ListenAndServe(listenAddress, mux)
+}
+
+// createServeMux creates the mux that the web service serves, which holds the feeds, the transcoder and the metrics
+// at /debug/vars.
+func createServeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	initTranscoder(mux)
+	registerFeedHandlers(mux)
+	mux.Handle(debugVarsPath, expvar.Handler())
+	return mux
+}
+
+// registerFeedHandlers mounts the routes of all providers. Adding a broadcaster only requires registering its provider.
+func registerFeedHandlers(mux *http.ServeMux) {
+	funkAPI := nexxapi.CreateFunkAPI()
+	feedRegistry.Register(mux, ardfeed.CreateProvider(maxEpisodes, funkAPI))
+	for _, platform := range zdfPlatforms {
+		feedRegistry.Register(mux, zdffeed.CreatePlatformProvider(platform, maxEpisodes, zdfVariantProber))
+	}
+	feedRegistry.Register(mux, audiothekfeed.CreateProvider(maxEpisodes))
+	feedRegistry.Register(mux, artefeed.CreateProvider(maxEpisodes))
+	feedRegistry.Register(mux, funkfeed.CreateProvider(maxEpisodes, funkAPI))
+	mux.HandleFunc(validatePathPrefix, func(w http.ResponseWriter, r *http.Request) {
+		validateServer(mux, w, r)
+	})
 }
 
-func ardShowByIDServer(w http.ResponseWriter, r *http.Request) {
-	// extract show id from request
-	urlSegments := strings.Split(r.URL.Path, "/")
-	if len(urlSegments) < 1 {
+// validateServer answers with a validation report of the feed that the given mux yields for the path following
+// /validate, e.g. /validate/zdf/show/byPath/comedy/zdf-magazin-royale?audio=true.
+func validateServer(mux *http.ServeMux, w http.ResponseWriter, r *http.Request) {
+	feedRequest := r.Clone(r.Context())
+	feedRequest.URL.Path = strings.TrimPrefix(r.URL.Path, strings.TrimSuffix(validatePathPrefix, "/"))
+	feedRequest.RequestURI = feedRequest.URL.RequestURI()
+	handler, pattern := mux.Handler(feedRequest)
+	if !feedRegistry.IsFeedPattern(pattern) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "The given path is no feed of this web service.")
+		log.Printf("Received a validation request for unknown path %v.", feedRequest.URL.Path)
 		return
 	}
-	showID := urlSegments[len(urlSegments)-1]
-	if !isValidArdShowID(showID) {
+	if format := feedRequest.URL.Query().Get("format"); format != "" && format != internal.FormatRSS {
 		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, "The given show ID is not valid.")
-		log.Print("Received a request for invalid show ID.")
+		fmt.Fprint(w, "Only RSS feeds can be validated.")
+		log.Printf("Received a validation request for the %v feed %v.", format, feedRequest.URL.Path)
 		return
 	}
 
-	// extract request parameters
-	requestParameters := internal.CreateRequestParametersFromURL(r.URL)
-	log.Printf("Received a request for show ID %v with parameters %v.", showID, requestParameters)
-
-	// create ARD API
-	ardAPI := ardapi.CreateArdAPI(maxEpisodes)
+	response := createResponseBuffer()
+	handler.ServeHTTP(response, feedRequest)
+	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
+	if response.status != http.StatusOK {
+		w.WriteHeader(response.status)
+		fmt.Fprintf(w, "The feed could not be created:\n%v", response.body.String())
+		return
+	}
 
-	// create RSS feed
-	fnCreateRss := func(showID string, parameters internal.RequestParameters) (string, error) {
-		return ardfeed.CreateArdRssFeed(showID, parameters, &ardAPI)
+	report := rssfeed.Validate(response.body.Bytes())
+	errors := len(report.Errors())
+	if report.IsValid() {
+		fmt.Fprintf(w, "The feed is valid with %v warnings.\n", len(report.Issues))
+	} else {
+		fmt.Fprintf(w, "The feed is invalid with %v errors and %v warnings.\n", errors, len(report.Issues)-errors)
 	}
-	rssFeedString, error := internal.CreateRssFeedCached(showID, requestParameters, &feedCache, fnCreateRss)
+	if len(report.Issues) > 0 {
+		fmt.Fprintf(w, "\n%v\n", report)
+	}
+	log.Printf("Successfully returning validation report for %v.", feedRequest.URL.Path)
+}
+
+// responseBuffer keeps a response in memory, so it can be processed before answering a request.
+type responseBuffer struct {
+	header        http.Header
+	status        int
+	statusWritten bool
+	body          bytes.Buffer
+}
 
-	// report an error
-	if error != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprint(w, error)
-		log.Printf("There was an error while processing request for %v: %v", showID, error)
+func createResponseBuffer() *responseBuffer {
+	return &responseBuffer{
+		header: http.Header{},
+		status: http.StatusOK,
 	}
+}
 
-	// return produced feed
-	w.Header().Add("Content-Type", "application/rss+xml")
-	fmt.Fprint(w, rssFeedString)
-	log.Printf("Successfully returing RSS feed for %v.", showID)
+func (buffer *responseBuffer) Header() http.Header {
+	return buffer.header
 }
 
-func isValidArdShowID(showID string) bool {
-	idRegex, _ := regexp.Compile("^[a-zA-Z0-9]+$")
-	showIDBytes := []byte(showID)
-	return idRegex.Match(showIDBytes)
+func (buffer *responseBuffer) Write(data []byte) (int, error) {
+	return buffer.body.Write(data)
 }
 
-func zdfShowByPathServer(w http.ResponseWriter, r *http.Request) {
-	// extract show path from URL
-	showPath := strings.Replace(r.URL.Path, zdfShowByPathPrefix, "", -1)
-	if !isValidZdfPath(showPath) {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, "The given show path is not valid.")
-		log.Print("Received a request for invalid show path.")
-		return
+// WriteHeader keeps the first status like a real response, which cannot change the status after sending it.
+func (buffer *responseBuffer) WriteHeader(status int) {
+	if !buffer.statusWritten {
+		buffer.status = status
+		buffer.statusWritten = true
 	}
+}
 
-	// extract request parameters
-	requestParameters := internal.CreateRequestParametersFromURL(r.URL)
-	log.Printf("Received a request for show path %v with parameters %v.", showPath, requestParameters)
+func initFeedValidation() {
+	switch mode := os.Getenv(envFeedValidation); mode {
+	case "":
+	case internal.ValidationOff, internal.ValidationWarn, internal.ValidationStrict:
+		feedRegistry.ValidationMode = mode
+	default:
+		log.Printf("Ignoring unknown feed validation mode %v of %v.", mode, envFeedValidation)
+	}
+}
 
-	// create ARD API
-	zdfAPI, err := zdfapi.CreateZDFApi(maxEpisodes)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprint(w, err)
-		log.Printf("There was an error while initializing the ZDF API: %v", err)
+func initTranscoder(mux *http.ServeMux) {
+	ffmpegPath := os.Getenv(envFfmpegPath)
+	if ffmpegPath == "" {
+		return
+	}
+	baseURL := os.Getenv(envBaseURL)
+	if baseURL == "" {
+		log.Printf("Audio extraction is disabled because %v is not set.", envBaseURL)
+		return
 	}
 
-	// create RSS feed
-	fnCreateRss := func(showPath string, parameters internal.RequestParameters) (string, error) {
-		return zdffeed.CreateZdfRssFeed(showPath, parameters, &zdfAPI)
+	cacheDirectory := os.Getenv(envTranscodeCacheDirectory)
+	if cacheDirectory == "" {
+		cacheDirectory = filepath.Join(os.TempDir(), "mediathek2rss")
+	}
+	cacheSizeInMB := defaultTranscodeCacheSizeInMB
+	if value, err := strconv.Atoi(os.Getenv(envTranscodeCacheSizeInMB)); err == nil && value > 0 {
+		cacheSizeInMB = value
+	}
+	secret := []byte(os.Getenv(envTranscodeSecret))
+	if len(secret) == 0 {
+		log.Printf("%v is not set, so audio URLs will become invalid when the web service restarts.", envTranscodeSecret)
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Printf("Audio extraction is disabled because no random secret could be created: %v", err)
+			return
+		}
 	}
-	rssFeedString, err := internal.CreateRssFeedCached(showPath, requestParameters, &feedCache, fnCreateRss)
 
-	// report an error
+	mediaTranscoder, err := transcoder.CreateTranscoder(transcoder.Config{
+		FfmpegPath:     ffmpegPath,
+		CacheDirectory: cacheDirectory,
+		MaxCacheBytes:  int64(cacheSizeInMB) * 1024 * 1024,
+		BaseURL:        baseURL,
+		Secret:         secret,
+	})
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprint(w, err)
-		log.Printf("There was an error while processing request for %v: %v", showPath, err)
+		log.Printf("Audio extraction is disabled because the transcoder could not be initialized: %v", err)
+		return
 	}
-
-	// return produced feed
-	w.Header().Add("Content-Type", "application/rss+xml")
-	fmt.Fprint(w, rssFeedString)
-	log.Printf("Successfully returing RSS feed for %v.", showPath)
+	mediaServices.AudioExtractor = mediaTranscoder
+	mediaServices.VideoRemuxer = mediaTranscoder
+	mux.Handle(transcoder.PathPrefix, mediaTranscoder)
+	log.Printf("Audio extraction and remuxing of adaptive streams via %v is enabled.", ffmpegPath)
 }
 
-func isValidZdfPath(path string) bool {
-	regex := regexp.MustCompile("^([a-zA-Z0-9-]+/)*[a-zA-Z0-9-]+$")
-	return regex.Match([]byte(path))
+func initZdfVariantProber() {
+	suffixes := zdffeed.DefaultURLSuffixes
+	if value := os.Getenv(envZdfURLSuffixes); value != "" {
+		configuredSuffixes, err := zdffeed.ParseURLSuffixes(value)
+		if err != nil {
+			log.Printf("Using the default ZDF URL suffixes because %v is invalid: %v", envZdfURLSuffixes, err)
+		} else {
+			suffixes = configuredSuffixes
+		}
+	}
+	zdfVariantProber = zdffeed.CreateVariantProber(suffixes, probeCacheDuration)
 }
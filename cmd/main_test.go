@@ -1,19 +1,88 @@
 package main
 
-import "testing"
-
-func TestIsValidShowID(t *testing.T) {
-	assertIsValidArdShowID(t, "", false)
-	assertIsValidArdShowID(t, "a", true)
-	assertIsValidArdShowID(t, "a1", true)
-	assertIsValidArdShowID(t, "b2H", true)
-	assertIsValidArdShowID(t, "b2H?", false)
-	assertIsValidArdShowID(t, "a\\q", false)
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/seiferma/docker_mediathek2rss/internal/transcoder"
+)
+
+func TestValidateServer(t *testing.T) {
+	mux := http.NewServeMux()
+	registerFeedHandlers(mux)
+
+	recorder := httptest.NewRecorder()
+	validateServer(mux, recorder, httptest.NewRequest("GET", validatePathPrefix+"unknown/feed", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("Expected status %v but got %v.", http.StatusNotFound, recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	validateServer(mux, recorder, httptest.NewRequest("GET", validatePathPrefix+"zdf/show/byPath/comedy/zdf-magazin-royale?witdh=720", nil))
+	if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "witdh: unknown parameter") {
+		t.Fatalf("Expected the error of the feed to be forwarded but got %v: %v", recorder.Code, recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	validateServer(mux, recorder, httptest.NewRequest("GET", validatePathPrefix+"zdf/show/byPath/comedy/zdf-magazin-royale?format=json", nil))
+	if recorder.Code != http.StatusBadRequest || recorder.Body.String() != "Only RSS feeds can be validated." {
+		t.Fatalf("Expected other formats to be rejected but got %v: %v", recorder.Code, recorder.Body.String())
+	}
 }
 
-func assertIsValidArdShowID(t *testing.T, idToTest string, expectedResult bool) {
-	actual := isValidArdShowID(idToTest)
-	if actual != expectedResult {
-		t.Fatalf("Expected the validation of %v to return %v but got %v.", idToTest, expectedResult, actual)
+func TestServeMuxServesTranscoderAndMetrics(t *testing.T) {
+	cacheDirectory, err := ioutil.TempDir("", "transcoder")
+	if err != nil {
+		t.Fatal(err)
 	}
+	defer os.RemoveAll(cacheDirectory)
+	setEnv(t, envFfmpegPath, "ffmpeg")
+	setEnv(t, envBaseURL, "https://mediathek2rss.example/")
+	setEnv(t, envTranscodeCacheDirectory, cacheDirectory)
+	setEnv(t, envTranscodeSecret, "secret")
+	defer func() {
+		mediaServices.AudioExtractor = nil
+		mediaServices.VideoRemuxer = nil
+	}()
+	mux := createServeMux()
+
+	// an invalid signature is rejected by the transcoder, whereas unknown paths yield 404
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest("GET", transcoder.PathPrefix+"foo/bar.m4a", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("Expected the transcoder to answer with status %v but got %v.", http.StatusBadRequest, recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest("GET", debugVarsPath, nil))
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "missingMediaEpisodes") {
+		t.Fatalf("Expected the metrics but got %v: %v", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestResponseBufferKeepsFirstStatus(t *testing.T) {
+	response := createResponseBuffer()
+	response.WriteHeader(http.StatusInternalServerError)
+	response.WriteHeader(http.StatusOK)
+	response.Write([]byte("error"))
+	if response.status != http.StatusInternalServerError || response.body.String() != "error" {
+		t.Fatalf("Expected the first status and the body to be kept but got %v: %v", response.status, response.body.String())
+	}
+}
+
+// setEnv sets an environment variable until the end of the test.
+func setEnv(t *testing.T, name, value string) {
+	previousValue, wasSet := os.LookupEnv(name)
+	os.Setenv(name, value)
+	t.Cleanup(func() {
+		if wasSet {
+			os.Setenv(name, previousValue)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
 }
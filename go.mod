@@ -1,4 +1,4 @@
 module github.com/seiferma/docker_mediathek2rss
 
-go 1.14
+go 1.15
 
@@ -1,32 +1,27 @@
 package ardapi
 
 import (
-	"crypto/md5"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
-	"math/rand"
 	"net/http"
-	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
-)
 
-const funkDomainId = 741
-const funkDomainHash = "CA4SDGOBTRM421IRNO0"
+	"github.com/seiferma/docker_mediathek2rss/internal/nexxapi"
+)
 
 // ArdAPI gives access to various operations of the ARD Mediathek API.
 // Its main purpose is to hold configuration parameters and provide them to
 // the API functions.
 type ArdAPI struct {
-	maxEpisodes   int
-	fnGetRequest  func(string) ([]byte, error)
-	fnPostRequest func(string, url.Values, map[string]string) ([]byte, error)
+	maxEpisodes  int
+	fnGetRequest func(string) ([]byte, error)
+	funkAPI      *nexxapi.NexxAPI // nil if the streams of funk videos shall not be completed
 }
 
 // ShowImage represents an image DTO from the API.
@@ -52,11 +47,32 @@ type Show struct {
 			LongSynopsis string
 			Images       map[string](ShowImage)
 		}
-		Images        map[string](ShowImage)
+		Images             map[string](ShowImage)
+		PublicationService struct {
+			Name string
+		}
 		BroadcastedOn time.Time
+		AvailableFrom time.Time // zero if the episode is available without start
+		AvailableTo   time.Time // zero if the episode is available without time limit
 		Duration      int
-		ID            string `json:"id"`
+		SeasonNumber  OptionalNumber // zero if the episode is not part of a season
+		EpisodeNumber OptionalNumber // zero if the episode is not numbered
+		ID            string         `json:"id"`
+	}
+}
+
+// OptionalNumber is a number of the API that may be missing. The API gives numbers as JSON numbers or strings.
+// Missing or invalid numbers are zero.
+type OptionalNumber int
+
+// UnmarshalJSON parses a number given as JSON number or string, e.g. 3 or "3".
+func (number *OptionalNumber) UnmarshalJSON(data []byte) error {
+	value, err := strconv.Atoi(strings.Trim(string(data), "\""))
+	if err != nil {
+		value = 0
 	}
+	*number = OptionalNumber(value)
+	return nil
 }
 
 // ShowVideo represents a DTO for a video of a show from the API.
@@ -73,10 +89,13 @@ type ShowVideo struct {
 				MediaArray []struct {
 					MediaStreamArray *[]MediaStreamArray `json:"_mediaStreamArray"`
 				} `json:"_mediaArray"`
+				SubtitleURL string `json:"_subtitleUrl"` // empty if the video has no subtitles
 			}
 		}
-		Image    ShowImage
-		Synopsis string
+		Image                 ShowImage
+		Synopsis              string
+		Geoblocked            bool   // the video is only available in Germany
+		MaturityContentRating string // e.g. NONE or FSK16
 	}
 }
 
@@ -92,27 +111,6 @@ type Streams struct {
 	StreamUrls []string
 }
 
-type NexxInitSessionResponse struct {
-	Result struct {
-		General struct {
-			Cid string
-		}
-	}
-}
-
-type NexxVideoMetadata struct {
-	Result struct {
-		Streamdata struct {
-			CdnType               string
-			CdnShieldHTTPS        string
-			QAccount              string
-			QPrefix               string
-			QLocator              string
-			AzureFileDistribution string
-		}
-	}
-}
-
 // Custom logic to unmarshal a stream array from JSON.
 // The function creates an array of stream URLs no matter if JSON contains an array of stream URLs or just one single URL.
 func (s *Streams) UnmarshalJSON(data []byte) error {
@@ -133,24 +131,48 @@ func (s *Streams) UnmarshalJSON(data []byte) error {
 
 // CreateArdAPI creates a new API instance taking configuration values to be considered when working with the API.
 // The maxEpisodes parameter defines how many episodes of a show shall be received at most.
-func CreateArdAPI(maxEpisodes int) ArdAPI {
-	return CreateArdAPIWithGetFunc(maxEpisodes, doGetRequest, doPostRequest)
+// The funkAPI parameter provides the streams of funk videos that the ARD API lists without streams.
+func CreateArdAPI(maxEpisodes int, funkAPI *nexxapi.NexxAPI) ArdAPI {
+	return CreateArdAPIWithGetFunc(maxEpisodes, doGetRequest, funkAPI)
 }
 
 // CreateArdAPIWithGetFunc creates a new API instance taking configuration values to be considered when working with the API.
 // The maxEpisodes parameter defines how many episodes of a show shall be received at most.
 // The fnGetRequest parameter provides a function that carries out a get request and provides the body as byte array.
-func CreateArdAPIWithGetFunc(maxEpisodes int, fnGetRequest func(string) ([]byte, error), fnPostRequest func(string, url.Values, map[string]string) ([]byte, error)) ArdAPI {
+// The funkAPI parameter provides the streams of funk videos that the ARD API lists without streams. It may be nil.
+func CreateArdAPIWithGetFunc(maxEpisodes int, fnGetRequest func(string) ([]byte, error), funkAPI *nexxapi.NexxAPI) ArdAPI {
 	return ArdAPI{
-		maxEpisodes:   maxEpisodes,
-		fnGetRequest:  fnGetRequest,
-		fnPostRequest: fnPostRequest,
+		maxEpisodes:  maxEpisodes,
+		fnGetRequest: fnGetRequest,
+		funkAPI:      funkAPI,
 	}
 }
 
+// ShowOptions select alternative versions of the episodes of a show.
+// Episodes without the requested version are provided in their normal version.
+type ShowOptions struct {
+	WithAudioDescription bool
+	WithOriginalVersion  bool
+	SeasonNumber         int // zero for the episodes of all seasons
+}
+
 // GetShow retrieves a show from the API by the given showID.
 func (api *ArdAPI) GetShow(showID string) (result Show, err error) {
+	return api.GetShowWithOptions(showID, ShowOptions{})
+}
+
+// GetShowWithOptions retrieves a show from the API by the given showID with the versions of the episodes selected by the options.
+func (api *ArdAPI) GetShowWithOptions(showID string, options ShowOptions) (result Show, err error) {
 	showURL := fmt.Sprintf("https://api.ardmediathek.de/page-gateway/widgets/ard/asset/%v?pageNumber=0&pageSize=%v", showID, api.maxEpisodes)
+	if options.WithAudioDescription {
+		showURL += "&withAudiodescription=true"
+	}
+	if options.WithOriginalVersion {
+		showURL += "&withOriginalversion=true"
+	}
+	if options.SeasonNumber > 0 {
+		showURL += fmt.Sprintf("&seasoned=true&seasonNumber=%v", options.SeasonNumber)
+	}
 	var body []byte
 	body, err = api.fnGetRequest(showURL)
 	if err != nil {
@@ -169,6 +191,35 @@ func (api *ArdAPI) GetShow(showID string) (result Show, err error) {
 	return
 }
 
+// seasonAndEpisodeRegex matches the season and episode numbers that the ARD appends to the titles of episodes,
+// e.g. "(S02/E05)".
+var seasonAndEpisodeRegex = regexp.MustCompile("\\(S([0-9]+)/E([0-9]+)\\)")
+
+// GetSeasonAndEpisode yields the season and episode numbers of an episode. The fields of the API are preferred and the
+// numbers in the title are the fallback for fields that are missing. Zero values mean that the numbers are unknown.
+func GetSeasonAndEpisode(seasonNumber, episodeNumber OptionalNumber, title string) (season, episode int) {
+	season, episode = ParseSeasonAndEpisode(title)
+	if seasonNumber > 0 {
+		season = int(seasonNumber)
+	}
+	if episodeNumber > 0 {
+		episode = int(episodeNumber)
+	}
+	return
+}
+
+// ParseSeasonAndEpisode yields the season and episode numbers contained in the title of an episode.
+// Zero values mean that the title contains no numbers.
+func ParseSeasonAndEpisode(title string) (season, episode int) {
+	matches := seasonAndEpisodeRegex.FindStringSubmatch(title)
+	if matches == nil {
+		return
+	}
+	season, _ = strconv.Atoi(matches[1])
+	episode, _ = strconv.Atoi(matches[2])
+	return
+}
+
 // GetVideoByURL retrieves a video from the given API URL.
 func (api *ArdAPI) GetVideoByURL(videoURL string) (result ShowVideo, err error) {
 	var body []byte
@@ -182,7 +233,7 @@ func (api *ArdAPI) GetVideoByURL(videoURL string) (result ShowVideo, err error)
 		return
 	}
 
-	if result.Tracking.AtiCustomVars.Channel == "funk" {
+	if result.Tracking.AtiCustomVars.Channel == "funk" && api.funkAPI != nil {
 		re := regexp.MustCompile("video/([0-9]+)$")
 		match := re.FindStringSubmatch(result.Tracking.AtiCustomVars.MetadataId)
 		if match == nil {
@@ -206,48 +257,26 @@ func (api *ArdAPI) replaceMediaStreamArrayUsingFunkVideoId(id string, showVideo
 		return
 	}
 
-	// get cid, which is required for getting metadata
-	cid, err := api.initializeNexxSession()
+	// get metadata about video, which contains the files in all resolutions
+	video, err := api.funkAPI.GetVideo(id)
 	if err != nil {
 		return
 	}
-
-	// get metadata about video
-	videoMetadata, err := api.getNexxVideoMetadata(id, cid)
-	if err != nil {
-		return
-	}
-
-	// video url (prefix of all is result.streamdata)
-	// https://[cdnShieldHTTPS]/[qAccount]/files/[qPrefix]/[qLocator]/[azureFileDistribution].mp4
-	// example of azureFileDistribution: (3001:1280x720:2-w7FWPztXm9hnZpbjMcKq,4152:1920x1080:1-Nf3kJwdpTrgHGLY2jtPv)
-
-	// parse file distribution data
-	re := regexp.MustCompile("[0-9]+:([0-9]+)x([0-9]+):([^:,]+)")
-	matches := re.FindAllStringSubmatch(videoMetadata.Result.Streamdata.AzureFileDistribution, -1)
-	if matches == nil {
+	streams := video.StreamData.GetStreams()
+	if len(streams) == 0 {
 		return
 	}
 
 	// build new media stream array
-	newMediaStreamArray := make([]MediaStreamArray, len(matches))
-	for i, match := range matches {
-		width, _ := strconv.Atoi(match[1])
-		height, _ := strconv.Atoi(match[2])
-		selector := match[3]
-		videoUrl := fmt.Sprintf("https://%v%v/files/%v/%v/%v.mp4",
-			videoMetadata.Result.Streamdata.CdnShieldHTTPS,
-			videoMetadata.Result.Streamdata.QAccount,
-			videoMetadata.Result.Streamdata.QPrefix,
-			videoMetadata.Result.Streamdata.QLocator,
-			selector)
+	newMediaStreamArray := make([]MediaStreamArray, len(streams))
+	for i, stream := range streams {
 		newMediaStreamArray[i] = MediaStreamArray{
-			CDN:    videoMetadata.Result.Streamdata.CdnType,
-			Width:  width,
-			Height: height,
+			CDN:    stream.CDN,
+			Width:  stream.Width,
+			Height: stream.Height,
 			Stream: Streams{
 				StreamUrls: []string{
-					videoUrl,
+					stream.URL,
 				},
 			},
 		}
@@ -282,63 +311,6 @@ func (showVideo *ShowVideo) getNumberOfNonAdaptiveStreams() int {
 	return nonAdaptiveStreams
 }
 
-func (api *ArdAPI) initializeNexxSession() (cid string, err error) {
-	currentTime := time.Now().Unix()
-	randomNumber := 10000 + rand.Intn(90000)
-	deviceId := fmt.Sprintf("%v:%v", currentTime, randomNumber)
-
-	postData := url.Values{
-		"nxp_devh": {deviceId},
-	}
-	URL := fmt.Sprintf("https://api.nexx.cloud/v3/%v/session/init", funkDomainId)
-	body, err := api.fnPostRequest(URL, postData, map[string]string{})
-	if err != nil {
-		return
-	}
-	var initSessionResponse NexxInitSessionResponse
-	err = json.Unmarshal(body, &initSessionResponse)
-	if err != nil {
-		return
-	}
-	if initSessionResponse.Result.General.Cid == "" {
-		err = errors.New("No cid from initializing session.")
-		return
-	}
-
-	cid = initSessionResponse.Result.General.Cid
-	return
-}
-
-func (api *ArdAPI) getNexxVideoMetadata(videoId, cid string) (result NexxVideoMetadata, err error) {
-	postData := url.Values{
-		"addStatusDetails": {"1"},
-		"addStreamDetails": {"1"},
-		"addFeatures":      {"1"},
-		"addCaptions":      {"1"},
-		"addBumpers":       {"1"},
-		"captionFormat":    {"data"},
-	}
-
-	requestTokenValue := getMD5Hash(fmt.Sprintf("%v%v%v", "byid", funkDomainId, funkDomainHash))
-	headers := map[string]string{
-		"x-request-cid":   cid,
-		"x-request-token": requestTokenValue,
-	}
-	URL := fmt.Sprintf("https://api.nexx.cloud/v3/%v/videos/byid/%v", funkDomainId, videoId)
-	body, err := api.fnPostRequest(URL, postData, headers)
-	if err != nil {
-		return
-	}
-
-	err = json.Unmarshal(body, &result)
-	return
-}
-
-func getMD5Hash(text string) string {
-	hash := md5.Sum([]byte(text))
-	return hex.EncodeToString(hash[:])
-}
-
 func (show Show) hasAtLeastOneValidTeaser() bool {
 	if len(show.Teasers) < 1 {
 		return false
@@ -372,34 +344,3 @@ func doGetRequest(URL string) (result []byte, err error) {
 	}
 	return
 }
-
-func doPostRequest(URL string, data url.Values, headers map[string]string) (result []byte, err error) {
-	var resp *http.Response
-
-	postData := strings.NewReader(data.Encode())
-	req, err := http.NewRequest("POST", URL, postData)
-	if err != nil {
-		log.Printf("Error in building POST request for URL %v: %v", URL, err)
-		return
-	}
-
-	for k, v := range headers {
-		req.Header.Add(k, v)
-	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	client := &http.Client{}
-	resp, err = client.Do(req)
-	if err != nil {
-		log.Printf("Received error for URL %v: %v", URL, err)
-		return
-	}
-	defer resp.Body.Close()
-
-	result, err = ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Could not read body from POST request to URL %v.", URL)
-		return
-	}
-	return
-}
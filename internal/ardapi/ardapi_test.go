@@ -1,10 +1,12 @@
 package ardapi
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetShow(t *testing.T) {
@@ -32,6 +34,77 @@ func TestGetShow(t *testing.T) {
 	if len(result.Teasers) != maxEpisodes {
 		t.Fatalf("Expected %v episodes but got %v.", maxEpisodes, actualEpisodes)
 	}
+	expectedAvailableTo := time.Date(2099, 12, 31, 22, 59, 59, 0, time.UTC)
+	if !result.Teasers[0].AvailableTo.Equal(expectedAvailableTo) {
+		t.Fatalf("Expected availability until %v but got %v.", expectedAvailableTo, result.Teasers[0].AvailableTo)
+	}
+}
+
+func TestGetShowWithOptions(t *testing.T) {
+	const maxEpisodes = 2
+	const showID = "test"
+	expctedURL := fmt.Sprintf("https://api.ardmediathek.de/page-gateway/widgets/ard/asset/%v?pageNumber=0&pageSize=%v&withAudiodescription=true&withOriginalversion=true", showID, maxEpisodes)
+	fnGet := func(url string) (result []byte, err error) {
+		if strings.Compare(expctedURL, url) != 0 {
+			t.Fatalf("We expected the URL %v but received %v.", expctedURL, url)
+		}
+		return ioutil.ReadFile("../testdata/Y3JpZDovL2Z1bmsubmV0LzEwMzE.json")
+	}
+
+	ardAPI := CreateArdAPIWithGetFunc(maxEpisodes, fnGet, nil)
+	_, err := ardAPI.GetShowWithOptions(showID, ShowOptions{WithAudioDescription: true, WithOriginalVersion: true})
+	if err != nil {
+		t.Fatalf("There should be no error reported.")
+	}
+}
+
+func TestGetShowOfSeason(t *testing.T) {
+	const maxEpisodes = 2
+	const showID = "test"
+	expctedURL := fmt.Sprintf("https://api.ardmediathek.de/page-gateway/widgets/ard/asset/%v?pageNumber=0&pageSize=%v&seasoned=true&seasonNumber=2", showID, maxEpisodes)
+	fnGet := func(url string) (result []byte, err error) {
+		if strings.Compare(expctedURL, url) != 0 {
+			t.Fatalf("We expected the URL %v but received %v.", expctedURL, url)
+		}
+		return ioutil.ReadFile("../testdata/Y3JpZDovL2Z1bmsubmV0LzEwMzE.json")
+	}
+
+	ardAPI := CreateArdAPIWithGetFunc(maxEpisodes, fnGet, nil)
+	_, err := ardAPI.GetShowWithOptions(showID, ShowOptions{SeasonNumber: 2})
+	if err != nil {
+		t.Fatalf("There should be no error reported.")
+	}
+}
+
+func TestParseSeasonAndEpisode(t *testing.T) {
+	assertSeasonAndEpisode(t, "Babylon Berlin (S03/E12)", 3, 12)
+	assertSeasonAndEpisode(t, "Folge 7: Der Sturm (S1/E7) - Hörfassung", 1, 7)
+	assertSeasonAndEpisode(t, "Geil, geiler, Bayern! Der Freistaat-Wahnsinn im TV erklärt | WALULIS", 0, 0)
+}
+
+func TestGetSeasonAndEpisode(t *testing.T) {
+	var show Show
+	err := json.Unmarshal([]byte(`{"teasers":[
+		{"longTitle":"Babylon Berlin (S03/E12)","seasonNumber":"4","episodeNumber":2},
+		{"longTitle":"Babylon Berlin (S03/E12)","seasonNumber":null,"episodeNumber":""},
+		{"longTitle":"Babylon Berlin","seasonNumber":1,"episodeNumber":"7"}]}`), &show)
+	if err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	expected := []string{"4 2", "3 12", "1 7"}
+	for i, teaser := range show.Teasers {
+		season, episode := GetSeasonAndEpisode(teaser.SeasonNumber, teaser.EpisodeNumber, teaser.LongTitle)
+		if actual := fmt.Sprint(season, episode); actual != expected[i] {
+			t.Fatalf("Expected %v for teaser %v but got %v.", expected[i], i, actual)
+		}
+	}
+}
+
+func assertSeasonAndEpisode(t *testing.T, title string, expectedSeason, expectedEpisode int) {
+	season, episode := ParseSeasonAndEpisode(title)
+	if season != expectedSeason || episode != expectedEpisode {
+		t.Fatalf("Expected season %v and episode %v for %v but got %v and %v.", expectedSeason, expectedEpisode, title, season, episode)
+	}
 }
 
 func TestGetShowWithoutTeasers(t *testing.T) {
@@ -104,6 +177,9 @@ func TestGetVideoByURLWithMultipleStreamURLs(t *testing.T) {
 		!assertContains(t, (*mediaStreams)[1].Stream.StreamUrls, "https://download.media.tagesschau.de/video/2021/0925/TV-20210925-2356-5100.websm.h264.mp4") {
 		return
 	}
+
+	// assert subtitles of show
+	assertEquals(t, "https://www.ardmediathek.de/subtitle/547570", result.Widgets[0].MediaCollection.Embedded.SubtitleURL)
 }
 
 func assertEquals(t *testing.T, expected, actual interface{}) bool {
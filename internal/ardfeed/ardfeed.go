@@ -1,105 +1,230 @@
 package ardfeed
 
 import (
+	"errors"
 	"fmt"
-	"math"
+	"log"
+	"regexp"
 	"strings"
-	"time"
 
 	"github.com/seiferma/docker_mediathek2rss/internal"
 	"github.com/seiferma/docker_mediathek2rss/internal/ardapi"
-	"github.com/seiferma/docker_mediathek2rss/internal/rssfeed"
+	"github.com/seiferma/docker_mediathek2rss/internal/manifest"
+	"github.com/seiferma/docker_mediathek2rss/internal/nexxapi"
+	"github.com/seiferma/docker_mediathek2rss/internal/streamselect"
 )
 
-// CreateArdRssFeed creates an RSS feed for an ARD show.
-//
-// It takes the ID of the show, request parameters and the ARD API to use. It yields the feed as a string.
-// The effective media width might not perfectly match the requested media width but tries to get as close as possible.
-func CreateArdRssFeed(showID string, parameters internal.RequestParameters, ardAPI *ardapi.ArdAPI) (result string, err error) {
+// defaultAuthor is the author of shows without publication service.
+const defaultAuthor = "ARD"
+
+var showIDRegex = regexp.MustCompile("^[a-zA-Z0-9]+$")
+
+// Provider offers the shows of the ARD Mediathek, which are identified by the ID of the show in the Mediathek.
+type Provider struct {
+	fnCreateAPI func() ardapi.ArdAPI
+}
+
+// CreateProvider creates the provider of the ARD Mediathek, which lists up to maxEpisodes episodes per show.
+// The streams of funk videos that the ARD lists without streams are retrieved from the given funk API.
+func CreateProvider(maxEpisodes int, funkAPI *nexxapi.NexxAPI) *Provider {
+	return &Provider{
+		fnCreateAPI: func() ardapi.ArdAPI {
+			return ardapi.CreateArdAPI(maxEpisodes, funkAPI)
+		},
+	}
+}
+
+// ardShow holds the API and the show of the ARD API, which are needed to list the episodes.
+type ardShow struct {
+	api  *ardapi.ArdAPI
+	show *ardapi.Show
+	id   string
+}
+
+// ardEpisode holds the URL of the details of an episode and the details once they are loaded.
+type ardEpisode struct {
+	detailsURL string
+	video      *ardapi.ShowVideo
+}
+
+// ID yields ard.
+func (ardProvider *Provider) ID() string {
+	return "ard"
+}
+
+// Routes yields the only route of the ARD, which serves the shows at /ard/show/{showID}.
+func (ardProvider *Provider) Routes() []string {
+	return []string{""}
+}
+
+// IsValidShowID checks if the ID is alphanumeric like Y3JpZDovL2Z1bmsubmV0LzEwMzE.
+func (ardProvider *Provider) IsValidShowID(route, showID string) bool {
+	return showIDRegex.MatchString(showID)
+}
+
+// LookupShow yields the show with the given ID. The episodes of the show are restricted to the requested version
+// and season.
+func (ardProvider *Provider) LookupShow(route, showID string, parameters internal.RequestParameters) (show internal.Show, err error) {
+	ardAPI := ardProvider.fnCreateAPI()
 	var showInitial ardapi.Show
-	showInitial, err = ardAPI.GetShow(showID)
+	showInitial, err = ardAPI.GetShowWithOptions(showID, createShowOptions(parameters))
 	if err != nil {
 		return
 	}
 
 	feedURL := "https://www.ardmediathek.de/ard/sendung/" + showID
-	feedTitle := showInitial.Teasers[0].Show.Title
-	feedDescription := showInitial.Teasers[0].Show.LongSynopsis
 	feedImage := getFeedImage(showInitial.Teasers[0].Show.Images)
 	feedImageURL, _ := getFeedImageURLAndAlt(feedImage, parameters.Width)
+	show = internal.Show{
+		Title:       showInitial.Teasers[0].Show.Title,
+		Description: showInitial.Teasers[0].Show.LongSynopsis,
+		Link:        feedURL,
+		Image:       internal.Image{URL: feedImageURL, Title: showInitial.Teasers[0].Show.Title},
+		Author:      getAuthor(&showInitial),
+		Category:    internal.DefaultITunesCategory,
+		Details:     &ardShow{api: &ardAPI, show: &showInitial, id: showID},
+	}
+	return
+}
 
-	feedItems := make([]rssfeed.FeedItem, 0)
-	for _, teaser := range showInitial.Teasers {
-
-		if teaser.Duration < parameters.MinimumLengthInSeconds {
-			continue
+// ListEpisodes yields the episodes of the teasers of a show. The description, the image and the restrictions are
+// part of the details of an episode, which cost a request each and are loaded by LoadDetails.
+func (ardProvider *Provider) ListEpisodes(show *internal.Show, parameters internal.RequestParameters, fnVisit func(internal.Episode) bool) error {
+	details := show.Details.(*ardShow)
+	for _, teaser := range details.show.Teasers {
+		seasonNumber, episodeNumber := ardapi.GetSeasonAndEpisode(teaser.SeasonNumber, teaser.EpisodeNumber, teaser.LongTitle)
+		if seasonNumber == 0 {
+			// the API already yields the episodes of the requested season only
+			seasonNumber = parameters.Season
 		}
-
-		mediathekLink := "https://www.ardmediathek.de/ard/video/" + teaser.ID
-		videoAPIURL := teaser.Links.Target.Href
-		var video ardapi.ShowVideo
-		video, err = ardAPI.GetVideoByURL(videoAPIURL)
-		if err != nil {
-			return
+		episode := internal.Episode{
+			ID:                teaser.ID,
+			Title:             teaser.LongTitle,
+			Link:              "https://www.ardmediathek.de/ard/video/" + teaser.ID,
+			Date:              teaser.BroadcastedOn,
+			DurationInSeconds: teaser.Duration,
+			AvailableFrom:     teaser.AvailableFrom,
+			AvailableTo:       teaser.AvailableTo,
+			Season:            seasonNumber,
+			Number:            episodeNumber,
+			Details:           &ardEpisode{detailsURL: teaser.Links.Target.Href},
 		}
-		mediaStreams := video.Widgets[0].MediaCollection.Embedded.MediaArray[0].MediaStreamArray
-		synopsis := video.Widgets[0].Synopsis
-		videoImage := video.Widgets[0].Image
-		videoImageURL, _ := getFeedImageURLAndAlt(videoImage, parameters.Width)
-
-		lastWidth := 0
-		var lastURL string
-		for _, mediaStream := range *mediaStreams {
-			newDistance := math.Abs(float64(parameters.Width - mediaStream.Width))
-			oldDistance := math.Abs(float64(parameters.Width - lastWidth))
-			for _, stream := range mediaStream.Stream.StreamUrls {
-				if strings.Contains(stream, "mp4") && newDistance < oldDistance {
-					lastWidth = mediaStream.Width
-					lastURL = stream
-				}
-			}
+		if !fnVisit(episode) {
+			break
 		}
+	}
+	return nil
+}
 
-		pubDataArray := make([]time.Time, 1)
-		pubDataArray[0] = teaser.BroadcastedOn
-
-		item := rssfeed.FeedItem{
-			Title:       teaser.LongTitle,
-			Description: &rssfeed.FeedDescription{Text: synopsis},
-			PubDate:     &pubDataArray[0],
-			GUID: &rssfeed.FeedGUID{
-				Text: teaser.ID,
-			},
-			Link:                 mediathekLink,
-			ITunesTitle:          teaser.LongTitle,
-			ITunesSummary:        &rssfeed.ItunesSummary{Text: synopsis},
-			ITunesDurationString: rssfeed.CreateItunesDurationStringFromSeconds(teaser.Duration),
-			ITunesImage: &rssfeed.ITunesImage{
-				URL: videoImageURL,
-			},
-			Enclosure: &rssfeed.FeedItemEnclosure{
-				URL:  lastURL,
-				Type: "video/mp4",
-			},
-		}
-		feedItems = append(feedItems, item)
+// LoadDetails fetches the details of an episode, which contain its description, image, restrictions and streams.
+// Episodes without details are skipped.
+func (ardProvider *Provider) LoadDetails(show *internal.Show, episode *internal.Episode, parameters internal.RequestParameters) (found bool, err error) {
+	details := episode.Details.(*ardEpisode)
+	video, err := show.Details.(*ardShow).api.GetVideoByURL(details.detailsURL)
+	if err != nil {
+		return
+	}
+	if len(video.Widgets) == 0 {
+		log.Printf("Skipping episode %v of show %v because the API provides no details.", episode.ID, show.Details.(*ardShow).id)
+		return
 	}
+	details.video = &video
+	videoImageURL, _ := getFeedImageURLAndAlt(video.Widgets[0].Image, parameters.Width)
+	episode.Description = video.Widgets[0].Synopsis
+	episode.Image = internal.Image{URL: videoImageURL}
+	episode.Restrictions = getRestrictions(&video)
+	found = true
+	return
+}
 
-	feed := rssfeed.CreateFeed()
-	feed.Channel = rssfeed.Channel{
-		Title:       feedTitle,
-		Link:        feedURL,
-		Description: &rssfeed.FeedDescription{Text: feedDescription},
-		Image: &rssfeed.Image{
-			URL:   feedImageURL,
-			Title: feedTitle,
-		},
-		FeedItems: feedItems,
+// ResolveMedia selects the stream of the episode that matches the request parameters best and adds the subtitles,
+// which the ARD offers in German only.
+func (ardProvider *Provider) ResolveMedia(show *internal.Show, episode *internal.Episode, parameters internal.RequestParameters, services *internal.MediaServices) (err error) {
+	video := episode.Details.(*ardEpisode).video
+	mediaStreams := getMediaStreams(video)
+	if mediaStreams == nil {
+		err = errors.New("no media streams")
+		return
+	}
+	URL, mimeType := findBestMatchingStream(mediaStreams, parameters, services)
+	episode.Media = &internal.MediaVariant{URL: URL, MimeType: mimeType}
+	if subtitleURL := video.Widgets[0].MediaCollection.Embedded.SubtitleURL; subtitleURL != "" {
+		episode.Subtitles = []internal.Subtitle{{URL: subtitleURL, MimeType: internal.TTMLMimeType, Language: "deu"}}
 	}
-	result, err = feed.SerializeToString()
 	return
 }
 
+// getAuthor yields the name of the television channel that publishes the show, e.g. Das Erste or funk.
+func getAuthor(show *ardapi.Show) string {
+	if name := show.Teasers[0].PublicationService.Name; name != "" {
+		return name
+	}
+	return defaultAuthor
+}
+
+// getMediaStreams yields the media streams of a video or nil if there are none.
+func getMediaStreams(video *ardapi.ShowVideo) []ardapi.MediaStreamArray {
+	mediaArray := video.Widgets[0].MediaCollection.Embedded.MediaArray
+	if len(mediaArray) == 0 || mediaArray[0].MediaStreamArray == nil {
+		return nil
+	}
+	return *mediaArray[0].MediaStreamArray
+}
+
+// getRestrictions yields the restrictions of a video. Geo-blocked videos of the ARD are only available in Germany.
+func getRestrictions(video *ardapi.ShowVideo) internal.Restrictions {
+	geoRegion := ""
+	if video.Widgets[0].Geoblocked {
+		geoRegion = "de"
+	}
+	return internal.CreateRestrictions(geoRegion, video.Widgets[0].MaturityContentRating)
+}
+
+// createShowOptions selects the version and the season of the episodes. The ARD API offers no choice of the language and no
+// versions with sign language, so the normal version is used in these cases.
+func createShowOptions(parameters internal.RequestParameters) ardapi.ShowOptions {
+	return ardapi.ShowOptions{
+		WithAudioDescription: parameters.Variant == internal.VariantAudioDescription,
+		WithOriginalVersion:  parameters.Variant == internal.VariantOriginalVersion,
+		SeasonNumber:         parameters.Season,
+	}
+}
+
+func findBestMatchingStream(mediaStreams []ardapi.MediaStreamArray, parameters internal.RequestParameters, services *internal.MediaServices) (URL, mimeType string) {
+	return streamselect.Resolve(createCandidates(mediaStreams), parameters, services, nil)
+}
+
+// createCandidates creates the candidates of the streams of an episode. The ARD API provides neither the codec nor
+// the bitrate of the streams, so both are unknown and never exclude a stream.
+func createCandidates(mediaStreams []ardapi.MediaStreamArray) []streamselect.Candidate {
+	candidates := make([]streamselect.Candidate, 0, len(mediaStreams))
+	for _, mediaStream := range mediaStreams {
+		for _, stream := range mediaStream.Stream.StreamUrls {
+			candidate := streamselect.Candidate{
+				URL:    stream,
+				Width:  mediaStream.Width,
+				Height: mediaStream.Height,
+			}
+			if audioMimeType, found := internal.GetAudioMimeTypeFromURL(stream); found {
+				candidate.MimeType = audioMimeType
+			} else if strings.Contains(stream, "m3u8") {
+				candidate.MimeType = manifest.HLSMimeType
+				candidate.Container = "m3u8"
+				candidate.IsAdaptive = true
+			} else if strings.Contains(stream, ".mpd") {
+				candidate.MimeType = manifest.DASHMimeType
+				candidate.Container = "mpd"
+				candidate.IsAdaptive = true
+			} else if strings.Contains(stream, "mp4") {
+				candidate.MimeType = internal.VideoMimeType
+				candidate.Container = "mp4"
+			}
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates
+}
+
 func getFeedImage(feedImageCandidates map[string](ardapi.ShowImage)) ardapi.ShowImage {
 	var feedImageCandidate ardapi.ShowImage
 	for k, v := range feedImageCandidates {
@@ -1,22 +1,30 @@
 package ardfeed
 
 import (
+	"encoding/json"
 	"errors"
 	"io/ioutil"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/seiferma/docker_mediathek2rss/internal"
 	"github.com/seiferma/docker_mediathek2rss/internal/ardapi"
+	"github.com/seiferma/docker_mediathek2rss/internal/provider"
+	"github.com/seiferma/docker_mediathek2rss/internal/rssfeed"
 )
 
 var defaultParameters internal.RequestParameters = internal.RequestParameters{
 	Width: 1920,
 }
 
+// testNow is the current time during the tests, which is the build date of the feeds.
+var testNow = time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
 func TestCreateRssFeedInvalidShowId(t *testing.T) {
 	urlToFilename := map[string](string){}
-	_, err := createRssFeedMocked("Y3JpZDovL2Z1bmsubmV0LzEwMzE", 2, defaultParameters, urlToFilename, CreateArdRssFeed)
+	_, err := createRssFeedMocked("Y3JpZDovL2Z1bmsubmV0LzEwMzE", 2, defaultParameters, urlToFilename)
 	if err == nil {
 		t.Fatal("There should be an error.")
 	}
@@ -26,7 +34,7 @@ func TestCreateRssFeedInvalidEpisodeURL(t *testing.T) {
 	urlToFilename := map[string](string){}
 	urlToFilename["https://api.ardmediathek.de/page-gateway/widgets/ard/asset/Y3JpZDovL2Z1bmsubmV0LzEwMzE?pageNumber=0&pageSize=2"] = "Y3JpZDovL2Z1bmsubmV0LzEwMzE.json"
 
-	_, err := createRssFeedMocked("Y3JpZDovL2Z1bmsubmV0LzEwMzE", 2, defaultParameters, urlToFilename, CreateArdRssFeed)
+	_, err := createRssFeedMocked("Y3JpZDovL2Z1bmsubmV0LzEwMzE", 2, defaultParameters, urlToFilename)
 	if err == nil {
 		t.Fatal("There should be an error.")
 	}
@@ -38,10 +46,13 @@ func TestCreateRssFeedValid(t *testing.T) {
 	urlToFilename["https://api.ardmediathek.de/page-gateway/pages/ard/item/Y3JpZDovL2Z1bmsubmV0LzEwMzEvdmlkZW8vMTcwNzQ0Mg?devicetype=pc&embedded=true"] = "Y3JpZDovL2Z1bmsubmV0LzEwMzEvdmlkZW8vMTcwNzQ0Mg.json"
 	urlToFilename["https://api.ardmediathek.de/page-gateway/pages/ard/item/Y3JpZDovL2Z1bmsubmV0LzEwMzEvdmlkZW8vMTcwNjkzOA?devicetype=pc&embedded=true"] = "Y3JpZDovL2Z1bmsubmV0LzEwMzEvdmlkZW8vMTcwNjkzOA.json"
 
-	result, err := createRssFeedMocked("Y3JpZDovL2Z1bmsubmV0LzEwMzE", 2, defaultParameters, urlToFilename, CreateArdRssFeed)
+	result, err := createRssFeedMocked("Y3JpZDovL2Z1bmsubmV0LzEwMzE", 2, defaultParameters, urlToFilename)
 	if err != nil {
 		t.Fatalf("There should not be an error.\n%v", err)
 	}
+	if report := rssfeed.Validate([]byte(result)); !report.IsValid() {
+		t.Fatalf("The created feed is not valid.\n%v", report)
+	}
 	expectedBytes, err := ioutil.ReadFile("../testdata/Y3JpZDovL2Z1bmsubmV0LzEwMzE.xml")
 	expected := string(expectedBytes)
 
@@ -61,7 +72,7 @@ func TestCreateRssFeedValidWithEpisodeLengthFilter(t *testing.T) {
 	parameters := defaultParameters
 	parameters.MinimumLengthInSeconds = 10 * 60
 
-	result, err := createRssFeedMocked("Y3JpZDovL2Z1bmsubmV0LzEwMzE", 2, parameters, urlToFilename, CreateArdRssFeed)
+	result, err := createRssFeedMocked("Y3JpZDovL2Z1bmsubmV0LzEwMzE", 2, parameters, urlToFilename)
 	if err != nil {
 		t.Fatalf("There should not be an error.\n%v", err)
 	}
@@ -75,8 +86,132 @@ func TestCreateRssFeedValidWithEpisodeLengthFilter(t *testing.T) {
 	}
 }
 
-func createRssFeedMocked(showID string, maxEpisodes int, parameters internal.RequestParameters, urlToFilename map[string](string), fnCreate func(showID string, parameters internal.RequestParameters, ardAPI *ardapi.ArdAPI) (result string, err error)) (result string, err error) {
+func TestCreateRssFeedLoadsDetailsOfIncludedEpisodesOnly(t *testing.T) {
+	urlToFilename := map[string](string){}
+	urlToFilename["https://api.ardmediathek.de/page-gateway/widgets/ard/asset/Y3JpZDovL2Z1bmsubmV0LzEwMzE?pageNumber=0&pageSize=2"] = "Y3JpZDovL2Z1bmsubmV0LzEwMzE.json"
+	urlToFilename["https://api.ardmediathek.de/page-gateway/pages/ard/item/Y3JpZDovL2Z1bmsubmV0LzEwMzEvdmlkZW8vMTcwNzQ0Mg?devicetype=pc&embedded=true"] = "Y3JpZDovL2Z1bmsubmV0LzEwMzEvdmlkZW8vMTcwNzQ0Mg.json"
+	urlToFilename["https://api.ardmediathek.de/page-gateway/pages/ard/item/Y3JpZDovL2Z1bmsubmV0LzEwMzEvdmlkZW8vMTcwNjkzOA?devicetype=pc&embedded=true"] = "Y3JpZDovL2Z1bmsubmV0LzEwMzEvdmlkZW8vMTcwNjkzOA.json"
+
+	parameters := defaultParameters
+	parameters.MinimumLengthInSeconds = 10 * 60
+	result, requests, err := createRssFeedCountingRequests("Y3JpZDovL2Z1bmsubmV0LzEwMzE", 2, parameters, urlToFilename)
+	if err != nil {
+		t.Fatalf("There should not be an error.\n%v", err)
+	}
+	assertEquals(t, 1, strings.Count(result, "<item>"))
+	assertEquals(t, 2, len(requests))
+	assertEquals(t, 0, requests["https://api.ardmediathek.de/page-gateway/pages/ard/item/Y3JpZDovL2Z1bmsubmV0LzEwMzEvdmlkZW8vMTcwNzQ0Mg?devicetype=pc&embedded=true"])
+
+	parameters = defaultParameters
+	parameters.Exclude = regexp.MustCompile("Big Brother")
+	result, requests, _ = createRssFeedCountingRequests("Y3JpZDovL2Z1bmsubmV0LzEwMzE", 2, parameters, urlToFilename)
+	assertEquals(t, 1, strings.Count(result, "<item>"))
+	assertEquals(t, 0, requests["https://api.ardmediathek.de/page-gateway/pages/ard/item/Y3JpZDovL2Z1bmsubmV0LzEwMzEvdmlkZW8vMTcwNjkzOA?devicetype=pc&embedded=true"])
+}
+
+func TestCreateRssFeedLoadsNoDetailsBeyondLimit(t *testing.T) {
+	urlToFilename := map[string](string){}
+	urlToFilename["https://api.ardmediathek.de/page-gateway/widgets/ard/asset/Y3JpZDovL2Z1bmsubmV0LzEwMzE?pageNumber=0&pageSize=2"] = "Y3JpZDovL2Z1bmsubmV0LzEwMzE.json"
+	urlToFilename["https://api.ardmediathek.de/page-gateway/pages/ard/item/Y3JpZDovL2Z1bmsubmV0LzEwMzEvdmlkZW8vMTcwNzQ0Mg?devicetype=pc&embedded=true"] = "Y3JpZDovL2Z1bmsubmV0LzEwMzEvdmlkZW8vMTcwNzQ0Mg.json"
+	urlToFilename["https://api.ardmediathek.de/page-gateway/pages/ard/item/Y3JpZDovL2Z1bmsubmV0LzEwMzEvdmlkZW8vMTcwNjkzOA?devicetype=pc&embedded=true"] = "Y3JpZDovL2Z1bmsubmV0LzEwMzEvdmlkZW8vMTcwNjkzOA.json"
+
+	parameters := defaultParameters
+	parameters.Limit = 1
+	result, requests, err := createRssFeedCountingRequests("Y3JpZDovL2Z1bmsubmV0LzEwMzE", 2, parameters, urlToFilename)
+	if err != nil {
+		t.Fatalf("There should not be an error.\n%v", err)
+	}
+	assertEquals(t, 1, strings.Count(result, "<item>"))
+	assertEquals(t, 2, len(requests))
+	assertEquals(t, 0, requests["https://api.ardmediathek.de/page-gateway/pages/ard/item/Y3JpZDovL2Z1bmsubmV0LzEwMzEvdmlkZW8vMTcwNjkzOA?devicetype=pc&embedded=true"])
+}
+
+func TestGetRestrictions(t *testing.T) {
+	var video ardapi.ShowVideo
+	err := json.Unmarshal([]byte(`{"widgets":[{"geoblocked":true,"maturityContentRating":"FSK16"}]}`), &video)
+	if err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	assertConvertEquals(t, getRestrictions(&video), "{de 16}")
+
+	var unrestrictedVideo ardapi.ShowVideo
+	content, _ := ioutil.ReadFile("../testdata/Y3JpZDovL2Z1bmsubmV0LzEwMzEvdmlkZW8vMTcwNzQ0Mg.json")
+	err = json.Unmarshal(content, &unrestrictedVideo)
+	if err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	assertConvertEquals(t, getRestrictions(&unrestrictedVideo), "{ 0}")
+}
+
+func TestIsValidShowID(t *testing.T) {
+	assertIsValidShowID(t, "", false)
+	assertIsValidShowID(t, "a", true)
+	assertIsValidShowID(t, "a1", true)
+	assertIsValidShowID(t, "b2H", true)
+	assertIsValidShowID(t, "b2H?", false)
+	assertIsValidShowID(t, "a\\q", false)
+	assertIsValidShowID(t, "a/b", false)
+}
+
+func assertIsValidShowID(t *testing.T, idToTest string, expectedResult bool) {
+	actual := CreateProvider(1, nil).IsValidShowID("", idToTest)
+	if actual != expectedResult {
+		t.Fatalf("Expected the validation of %v to return %v but got %v.", idToTest, expectedResult, actual)
+	}
+}
+
+func TestCreateRssFeedConsidersAvailabilityStart(t *testing.T) {
+	urlToFilename := map[string](string){}
+	urlToFilename["https://api.ardmediathek.de/page-gateway/widgets/ard/asset/Y3JpZDovL2Z1bmsubmV0LzEwMzE?pageNumber=0&pageSize=2"] = "Y3JpZDovL2Z1bmsubmV0LzEwMzE.json"
+	urlToFilename["https://api.ardmediathek.de/page-gateway/pages/ard/item/Y3JpZDovL2Z1bmsubmV0LzEwMzEvdmlkZW8vMTcwNzQ0Mg?devicetype=pc&embedded=true"] = "Y3JpZDovL2Z1bmsubmV0LzEwMzEvdmlkZW8vMTcwNzQ0Mg.json"
+	urlToFilename["https://api.ardmediathek.de/page-gateway/pages/ard/item/Y3JpZDovL2Z1bmsubmV0LzEwMzEvdmlkZW8vMTcwNjkzOA?devicetype=pc&embedded=true"] = "Y3JpZDovL2Z1bmsubmV0LzEwMzEvdmlkZW8vMTcwNjkzOA.json"
+	fnGetHTTP := func(URL string) (result []byte, err error) {
+		filename, ok := urlToFilename[URL]
+		if !ok {
+			err = errors.New("unknown URL")
+			return
+		}
+		result, err = ioutil.ReadFile("../testdata/" + filename)
+		// the first episode becomes available after the build date of the feed
+		result = []byte(strings.Replace(string(result), `"availableTo":`, `"availableFrom":"2021-01-02T00:00:00Z","availableTo":`, 1))
+		return
+	}
+	ardProvider := &Provider{
+		fnCreateAPI: func() ardapi.ArdAPI {
+			return ardapi.CreateArdAPIWithGetFunc(2, fnGetHTTP, nil)
+		},
+	}
+
+	result, err := provider.CreateFeed(ardProvider, "", "Y3JpZDovL2Z1bmsubmV0LzEwMzE", "", defaultParameters, &internal.MediaServices{}, testNow)
+	if err != nil {
+		t.Fatalf("There should not be an error.\n%v", err)
+	}
+	assertEquals(t, 1, strings.Count(result, "<item>"))
+	result, _ = provider.CreateFeed(ardProvider, "", "Y3JpZDovL2Z1bmsubmV0LzEwMzE", "", defaultParameters, &internal.MediaServices{}, testNow.AddDate(0, 0, 1))
+	assertEquals(t, 2, strings.Count(result, "<item>"))
+}
+
+func TestCreateShowOptions(t *testing.T) {
+	parameters := defaultParameters
+	parameters.Variant = internal.VariantAudioDescription
+	assertConvertEquals(t, createShowOptions(parameters), "{true false 0}")
+	parameters.Variant = internal.VariantOriginalVersion
+	assertConvertEquals(t, createShowOptions(parameters), "{false true 0}")
+	parameters.Variant = internal.VariantSignLanguage
+	parameters.Season = 2
+	assertConvertEquals(t, createShowOptions(parameters), "{false false 2}")
+}
+
+func createRssFeedMocked(showID string, maxEpisodes int, parameters internal.RequestParameters, urlToFilename map[string](string)) (result string, err error) {
+	result, _, err = createRssFeedCountingRequests(showID, maxEpisodes, parameters, urlToFilename)
+	return
+}
+
+// createRssFeedCountingRequests creates the feed and counts the requests per URL.
+func createRssFeedCountingRequests(showID string, maxEpisodes int, parameters internal.RequestParameters, urlToFilename map[string](string)) (result string, requests map[string](int), err error) {
+	requests = map[string](int){}
 	fnGetHTTP := func(URL string) (result []byte, err error) {
+		requests[URL]++
 		filename, ok := urlToFilename[URL]
 		if !ok {
 			err = errors.New("unknown URL")
@@ -85,8 +220,12 @@ func createRssFeedMocked(showID string, maxEpisodes int, parameters internal.Req
 		result, err = ioutil.ReadFile("../testdata/" + filename)
 		return
 	}
-	ardAPI := ardapi.CreateArdAPIWithGetFunc(maxEpisodes, fnGetHTTP, nil)
-	result, err = fnCreate(showID, parameters, &ardAPI)
+	ardProvider := &Provider{
+		fnCreateAPI: func() ardapi.ArdAPI {
+			return ardapi.CreateArdAPIWithGetFunc(maxEpisodes, fnGetHTTP, nil)
+		},
+	}
+	result, err = provider.CreateFeed(ardProvider, "", showID, "", parameters, &internal.MediaServices{}, testNow)
 	return
 }
 
@@ -143,6 +282,43 @@ func TestFeedImageFinderWithout169(t *testing.T) {
 	}
 }
 
+func TestFindBestMatchingStream(t *testing.T) {
+	mediaStreams := createMediaStreams()
+	actualURL, actualMimeType := findBestMatchingStream(mediaStreams, defaultParameters, &internal.MediaServices{})
+	assertStringEquals(t, "https://foo.bar/video_1920.mp4", actualURL)
+	assertStringEquals(t, "video/mp4", actualMimeType)
+}
+
+func TestFindBestMatchingStreamAudioOnly(t *testing.T) {
+	mediaStreams := createMediaStreams()
+	mediaStreams = append(mediaStreams, ardapi.MediaStreamArray{
+		Stream: ardapi.Streams{StreamUrls: []string{"https://foo.bar/audio.mp3"}},
+	})
+	parameters := defaultParameters
+	parameters.AudioOnly = true
+	actualURL, actualMimeType := findBestMatchingStream(mediaStreams, parameters, &internal.MediaServices{})
+	assertStringEquals(t, "https://foo.bar/audio.mp3", actualURL)
+	assertStringEquals(t, "audio/mpeg", actualMimeType)
+}
+
+func TestFindBestMatchingStreamAudioOnlyWithoutAudio(t *testing.T) {
+	mediaStreams := createMediaStreams()
+	parameters := defaultParameters
+	parameters.AudioOnly = true
+	actualURL, actualMimeType := findBestMatchingStream(mediaStreams, parameters, &internal.MediaServices{})
+	assertStringEquals(t, "https://foo.bar/video_640.mp4", actualURL)
+	assertStringEquals(t, "video/mp4", actualMimeType)
+}
+
+func createMediaStreams() []ardapi.MediaStreamArray {
+	return []ardapi.MediaStreamArray{
+		{Stream: ardapi.Streams{StreamUrls: []string{"https://foo.bar/master.m3u8"}}},
+		{Width: 960, Stream: ardapi.Streams{StreamUrls: []string{"https://foo.bar/video_960.mp4"}}},
+		{Width: 640, Stream: ardapi.Streams{StreamUrls: []string{"https://foo.bar/video_640.mp4"}}},
+		{Width: 1920, Stream: ardapi.Streams{StreamUrls: []string{"https://foo.bar/video_1920.mp4"}}},
+	}
+}
+
 func TestConvertToString(t *testing.T) {
 	assertConvertEquals(t, 0, "0")
 	assertConvertEquals(t, 1, "1")
@@ -160,3 +336,9 @@ func assertStringEquals(t *testing.T, expected, actual string) {
 		t.Fatalf("Expected %v but got %v.", expected, actual)
 	}
 }
+
+func assertEquals(t *testing.T, expected, actual interface{}) {
+	if expected != actual {
+		t.Fatalf("Expected %v but got %v.", expected, actual)
+	}
+}
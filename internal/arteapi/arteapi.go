@@ -0,0 +1,247 @@
+package arteapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const playerAPIBase = "https://api.arte.tv/api/player/v2/config"
+const pageAPIBase = "https://www.arte.tv/api/rproxy/emac/v4"
+
+// collectionZonePrefix is the prefix of the codes of the zones of a collection page that list the videos of the
+// collection. Other zones of the page list related collections and trailers.
+const collectionZonePrefix = "collection_videos"
+
+// imageSizePlaceholder is the placeholder for the size of an image in image URLs of the API.
+const imageSizePlaceholder = "__SIZE__"
+
+// ArteAPI gives access to the collections and programmes of the arte API.
+// Its main purpose is to hold configuration parameters and provide them to the API functions.
+type ArteAPI struct {
+	maxEpisodes  int
+	fnGetRequest func(string) ([]byte, error)
+}
+
+// Image represents an image DTO from the API. The URL contains the placeholder __SIZE__ for the requested size.
+type Image struct {
+	URL     string `json:"url"`
+	Caption string `json:"caption"`
+}
+
+// Availability describes when a video can be watched. Zero times leave the corresponding end of the window open.
+type Availability struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Teaser represents a DTO for a video listed on a page of a collection.
+type Teaser struct {
+	ProgramID        string       `json:"programId"` // e.g. 098765-001-A
+	Title            string       `json:"title"`
+	Subtitle         string       `json:"subtitle"` // e.g. the title of the episode
+	ShortDescription string       `json:"shortDescription"`
+	Duration         int          `json:"duration"` // the duration in seconds
+	URL              string       `json:"url"`
+	MainImage        Image        `json:"mainImage"`
+	Availability     Availability `json:"availability"`
+}
+
+// Collection represents a DTO for a collection of videos, e.g. a series or a documentary in several parts.
+type Collection struct {
+	Metadata struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Link        struct {
+			URL string `json:"url"`
+		} `json:"link"`
+		Images []Image `json:"images"`
+	} `json:"metadata"`
+	Zones []struct {
+		Code    string `json:"code"`
+		Content struct {
+			Data []Teaser `json:"data"`
+		} `json:"content"`
+	} `json:"zones"`
+}
+
+// Stream represents a DTO for a stream of a programme in one version.
+type Stream struct {
+	URL      string `json:"url"`
+	Versions []struct {
+		Label      string `json:"label"`
+		ShortLabel string `json:"shortLabel"`
+		EStat      struct {
+			Code string `json:"ml5"` // the version code, e.g. VA for the German dubbed version
+		} `json:"eStat"`
+	} `json:"versions"`
+	MainQuality struct {
+		Code  string `json:"code"` // e.g. XQ for 1080p
+		Label string `json:"label"`
+	} `json:"mainQuality"`
+	Protocol string `json:"protocol"` // e.g. HLS_NG or HTTPS
+}
+
+// Program represents a DTO for the player configuration of a programme, which holds its metadata and streams.
+type Program struct {
+	Metadata struct {
+		ProviderID  string `json:"providerId"`
+		Title       string `json:"title"`
+		Subtitle    string `json:"subtitle"`
+		Description string `json:"description"`
+		Link        struct {
+			URL string `json:"url"`
+		} `json:"link"`
+		Images   []Image `json:"images"`
+		Duration struct {
+			Seconds int `json:"seconds"`
+		} `json:"duration"`
+	} `json:"metadata"`
+	Rights struct {
+		Begin time.Time `json:"begin"`
+		End   time.Time `json:"end"`
+	} `json:"rights"`
+	Restriction struct {
+		Geoblocking struct {
+			Code string `json:"code"` // e.g. ALL or DE_FR
+		} `json:"geoblocking"`
+	} `json:"restriction"`
+	Streams []Stream `json:"streams"`
+}
+
+type collectionResponse struct {
+	Value Collection `json:"value"`
+}
+
+type programResponse struct {
+	Data struct {
+		Attributes Program `json:"attributes"`
+	} `json:"data"`
+}
+
+// CreateArteAPI creates a new API instance taking configuration values to be considered when working with the API.
+// The maxEpisodes parameter defines how many episodes of a collection shall be received at most.
+func CreateArteAPI(maxEpisodes int) ArteAPI {
+	return CreateArteAPIWithGetFunc(maxEpisodes, doGetRequest)
+}
+
+// CreateArteAPIWithGetFunc creates a new API instance taking configuration values to be considered when working
+// with the API. The maxEpisodes parameter defines how many episodes of a collection shall be received at most.
+// The fnGetRequest parameter provides a function that carries out a get request and provides the body as byte array.
+func CreateArteAPIWithGetFunc(maxEpisodes int, fnGetRequest func(string) ([]byte, error)) ArteAPI {
+	return ArteAPI{
+		maxEpisodes:  maxEpisodes,
+		fnGetRequest: fnGetRequest,
+	}
+}
+
+// GetCollection retrieves a collection by its ID (e.g. RC-014095) in the given language of the arte website, e.g. de
+// or fr. The metadata of the collection and its videos are in that language.
+func (api *ArteAPI) GetCollection(language, collectionID string) (result Collection, err error) {
+	collectionURL := fmt.Sprintf("%v/%v/web/pages/COLLECTION/%v/", pageAPIBase, language, collectionID)
+	var response collectionResponse
+	err = api.getJSON(collectionURL, &response)
+	if err != nil {
+		return
+	}
+	result = response.Value
+	if result.Metadata.Title == "" {
+		err = fmt.Errorf("there is no collection with ID %v", collectionID)
+	}
+	return
+}
+
+// GetProgram retrieves the player configuration of a programme by its ID (e.g. 098765-001-A) in the given language of
+// the arte website. The configuration contains the streams of all versions of the programme in that language.
+func (api *ArteAPI) GetProgram(language, programID string) (result Program, err error) {
+	programURL := fmt.Sprintf("%v/%v/%v", playerAPIBase, language, programID)
+	var response programResponse
+	err = api.getJSON(programURL, &response)
+	if err != nil {
+		return
+	}
+	result = response.Data.Attributes
+	if result.Metadata.Title == "" {
+		err = fmt.Errorf("there is no programme with ID %v", programID)
+	}
+	return
+}
+
+func (api *ArteAPI) getJSON(URL string, result interface{}) (err error) {
+	var body []byte
+	body, err = api.fnGetRequest(URL)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, result)
+	if err != nil {
+		log.Printf("Could not parse JSON body for request to URL %v. %v", URL, err)
+	}
+	return
+}
+
+// GetVideos yields the videos of a collection up to the maximum number of episodes. Videos listed in several zones
+// are only yielded once.
+func (api *ArteAPI) GetVideos(collection *Collection) []Teaser {
+	videos := make([]Teaser, 0)
+	knownIDs := map[string](bool){}
+	for _, zone := range collection.Zones {
+		if !strings.HasPrefix(zone.Code, collectionZonePrefix) {
+			continue
+		}
+		for _, teaser := range zone.Content.Data {
+			if len(videos) >= api.maxEpisodes {
+				return videos
+			}
+			if teaser.ProgramID == "" || knownIDs[teaser.ProgramID] {
+				continue
+			}
+			knownIDs[teaser.ProgramID] = true
+			videos = append(videos, teaser)
+		}
+	}
+	return videos
+}
+
+// GetVersionCode yields the code of the version of a stream, e.g. VOF for the French original version or VA-STA for
+// the German version with German subtitles. It yields an empty code if the stream has no version.
+func (stream *Stream) GetVersionCode() string {
+	if len(stream.Versions) == 0 {
+		return ""
+	}
+	return stream.Versions[0].EStat.Code
+}
+
+// GetImageURL yields the URL of the first image in the given size, e.g. 1920x1080. It yields an empty URL if there
+// are no images.
+func GetImageURL(images []Image, size string) string {
+	if len(images) == 0 {
+		return ""
+	}
+	return strings.Replace(images[0].URL, imageSizePlaceholder, size, -1)
+}
+
+func doGetRequest(URL string) (result []byte, err error) {
+	var resp *http.Response
+	resp, err = http.Get(URL)
+	if err != nil {
+		log.Printf("Received error for URL %v: %v", URL, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("received status %v for URL %v", resp.StatusCode, URL)
+		return
+	}
+
+	result, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Printf("Could not read body from GET request to URL %v.", URL)
+		return
+	}
+	return
+}
@@ -0,0 +1,90 @@
+package arteapi
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+var urlToFilename = map[string](string){
+	"https://www.arte.tv/api/rproxy/emac/v4/de/web/pages/COLLECTION/RC-014095/": "arte-collection-RC-014095.json",
+	"https://api.arte.tv/api/player/v2/config/de/098765-001-A":                  "arte-program-098765-001-A.json",
+}
+
+func createTestAPI(maxEpisodes int) ArteAPI {
+	fnGet := func(URL string) (result []byte, err error) {
+		filename, ok := urlToFilename[URL]
+		if !ok {
+			err = errors.New("unknown URL")
+			return
+		}
+		return ioutil.ReadFile("../testdata/" + filename)
+	}
+	return CreateArteAPIWithGetFunc(maxEpisodes, fnGet)
+}
+
+func TestGetCollection(t *testing.T) {
+	api := createTestAPI(10)
+	result, err := api.GetCollection("de", "RC-014095")
+	if err != nil {
+		t.Fatalf("There should be no error reported.\n%v", err)
+	}
+	assertEquals(t, "Europas Geschichte", result.Metadata.Title)
+	assertEquals(t, "https://www.arte.tv/de/videos/RC-014095/europas-geschichte/", result.Metadata.Link.URL)
+	assertEquals(t, 3, len(result.Zones))
+
+	teaser := result.Zones[0].Content.Data[0]
+	assertEquals(t, "098765-001-A", teaser.ProgramID)
+	assertEquals(t, "Die Antike", teaser.Subtitle)
+	assertEquals(t, 3120, teaser.Duration)
+	assertEquals(t, time.Date(2021, 2, 28, 4, 0, 0, 0, time.UTC), teaser.Availability.End)
+	assertEquals(t, true, result.Zones[0].Content.Data[1].Availability.End.IsZero())
+}
+
+func TestGetCollectionWithUnknownID(t *testing.T) {
+	api := createTestAPI(10)
+	if _, err := api.GetCollection("fr", "RC-014095"); err == nil {
+		t.Fatal("There should be an error.")
+	}
+}
+
+func TestGetVideos(t *testing.T) {
+	api := createTestAPI(10)
+	collection, _ := api.GetCollection("de", "RC-014095")
+	videos := api.GetVideos(&collection)
+	assertEquals(t, 3, len(videos))
+	assertEquals(t, "098765-003-A", videos[2].ProgramID)
+
+	api = createTestAPI(2)
+	assertEquals(t, 2, len(api.GetVideos(&collection)))
+}
+
+func TestGetProgram(t *testing.T) {
+	api := createTestAPI(10)
+	result, err := api.GetProgram("de", "098765-001-A")
+	if err != nil {
+		t.Fatalf("There should be no error reported.\n%v", err)
+	}
+	assertEquals(t, "Die Antike", result.Metadata.Subtitle)
+	assertEquals(t, 3120, result.Metadata.Duration.Seconds)
+	assertEquals(t, time.Date(2020, 12, 1, 4, 0, 0, 0, time.UTC), result.Rights.Begin)
+	assertEquals(t, "DE_FR", result.Restriction.Geoblocking.Code)
+	assertEquals(t, 6, len(result.Streams))
+	assertEquals(t, "VA", result.Streams[0].GetVersionCode())
+	assertEquals(t, "SQ", result.Streams[0].MainQuality.Code)
+	assertEquals(t, "VOSTA", result.Streams[5].GetVersionCode())
+	assertEquals(t, "", (&Stream{}).GetVersionCode())
+}
+
+func TestGetImageURL(t *testing.T) {
+	images := []Image{{URL: "https://api-cdn.arte.tv/img/v2/image/foo/__SIZE__"}}
+	assertEquals(t, "https://api-cdn.arte.tv/img/v2/image/foo/1920x1080", GetImageURL(images, "1920x1080"))
+	assertEquals(t, "", GetImageURL(nil, "1920x1080"))
+}
+
+func assertEquals(t *testing.T, expected, actual interface{}) {
+	if expected != actual {
+		t.Fatalf("Expected \"%v\" but got \"%v\".", expected, actual)
+	}
+}
@@ -0,0 +1,343 @@
+package artefeed
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/seiferma/docker_mediathek2rss/internal"
+	"github.com/seiferma/docker_mediathek2rss/internal/arteapi"
+	"github.com/seiferma/docker_mediathek2rss/internal/manifest"
+	"github.com/seiferma/docker_mediathek2rss/internal/streamselect"
+)
+
+// author is the author of all shows of arte.
+const author = "arte"
+
+// defaultImageWidth is the width of images if no width is requested.
+const defaultImageWidth = 1920
+
+// routes of arte
+const (
+	routeCollection = "collection"
+	routeProgram    = "program"
+)
+
+// language of the arte website and letter of the version codes for each ISO 639-2 code of the lang request parameter
+var websiteLanguages = map[string](websiteLanguage){
+	"deu": {"de", "A"},
+	"ger": {"de", "A"},
+	"fra": {"fr", "F"},
+	"fre": {"fr", "F"},
+}
+
+// defaultWebsiteLanguage is used if no language or a language without arte website is requested.
+var defaultWebsiteLanguage = websiteLanguages["deu"]
+
+// geo region of the restrictions for each geoblocking code of the arte API that differs from the lower case code
+var geoRegionByGeoblocking = map[string](string){
+	"ALL":       "none",
+	"EUR_DE_FR": "ebu",
+}
+
+// rank and approximate video dimensions of the qualities of the arte API
+var dimensionsByQuality = map[string](qualityDimensions){
+	"MQ": {1, 384, 216},
+	"HQ": {2, 640, 360},
+	"EQ": {3, 720, 406},
+	"SQ": {4, 1280, 720},
+	"XQ": {5, 1920, 1080},
+}
+
+var collectionIDRegex = regexp.MustCompile("^RC-[0-9]+$")
+var programIDRegex = regexp.MustCompile("^[0-9]{6}-([0-9]{3})-[A-Z]$")
+var bitrateRegex = regexp.MustCompile("_MP4-([0-9]+)_")
+
+type websiteLanguage struct {
+	code          string // e.g. de for www.arte.tv/de
+	versionLetter string // e.g. A in VA, the German version
+}
+
+type qualityDimensions struct {
+	rank   int
+	width  int
+	height int
+}
+
+// Provider offers the collections and programmes of arte, which are identified by their ID on the arte website.
+type Provider struct {
+	fnCreateAPI func() arteapi.ArteAPI
+}
+
+// CreateProvider creates the provider of arte, which lists up to maxEpisodes episodes per collection.
+func CreateProvider(maxEpisodes int) *Provider {
+	return &Provider{
+		fnCreateAPI: func() arteapi.ArteAPI {
+			return arteapi.CreateArteAPI(maxEpisodes)
+		},
+	}
+}
+
+// arteShow holds the API and the videos of a collection or the programme, which are needed to list the episodes.
+type arteShow struct {
+	api      *arteapi.ArteAPI
+	language websiteLanguage
+	videos   []arteapi.Teaser
+	program  *arteapi.Program // nil for collections
+}
+
+// arteEpisode holds the ID of the programme of an episode and the programme once its details are loaded.
+type arteEpisode struct {
+	programID string
+	program   *arteapi.Program // nil until the details are loaded
+}
+
+// ID yields arte.
+func (arteProvider *Provider) ID() string {
+	return "arte"
+}
+
+// Routes yields collection and program, which serve collections at /arte/show/collection/{ID} and single programmes
+// at /arte/show/program/{ID}.
+func (arteProvider *Provider) Routes() []string {
+	return []string{routeCollection, routeProgram}
+}
+
+// IsValidShowID checks if the ID is the ID of a collection like RC-014095 or of a programme like 098765-001-A.
+func (arteProvider *Provider) IsValidShowID(route, showID string) bool {
+	if route == routeCollection {
+		return collectionIDRegex.MatchString(showID)
+	}
+	return programIDRegex.MatchString(showID)
+}
+
+// LookupShow yields the collection or the programme with the given ID in the requested language.
+func (arteProvider *Provider) LookupShow(route, showID string, parameters internal.RequestParameters) (show internal.Show, err error) {
+	api := arteProvider.fnCreateAPI()
+	language := getWebsiteLanguage(parameters)
+	details := &arteShow{api: &api, language: language}
+	if route == routeProgram {
+		var program arteapi.Program
+		program, err = api.GetProgram(language.code, showID)
+		if err != nil {
+			return
+		}
+		details.program = &program
+		show = internal.Show{
+			Title:       program.Metadata.Title,
+			Description: program.Metadata.Description,
+			Link:        program.Metadata.Link.URL,
+			Image:       createImage(program.Metadata.Images, parameters.Width),
+		}
+	} else {
+		var collection arteapi.Collection
+		collection, err = api.GetCollection(language.code, showID)
+		if err != nil {
+			return
+		}
+		details.videos = api.GetVideos(&collection)
+		show = internal.Show{
+			Title:       collection.Metadata.Title,
+			Description: collection.Metadata.Description,
+			Link:        collection.Metadata.Link.URL,
+			Image:       createImage(collection.Metadata.Images, parameters.Width),
+		}
+	}
+	show.Author = author
+	show.Category = internal.DefaultITunesCategory
+	show.Language = language.code
+	show.Details = details
+	return
+}
+
+// ListEpisodes passes the videos of a collection as listed by the collection page. They lack the description and the
+// streams, which LoadDetails fetches only for the episodes that pass the filters and are within the limit of the feed.
+func (arteProvider *Provider) ListEpisodes(show *internal.Show, parameters internal.RequestParameters, fnVisit func(internal.Episode) bool) error {
+	details := show.Details.(*arteShow)
+	if details.program != nil {
+		episode := internal.Episode{Details: &arteEpisode{programID: details.program.Metadata.ProviderID}}
+		applyProgram(&episode, details.program, parameters)
+		fnVisit(episode)
+		return nil
+	}
+	for i := range details.videos {
+		if !fnVisit(createTeaserEpisode(&details.videos[i], parameters)) {
+			break
+		}
+	}
+	return nil
+}
+
+// LoadDetails fetches the programme of an episode of a collection. Programmes that are no longer offered by the API
+// are skipped.
+func (arteProvider *Provider) LoadDetails(show *internal.Show, episode *internal.Episode, parameters internal.RequestParameters) (bool, error) {
+	details := episode.Details.(*arteEpisode)
+	if details.program != nil {
+		return true, nil
+	}
+	showDetails := show.Details.(*arteShow)
+	program, err := showDetails.api.GetProgram(showDetails.language.code, details.programID)
+	if err != nil {
+		log.Printf("Skipping episode %v of arte collection because the API provides no details: %v", details.programID, err)
+		return false, nil
+	}
+	applyProgram(episode, &program, parameters)
+	return true, nil
+}
+
+// ResolveMedia selects the stream of the requested version of an episode that matches the request parameters best.
+// Subtitles of arte are part of the video, so there are no separate subtitle files.
+func (arteProvider *Provider) ResolveMedia(show *internal.Show, episode *internal.Episode, parameters internal.RequestParameters, services *internal.MediaServices) (err error) {
+	program := episode.Details.(*arteEpisode).program
+	if len(program.Streams) == 0 {
+		err = errors.New("no media streams")
+		return
+	}
+	language := show.Details.(*arteShow).language
+	streams := selectVersion(program.Streams, getVersionCodes(parameters.Variant, language))
+	URL, mimeType := streamselect.Resolve(createCandidates(streams), parameters, services, nil)
+	episode.Media = &internal.MediaVariant{URL: URL, MimeType: mimeType}
+	return
+}
+
+// createTeaserEpisode creates the episode of a video of a collection from the data of the collection page. The
+// episode number is the part number of the programme ID.
+func createTeaserEpisode(teaser *arteapi.Teaser, parameters internal.RequestParameters) internal.Episode {
+	episode := internal.Episode{
+		ID:                teaser.ProgramID,
+		Title:             joinTitle(teaser.Title, teaser.Subtitle),
+		Description:       teaser.ShortDescription,
+		Link:              teaser.URL,
+		Image:             createImage([]arteapi.Image{teaser.MainImage}, parameters.Width),
+		Date:              teaser.Availability.Start,
+		DurationInSeconds: teaser.Duration,
+		AvailableFrom:     teaser.Availability.Start,
+		AvailableTo:       teaser.Availability.End,
+		Details:           &arteEpisode{programID: teaser.ProgramID},
+	}
+	if matches := programIDRegex.FindStringSubmatch(teaser.ProgramID); matches != nil {
+		episode.Number, _ = strconv.Atoi(matches[1])
+	}
+	return episode
+}
+
+// applyProgram completes an episode with the metadata, the rights and the restrictions of its programme. The end of
+// the availability of the collection page is kept if the programme has no end.
+func applyProgram(episode *internal.Episode, program *arteapi.Program, parameters internal.RequestParameters) {
+	metadata := &program.Metadata
+	episode.ID = metadata.ProviderID
+	episode.Title = joinTitle(metadata.Title, metadata.Subtitle)
+	episode.Description = metadata.Description
+	episode.Link = metadata.Link.URL
+	episode.Image = createImage(metadata.Images, parameters.Width)
+	episode.Date = program.Rights.Begin
+	episode.DurationInSeconds = metadata.Duration.Seconds
+	episode.AvailableFrom = program.Rights.Begin
+	if !program.Rights.End.IsZero() {
+		episode.AvailableTo = program.Rights.End
+	}
+	episode.Restrictions = internal.CreateRestrictions(getGeoRegion(program.Restriction.Geoblocking.Code), "")
+	episode.Details.(*arteEpisode).program = program
+}
+
+// joinTitle yields the title of an episode, which consists of the title of the programme and its subtitle if any.
+func joinTitle(title, subtitle string) string {
+	if subtitle != "" {
+		return title + " - " + subtitle
+	}
+	return title
+}
+
+// getWebsiteLanguage yields the arte website in the requested language. arte offers its programmes in German and
+// French, so other languages fall back to the German website.
+func getWebsiteLanguage(parameters internal.RequestParameters) websiteLanguage {
+	if language, found := websiteLanguages[parameters.Language]; found {
+		return language
+	}
+	return defaultWebsiteLanguage
+}
+
+// getVersionCodes yields the codes of the versions of a programme in the order of preference for the requested
+// variant, e.g. VA-STA and VOSTA for the German version with subtitles. The normal version in the language of the
+// website is the fallback of all variants.
+func getVersionCodes(variant string, language websiteLanguage) []string {
+	letter := language.versionLetter
+	mainCodes := []string{"V" + letter, "VO" + letter, "VOST" + letter, "V" + letter + "-ST" + letter}
+	switch variant {
+	case internal.VariantSubtitled:
+		return append([]string{"V" + letter + "-ST" + letter, "VOST" + letter, "V" + letter + "-STM" + letter}, mainCodes...)
+	case internal.VariantOriginalVersion:
+		return append([]string{"VO" + letter, "VOST" + letter, "VOA", "VOF", "VO"}, mainCodes...)
+	case internal.VariantAudioDescription:
+		return append([]string{"V" + letter + "AUD"}, mainCodes...)
+	}
+	return mainCodes
+}
+
+// selectVersion yields the streams of the first version code that has streams. If there is none, all streams are
+// yielded, so the episode is available in any version.
+func selectVersion(streams []arteapi.Stream, versionCodes []string) []arteapi.Stream {
+	for _, code := range versionCodes {
+		selected := make([]arteapi.Stream, 0)
+		for _, stream := range streams {
+			if stream.GetVersionCode() == code {
+				selected = append(selected, stream)
+			}
+		}
+		if len(selected) > 0 {
+			return selected
+		}
+	}
+	return streams
+}
+
+func createCandidates(streams []arteapi.Stream) []streamselect.Candidate {
+	candidates := make([]streamselect.Candidate, 0, len(streams))
+	for _, stream := range streams {
+		dimensions := dimensionsByQuality[stream.MainQuality.Code]
+		candidate := streamselect.Candidate{
+			URL:    stream.URL,
+			Width:  dimensions.width,
+			Height: dimensions.height,
+			Rank:   dimensions.rank,
+		}
+		if strings.HasPrefix(stream.Protocol, "HLS") {
+			candidate.MimeType = manifest.HLSMimeType
+			candidate.Container = "m3u8"
+			candidate.IsAdaptive = true
+		} else {
+			candidate.MimeType = internal.VideoMimeType
+			candidate.Container = "mp4"
+			candidate.Codec = "h264"
+		}
+		if matches := bitrateRegex.FindStringSubmatch(stream.URL); matches != nil {
+			candidate.Bitrate, _ = strconv.Atoi(matches[1])
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// getGeoRegion yields the geo region of the restrictions for a geoblocking code of the arte API, e.g. de_fr for DE_FR.
+func getGeoRegion(geoblockingCode string) string {
+	if region, found := geoRegionByGeoblocking[geoblockingCode]; found {
+		return region
+	}
+	return strings.ToLower(geoblockingCode)
+}
+
+// createImage yields the first image in the requested width and the aspect ratio 16:9.
+func createImage(images []arteapi.Image, requestedWidth int) internal.Image {
+	if requestedWidth <= 0 {
+		requestedWidth = defaultImageWidth
+	}
+	size := fmt.Sprintf("%vx%v", requestedWidth, requestedWidth*9/16)
+	image := internal.Image{URL: arteapi.GetImageURL(images, size)}
+	if len(images) > 0 {
+		image.Title = images[0].Caption
+	}
+	return image
+}
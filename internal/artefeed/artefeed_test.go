@@ -0,0 +1,211 @@
+package artefeed
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seiferma/docker_mediathek2rss/internal"
+	"github.com/seiferma/docker_mediathek2rss/internal/arteapi"
+	"github.com/seiferma/docker_mediathek2rss/internal/provider"
+	"github.com/seiferma/docker_mediathek2rss/internal/rssfeed"
+)
+
+// testNow is the current time during the tests, which is the build date of the feeds.
+var testNow = time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+var defaultParameters = internal.RequestParameters{
+	Width: 1280,
+}
+
+var urlToFilename = map[string](string){
+	"https://www.arte.tv/api/rproxy/emac/v4/de/web/pages/COLLECTION/RC-014095/": "arte-collection-RC-014095.json",
+	"https://api.arte.tv/api/player/v2/config/de/098765-001-A":                  "arte-program-098765-001-A.json",
+	"https://api.arte.tv/api/player/v2/config/de/098765-002-A":                  "arte-program-098765-002-A.json",
+}
+
+func TestCreateRssFeedValid(t *testing.T) {
+	result, err := createRssFeedMocked(routeCollection, "RC-014095", defaultParameters)
+	if err != nil {
+		t.Fatalf("There should not be an error.\n%v", err)
+	}
+	if report := rssfeed.Validate([]byte(result)); !report.IsValid() {
+		t.Fatalf("The created feed is not valid.\n%v", report)
+	}
+	expectedBytes, err := ioutil.ReadFile("../testdata/arte-collection-RC-014095.xml")
+	expected := string(expectedBytes)
+
+	if strings.Compare(result, expected) != 0 {
+		// ioutil.WriteFile("/tmp/actual.xml", []byte(result), 0644)
+		// ioutil.WriteFile("/tmp/expected.xml", []byte(expected), 0644)
+		t.Fatalf("The created XML is not as expected. Created:\n%v", result)
+	}
+}
+
+func TestCreateRssFeedOfProgram(t *testing.T) {
+	result, err := createRssFeedMocked(routeProgram, "098765-002-A", defaultParameters)
+	if err != nil {
+		t.Fatalf("There should not be an error.\n%v", err)
+	}
+	assertEquals(t, 1, strings.Count(result, "<item>"))
+	assertEquals(t, true, strings.Contains(result, "<title>Europas Geschichte - Das Mittelalter</title>"))
+	assertEquals(t, false, strings.Contains(result, "<itunes:episode>"))
+
+	_, err = createRssFeedMocked(routeProgram, "098765-003-A", defaultParameters)
+	assertEquals(t, true, err != nil)
+}
+
+func TestCreateRssFeedConsidersAvailability(t *testing.T) {
+	parameters := defaultParameters
+	parameters.Language = "fra"
+	_, err := createRssFeedMocked(routeCollection, "RC-014095", parameters)
+	assertEquals(t, true, err != nil)
+
+	parameters = defaultParameters
+	parameters.ExcludedGeoRegions = []string{internal.AllGeoRegions}
+	result, _ := createRssFeedMocked(routeCollection, "RC-014095", parameters)
+	assertEquals(t, 1, strings.Count(result, "<item>"))
+	assertEquals(t, true, strings.Contains(result, "Das Mittelalter"))
+}
+
+func TestCreateRssFeedExcludesGeoRegionDeFr(t *testing.T) {
+	parameters, err := internal.ParseRequestParametersFromURL(&url.URL{RawQuery: "width=1280&excludeGeo=de_fr"})
+	assertEquals(t, nil, err)
+	result, _ := createRssFeedMocked(routeCollection, "RC-014095", parameters)
+	assertEquals(t, 1, strings.Count(result, "<item>"))
+	assertEquals(t, false, strings.Contains(result, "Die Antike"))
+	assertEquals(t, true, strings.Contains(result, "Das Mittelalter"))
+}
+
+func TestCreateRssFeedLoadsDetailsOfIncludedEpisodesOnly(t *testing.T) {
+	parameters := defaultParameters
+	parameters.Exclude = regexp.MustCompile("Antike")
+	requestedURLs, result := createRssFeedRecordingRequests(parameters)
+	assertEquals(t, 1, strings.Count(result, "<item>"))
+	assertEquals(t, "https://www.arte.tv/api/rproxy/emac/v4/de/web/pages/COLLECTION/RC-014095/ https://api.arte.tv/api/player/v2/config/de/098765-002-A", strings.Join(requestedURLs, " "))
+
+	parameters = defaultParameters
+	parameters.Limit = 1
+	requestedURLs, result = createRssFeedRecordingRequests(parameters)
+	assertEquals(t, 1, strings.Count(result, "<item>"))
+	assertEquals(t, 2, len(requestedURLs))
+}
+
+func TestIsValidShowID(t *testing.T) {
+	arteProvider := CreateProvider(10)
+	assertEquals(t, true, arteProvider.IsValidShowID(routeCollection, "RC-014095"))
+	assertEquals(t, false, arteProvider.IsValidShowID(routeCollection, "098765-001-A"))
+	assertEquals(t, true, arteProvider.IsValidShowID(routeProgram, "098765-001-A"))
+	assertEquals(t, false, arteProvider.IsValidShowID(routeProgram, "RC-014095"))
+	assertEquals(t, false, arteProvider.IsValidShowID(routeProgram, "../098765-001-A"))
+}
+
+func TestSelectVersion(t *testing.T) {
+	api := createTestAPI()
+	program, _ := api.GetProgram("de", "098765-001-A")
+	german := websiteLanguages["deu"]
+	french := websiteLanguages["fra"]
+
+	assertVersion(t, &program, internal.VariantMain, german, "VA")
+	assertVersion(t, &program, internal.VariantSubtitled, german, "VA-STA")
+	assertVersion(t, &program, internal.VariantOriginalVersion, german, "VOSTA")
+	assertVersion(t, &program, internal.VariantAudioDescription, german, "VA")
+	assertVersion(t, &program, internal.VariantOriginalVersion, french, "VOF")
+	assertVersion(t, &program, internal.VariantSubtitled, french, "VOF")
+
+	streams := selectVersion(program.Streams, []string{"VE"})
+	assertEquals(t, len(program.Streams), len(streams))
+}
+
+func TestResolveMediaSelectsQuality(t *testing.T) {
+	api := createTestAPI()
+	program, _ := api.GetProgram("de", "098765-001-A")
+	show := internal.Show{Details: &arteShow{language: defaultWebsiteLanguage}}
+	episode := internal.Episode{Details: &arteEpisode{programID: "098765-001-A", program: &program}}
+
+	parameters := internal.RequestParameters{Width: 720}
+	(&Provider{}).ResolveMedia(&show, &episode, parameters, &internal.MediaServices{})
+	assertEquals(t, "https://arteptweb-a.akamaihd.net/am/ptweb/098000/098700/098765-001-A_EQ_0_VA_06461463_MP4-1500_AMM-PTWEB_1ApH0cR9w.mp4", episode.Media.URL)
+	assertEquals(t, internal.VideoMimeType, episode.Media.MimeType)
+
+	episode.Details = &arteEpisode{program: &arteapi.Program{}}
+	err := (&Provider{}).ResolveMedia(&show, &episode, parameters, &internal.MediaServices{})
+	assertEquals(t, true, err != nil)
+}
+
+func TestLookupShowStatesWebsiteLanguage(t *testing.T) {
+	fnCreateAPI := func() arteapi.ArteAPI {
+		return arteapi.CreateArteAPIWithGetFunc(10, func(URL string) ([]byte, error) {
+			return getTestFile(strings.Replace(URL, "/fr/", "/de/", 1))
+		})
+	}
+	arteProvider := &Provider{fnCreateAPI: fnCreateAPI}
+	show, err := arteProvider.LookupShow(routeCollection, "RC-014095", internal.RequestParameters{Language: "fra"})
+	if err != nil {
+		t.Fatalf("There should not be an error.\n%v", err)
+	}
+	assertEquals(t, "fr", show.Language)
+
+	result, _ := provider.CreateFeed(arteProvider, routeCollection, "RC-014095", "", internal.RequestParameters{Language: "fra"}, &internal.MediaServices{}, testNow)
+	assertEquals(t, true, strings.Contains(result, "<language>fr</language>"))
+}
+
+func TestGetWebsiteLanguage(t *testing.T) {
+	assertEquals(t, "de", getWebsiteLanguage(internal.RequestParameters{}).code)
+	assertEquals(t, "fr", getWebsiteLanguage(internal.RequestParameters{Language: "fra"}).code)
+	assertEquals(t, "de", getWebsiteLanguage(internal.RequestParameters{Language: "eng"}).code)
+}
+
+func TestGetGeoRegion(t *testing.T) {
+	assertEquals(t, "none", getGeoRegion("ALL"))
+	assertEquals(t, "ebu", getGeoRegion("EUR_DE_FR"))
+	assertEquals(t, "de_fr", getGeoRegion("DE_FR"))
+}
+
+func assertVersion(t *testing.T, program *arteapi.Program, variant string, language websiteLanguage, expectedCode string) {
+	streams := selectVersion(program.Streams, getVersionCodes(variant, language))
+	assertEquals(t, expectedCode, streams[0].GetVersionCode())
+}
+
+func createTestAPI() arteapi.ArteAPI {
+	return arteapi.CreateArteAPIWithGetFunc(10, getTestFile)
+}
+
+func getTestFile(URL string) (result []byte, err error) {
+	filename, ok := urlToFilename[URL]
+	if !ok {
+		err = errors.New("unknown URL")
+		return
+	}
+	result, err = ioutil.ReadFile("../testdata/" + filename)
+	return
+}
+
+// createRssFeedRecordingRequests creates the feed of the test collection and yields the requested URLs in order.
+func createRssFeedRecordingRequests(parameters internal.RequestParameters) (requestedURLs []string, result string) {
+	fnCreateAPI := func() arteapi.ArteAPI {
+		return arteapi.CreateArteAPIWithGetFunc(10, func(URL string) ([]byte, error) {
+			requestedURLs = append(requestedURLs, URL)
+			return getTestFile(URL)
+		})
+	}
+	arteProvider := &Provider{fnCreateAPI: fnCreateAPI}
+	result, _ = provider.CreateFeed(arteProvider, routeCollection, "RC-014095", "", parameters, &internal.MediaServices{}, testNow)
+	return
+}
+
+func createRssFeedMocked(route, showID string, parameters internal.RequestParameters) (result string, err error) {
+	arteProvider := &Provider{fnCreateAPI: createTestAPI}
+	result, err = provider.CreateFeed(arteProvider, route, showID, "", parameters, &internal.MediaServices{}, testNow)
+	return
+}
+
+func assertEquals(t *testing.T, expected, actual interface{}) {
+	if expected != actual {
+		t.Fatalf("Expected %v but got %v.", expected, actual)
+	}
+}
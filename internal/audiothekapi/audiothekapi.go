@@ -0,0 +1,173 @@
+package audiothekapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+)
+
+const apiBaseURL = "https://api.ardaudiothek.de"
+
+// AudiothekAPI gives access to the programme sets and editorial collections of the ARD Audiothek API.
+// Its main purpose is to hold configuration parameters and provide them to the API functions.
+type AudiothekAPI struct {
+	maxEpisodes  int
+	fnGetRequest func(string) ([]byte, error)
+}
+
+// Image represents an image DTO from the API. The URL contains the placeholder {width} for the requested width.
+type Image struct {
+	URL    string `json:"url"`
+	URL1X1 string `json:"url1X1"` // the square version of the image, which podcast clients expect as artwork
+	Title  string `json:"title"`
+	Alt    string `json:"alt"`
+}
+
+// Audio represents a DTO for an audio file of an episode.
+type Audio struct {
+	URL         string `json:"url"`
+	DownloadURL string `json:"downloadUrl"` // empty if the audio file may not be downloaded
+	MimeType    string `json:"mimeType"`
+}
+
+// Item represents a DTO for an episode of a programme set or an editorial collection.
+type Item struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Synopsis    string    `json:"synopsis"`
+	Duration    int       `json:"duration"` // the duration in seconds
+	PublishDate time.Time `json:"publishDate"`
+	EndDate     time.Time `json:"endDate"` // zero if the episode is available without time limit
+	SharingURL  string    `json:"sharingUrl"`
+	Image       Image     `json:"image"`
+	Audios      []Audio   `json:"audios"`
+	ProgramSet  struct {
+		Title              string `json:"title"`
+		PublicationService struct {
+			Title string `json:"title"`
+		} `json:"publicationService"`
+	} `json:"programSet"`
+}
+
+// Collection represents a DTO for a programme set or an editorial collection, which both consist of items.
+type Collection struct {
+	ID                 string `json:"id"`
+	Title              string `json:"title"`
+	Synopsis           string `json:"synopsis"`
+	SharingURL         string `json:"sharingUrl"`
+	Image              Image  `json:"image"`
+	PublicationService struct {
+		Title string `json:"title"`
+	} `json:"publicationService"` // empty for editorial collections, which combine items of several channels
+	Items struct {
+		Nodes []Item `json:"nodes"`
+	} `json:"items"`
+}
+
+type collectionResponse struct {
+	Data struct {
+		ProgramSet          *Collection `json:"programSet"`
+		EditorialCollection *Collection `json:"editorialCollection"`
+	} `json:"data"`
+}
+
+// CreateAudiothekAPI creates a new API instance taking configuration values to be considered when working with the API.
+// The maxEpisodes parameter defines how many episodes of a collection shall be received at most.
+func CreateAudiothekAPI(maxEpisodes int) AudiothekAPI {
+	return CreateAudiothekAPIWithGetFunc(maxEpisodes, doGetRequest)
+}
+
+// CreateAudiothekAPIWithGetFunc creates a new API instance taking configuration values to be considered when working
+// with the API. The maxEpisodes parameter defines how many episodes of a collection shall be received at most.
+// The fnGetRequest parameter provides a function that carries out a get request and provides the body as byte array.
+func CreateAudiothekAPIWithGetFunc(maxEpisodes int, fnGetRequest func(string) ([]byte, error)) AudiothekAPI {
+	return AudiothekAPI{
+		maxEpisodes:  maxEpisodes,
+		fnGetRequest: fnGetRequest,
+	}
+}
+
+// GetProgramSet retrieves a programme set, i.e. a radio show, with its latest episodes by the given ID.
+func (api *AudiothekAPI) GetProgramSet(programSetID string) (result Collection, err error) {
+	var response collectionResponse
+	response, err = api.getCollection("programsets", programSetID)
+	if err != nil {
+		return
+	}
+	if response.Data.ProgramSet == nil {
+		err = fmt.Errorf("there is no programme set with ID %v", programSetID)
+		return
+	}
+	result = *response.Data.ProgramSet
+	return
+}
+
+// GetEditorialCollection retrieves an editorial collection, i.e. episodes of several radio shows compiled by the
+// editors of the Audiothek, by the given ID.
+func (api *AudiothekAPI) GetEditorialCollection(collectionID string) (result Collection, err error) {
+	var response collectionResponse
+	response, err = api.getCollection("editorialcollections", collectionID)
+	if err != nil {
+		return
+	}
+	if response.Data.EditorialCollection == nil {
+		err = fmt.Errorf("there is no editorial collection with ID %v", collectionID)
+		return
+	}
+	result = *response.Data.EditorialCollection
+	return
+}
+
+func (api *AudiothekAPI) getCollection(kind, ID string) (result collectionResponse, err error) {
+	collectionURL := fmt.Sprintf("%v/%v/%v?offset=0&limit=%v", apiBaseURL, kind, ID, api.maxEpisodes)
+	var body []byte
+	body, err = api.fnGetRequest(collectionURL)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		log.Printf("Could not parse JSON body for request to URL %v. %v", collectionURL, err)
+	}
+	return
+}
+
+// GetBestAudio yields the audio file of an item that suits podcast clients best, which is the download version
+// if there is one. It yields an error if the item has no audio files.
+func (item *Item) GetBestAudio() (URL, mimeType string, err error) {
+	if len(item.Audios) == 0 {
+		err = errors.New("no audio files")
+		return
+	}
+	for _, audio := range item.Audios {
+		if audio.DownloadURL != "" {
+			return audio.DownloadURL, audio.MimeType, nil
+		}
+	}
+	return item.Audios[0].URL, item.Audios[0].MimeType, nil
+}
+
+func doGetRequest(URL string) (result []byte, err error) {
+	var resp *http.Response
+	resp, err = http.Get(URL)
+	if err != nil {
+		log.Printf("Received error for URL %v: %v", URL, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("received status %v for URL %v", resp.StatusCode, URL)
+		return
+	}
+
+	result, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Printf("Could not read body from GET request to URL %v.", URL)
+		return
+	}
+	return
+}
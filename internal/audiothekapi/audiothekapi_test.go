@@ -0,0 +1,88 @@
+package audiothekapi
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func createTestAPI(t *testing.T, expectedURL, filename string) AudiothekAPI {
+	fnGet := func(url string) (result []byte, err error) {
+		if url != expectedURL {
+			t.Fatalf("Expected request to %v but got %v.", expectedURL, url)
+		}
+		return ioutil.ReadFile("../testdata/" + filename)
+	}
+	return CreateAudiothekAPIWithGetFunc(3, fnGet)
+}
+
+func TestGetProgramSet(t *testing.T) {
+	api := createTestAPI(t, "https://api.ardaudiothek.de/programsets/7852180?offset=0&limit=3", "audiothek-programset.json")
+	result, err := api.GetProgramSet("7852180")
+	if err != nil {
+		t.Fatalf("There should be no error reported.\n%v", err)
+	}
+
+	assertEquals(t, "Eine Stunde History", result.Title)
+	assertEquals(t, "Deutschlandfunk Nova", result.PublicationService.Title)
+	assertEquals(t, 3, len(result.Items.Nodes))
+	item := result.Items.Nodes[0]
+	assertEquals(t, "84213966", item.ID)
+	assertEquals(t, 3418, item.Duration)
+	assertEquals(t, time.Date(2020, 12, 18, 9, 0, 0, 0, time.UTC).Unix(), item.PublishDate.Unix())
+	assertEquals(t, true, item.EndDate.IsZero())
+	assertEquals(t, time.Date(2020, 12, 31, 9, 0, 0, 0, time.UTC).Unix(), result.Items.Nodes[2].EndDate.Unix())
+}
+
+func TestGetProgramSetOfOtherKind(t *testing.T) {
+	api := createTestAPI(t, "https://api.ardaudiothek.de/programsets/94553610?offset=0&limit=3", "audiothek-editorialcollection.json")
+	if _, err := api.GetProgramSet("94553610"); err == nil {
+		t.Fatal("There should be an error.")
+	}
+}
+
+func TestGetProgramSetWithFailingRequest(t *testing.T) {
+	api := CreateAudiothekAPIWithGetFunc(3, func(url string) ([]byte, error) {
+		return nil, errors.New("not found")
+	})
+	if _, err := api.GetProgramSet("7852180"); err == nil {
+		t.Fatal("There should be an error.")
+	}
+}
+
+func TestGetEditorialCollection(t *testing.T) {
+	api := createTestAPI(t, "https://api.ardaudiothek.de/editorialcollections/94553610?offset=0&limit=3", "audiothek-editorialcollection.json")
+	result, err := api.GetEditorialCollection("94553610")
+	if err != nil {
+		t.Fatalf("There should be no error reported.\n%v", err)
+	}
+
+	assertEquals(t, "Hörspiele zum Wochenende", result.Title)
+	assertEquals(t, "", result.PublicationService.Title)
+	assertEquals(t, 2, len(result.Items.Nodes))
+	assertEquals(t, "SWR2", result.Items.Nodes[0].ProgramSet.PublicationService.Title)
+}
+
+func TestGetBestAudio(t *testing.T) {
+	api := createTestAPI(t, "https://api.ardaudiothek.de/programsets/7852180?offset=0&limit=3", "audiothek-programset.json")
+	result, _ := api.GetProgramSet("7852180")
+
+	URL, mimeType, err := result.Items.Nodes[0].GetBestAudio()
+	assertEquals(t, nil, err)
+	assertEquals(t, "https://download.deutschlandfunk.de/file/dradio/2020/12/18/der_mauerfall_dlf_20201218_1000_8f1e7a2c.mp3", URL)
+	assertEquals(t, "audio/mpeg", mimeType)
+
+	URL, mimeType, _ = result.Items.Nodes[1].GetBestAudio()
+	assertEquals(t, "https://dradio-edge-209b-fra-lg-cdn.cast.addradio.de/dradio/nova/sendungen/history/die_hanse.m4a", URL)
+	assertEquals(t, "", mimeType)
+
+	_, _, err = (&Item{}).GetBestAudio()
+	assertEquals(t, true, err != nil)
+}
+
+func assertEquals(t *testing.T, expected, actual interface{}) {
+	if expected != actual {
+		t.Fatalf("Expected \"%v\" but got \"%v\".", expected, actual)
+	}
+}
@@ -0,0 +1,149 @@
+package audiothekfeed
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/seiferma/docker_mediathek2rss/internal"
+	"github.com/seiferma/docker_mediathek2rss/internal/audiothekapi"
+)
+
+// defaultAuthor is the author of editorial collections, which combine episodes of several radio channels.
+const defaultAuthor = "ARD Audiothek"
+
+// iTunesCategory is the category of the Apple Podcasts directory for radio shows, which cover all kinds of topics.
+const iTunesCategory = "Society & Culture"
+
+// defaultImageWidth is the width of images if no width is requested, which is the minimum size of podcast artwork.
+const defaultImageWidth = 1400
+
+// defaultAudioMimeType is the MIME type of audio files whose type is neither given by the API nor by their URL.
+// The Audiothek serves MP3 files in this case.
+const defaultAudioMimeType = "audio/mpeg"
+
+// routes of the Audiothek
+const (
+	routeProgramSet = "programSet"
+	routeCollection = "collection"
+)
+
+var collectionIDRegex = regexp.MustCompile("^[a-zA-Z0-9:_-]+$")
+
+// Provider offers the programme sets and the editorial collections of the ARD Audiothek as audio podcasts.
+type Provider struct {
+	fnCreateAPI func() audiothekapi.AudiothekAPI
+}
+
+// CreateProvider creates the provider of the ARD Audiothek, which lists up to maxEpisodes episodes per show.
+func CreateProvider(maxEpisodes int) *Provider {
+	return &Provider{
+		fnCreateAPI: func() audiothekapi.AudiothekAPI {
+			return audiothekapi.CreateAudiothekAPI(maxEpisodes)
+		},
+	}
+}
+
+// ID yields audiothek.
+func (audiothekProvider *Provider) ID() string {
+	return "audiothek"
+}
+
+// Routes yields programSet and collection, which serve radio shows at /audiothek/show/programSet/{ID} and editorial
+// collections at /audiothek/show/collection/{ID}.
+func (audiothekProvider *Provider) Routes() []string {
+	return []string{routeProgramSet, routeCollection}
+}
+
+// IsValidShowID checks if the ID consists of characters of IDs of the Audiothek like 7852180.
+func (audiothekProvider *Provider) IsValidShowID(route, showID string) bool {
+	return collectionIDRegex.MatchString(showID)
+}
+
+// LookupShow yields the programme set or the editorial collection with the given ID including its episodes.
+func (audiothekProvider *Provider) LookupShow(route, showID string, parameters internal.RequestParameters) (show internal.Show, err error) {
+	api := audiothekProvider.fnCreateAPI()
+	var collection audiothekapi.Collection
+	if route == routeCollection {
+		collection, err = api.GetEditorialCollection(showID)
+	} else {
+		collection, err = api.GetProgramSet(showID)
+	}
+	if err != nil {
+		return
+	}
+
+	author := collection.PublicationService.Title
+	if author == "" {
+		author = defaultAuthor
+	}
+	show = internal.Show{
+		Title:       collection.Title,
+		Description: collection.Synopsis,
+		Link:        collection.SharingURL,
+		Image:       internal.Image{URL: getImageURL(&collection.Image, parameters.Width), Title: collection.Title},
+		Author:      author,
+		Category:    iTunesCategory,
+		Details:     &collection,
+	}
+	return
+}
+
+// ListEpisodes yields the episodes of the programme set or the editorial collection from newest to oldest.
+func (audiothekProvider *Provider) ListEpisodes(show *internal.Show, parameters internal.RequestParameters, fnVisit func(internal.Episode) bool) error {
+	collection := show.Details.(*audiothekapi.Collection)
+	for i := range collection.Items.Nodes {
+		item := &collection.Items.Nodes[i]
+		episode := internal.Episode{
+			ID:                item.ID,
+			Title:             item.Title,
+			Description:       item.Synopsis,
+			Link:              item.SharingURL,
+			Image:             internal.Image{URL: getImageURL(&item.Image, parameters.Width), Title: item.Image.Alt},
+			Date:              item.PublishDate,
+			DurationInSeconds: item.Duration,
+			AvailableTo:       item.EndDate,
+			Details:           item,
+		}
+		if !fnVisit(episode) {
+			break
+		}
+	}
+	return nil
+}
+
+// ResolveMedia selects the audio file of an episode. The Audiothek offers a single audio file per episode, so there
+// is no choice of quality.
+func (audiothekProvider *Provider) ResolveMedia(show *internal.Show, episode *internal.Episode, parameters internal.RequestParameters, services *internal.MediaServices) (err error) {
+	URL, mimeType, err := episode.Details.(*audiothekapi.Item).GetBestAudio()
+	if err != nil {
+		return
+	}
+	episode.Media = &internal.MediaVariant{URL: URL, MimeType: getAudioMimeType(URL, mimeType)}
+	return
+}
+
+// getAudioMimeType yields the MIME type of an audio file. Types of the API that do not describe audio are replaced by
+// the type belonging to the extension of the file.
+func getAudioMimeType(URL, mimeType string) string {
+	if internal.IsAudioMimeType(mimeType) {
+		return mimeType
+	}
+	if mimeTypeOfURL, found := internal.GetAudioMimeTypeFromURL(URL); found {
+		return mimeTypeOfURL
+	}
+	return defaultAudioMimeType
+}
+
+// getImageURL yields the URL of the square version of an image if there is one, because podcast clients expect
+// square artwork. The image has the requested width or the minimum width of podcast artwork.
+func getImageURL(image *audiothekapi.Image, requestedWidth int) string {
+	URL := image.URL1X1
+	if URL == "" {
+		URL = image.URL
+	}
+	if requestedWidth <= 0 {
+		requestedWidth = defaultImageWidth
+	}
+	return strings.Replace(URL, "{width}", strconv.Itoa(requestedWidth), -1)
+}
@@ -0,0 +1,107 @@
+package audiothekfeed
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seiferma/docker_mediathek2rss/internal"
+	"github.com/seiferma/docker_mediathek2rss/internal/audiothekapi"
+	"github.com/seiferma/docker_mediathek2rss/internal/provider"
+	"github.com/seiferma/docker_mediathek2rss/internal/rssfeed"
+)
+
+// testNow is the current time during the tests, which is the build date of the feeds.
+var testNow = time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+var urlToFilename = map[string](string){
+	"https://api.ardaudiothek.de/programsets/7852180?offset=0&limit=3":           "audiothek-programset.json",
+	"https://api.ardaudiothek.de/editorialcollections/94553610?offset=0&limit=3": "audiothek-editorialcollection.json",
+}
+
+func TestCreateRssFeedValid(t *testing.T) {
+	result, err := createRssFeedMocked("programSet", "7852180", internal.RequestParameters{})
+	if err != nil {
+		t.Fatalf("There should not be an error.\n%v", err)
+	}
+	if report := rssfeed.Validate([]byte(result)); !report.IsValid() {
+		t.Fatalf("The created feed is not valid.\n%v", report)
+	}
+	expectedBytes, err := ioutil.ReadFile("../testdata/audiothek-programset.xml")
+	expected := string(expectedBytes)
+
+	if strings.Compare(result, expected) != 0 {
+		// ioutil.WriteFile("/tmp/actual.xml", []byte(result), 0644)
+		// ioutil.WriteFile("/tmp/expected.xml", []byte(expected), 0644)
+		t.Fatalf("The created XML is not as expected. Created:\n%v", result)
+	}
+}
+
+func TestCreateRssFeedOfCollection(t *testing.T) {
+	result, err := createRssFeedMocked("collection", "94553610", internal.RequestParameters{OnMissingMedia: internal.MissingMediaLink})
+	if err != nil {
+		t.Fatalf("There should not be an error.\n%v", err)
+	}
+	assertEquals(t, 2, strings.Count(result, "<item>"))
+	assertEquals(t, 1, strings.Count(result, "<enclosure"))
+	assertEquals(t, true, strings.Contains(result, "<itunes:author>ARD Audiothek</itunes:author>"))
+	assertEquals(t, true, strings.Contains(result, `<enclosure url="https://avdlswr-a.akamaihd.net/swr/swr2/hoerspiel/der-prozess-1.m.mp3" type="audio/mpeg" length="0"></enclosure>`))
+}
+
+func TestCreateRssFeedOfUnknownShow(t *testing.T) {
+	if _, err := createRssFeedMocked("programSet", "94553610", internal.RequestParameters{}); err == nil {
+		t.Fatal("There should be an error.")
+	}
+	if _, err := createRssFeedMocked("collection", "7852180", internal.RequestParameters{}); err == nil {
+		t.Fatal("There should be an error.")
+	}
+}
+
+func TestIsValidShowID(t *testing.T) {
+	audiothekProvider := CreateProvider(3)
+	assertEquals(t, true, audiothekProvider.IsValidShowID(routeProgramSet, "7852180"))
+	assertEquals(t, true, audiothekProvider.IsValidShowID(routeCollection, "urn:ard:page:4a5c8b05e7b2d1a9"))
+	assertEquals(t, false, audiothekProvider.IsValidShowID(routeProgramSet, "../7852180"))
+	assertEquals(t, false, audiothekProvider.IsValidShowID(routeProgramSet, ""))
+}
+
+func TestGetAudioMimeType(t *testing.T) {
+	assertEquals(t, "audio/mpeg", getAudioMimeType("https://foo.bar/episode.m4a", "audio/mpeg"))
+	assertEquals(t, "audio/mp4", getAudioMimeType("https://foo.bar/episode.m4a", ""))
+	assertEquals(t, "audio/mp4", getAudioMimeType("https://foo.bar/episode.m4a", "application/octet-stream"))
+	assertEquals(t, "audio/mpeg", getAudioMimeType("https://foo.bar/episode", ""))
+}
+
+func TestGetImageURL(t *testing.T) {
+	image := audiothekapi.Image{URL: "https://foo.bar/image?w={width}", URL1X1: "https://foo.bar/image?w={width}&ar=1x1"}
+	assertEquals(t, "https://foo.bar/image?w=1400&ar=1x1", getImageURL(&image, 0))
+	image.URL1X1 = ""
+	assertEquals(t, "https://foo.bar/image?w=720", getImageURL(&image, 720))
+}
+
+func createRssFeedMocked(route, showID string, parameters internal.RequestParameters) (result string, err error) {
+	fnGetHTTP := func(URL string) (result []byte, err error) {
+		filename, ok := urlToFilename[URL]
+		if !ok {
+			err = errors.New("unknown URL")
+			return
+		}
+		result, err = ioutil.ReadFile("../testdata/" + filename)
+		return
+	}
+	audiothekProvider := &Provider{
+		fnCreateAPI: func() audiothekapi.AudiothekAPI {
+			return audiothekapi.CreateAudiothekAPIWithGetFunc(3, fnGetHTTP)
+		},
+	}
+	result, err = provider.CreateFeed(audiothekProvider, route, showID, "", parameters, &internal.MediaServices{}, testNow)
+	return
+}
+
+func assertEquals(t *testing.T, expected, actual interface{}) {
+	if expected != actual {
+		t.Fatalf("Expected %v but got %v.", expected, actual)
+	}
+}
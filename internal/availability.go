@@ -0,0 +1,34 @@
+package internal
+
+import (
+	"time"
+)
+
+const expiryTimeZone = "Europe/Berlin"
+const expiryFormat = "02.01.2006, 15:04"
+
+// IsAvailable determines if an episode is available at the given time. Zero times leave the corresponding end of
+// the availability open.
+func IsAvailable(availableFrom, availableTo, now time.Time) bool {
+	if !availableFrom.IsZero() && now.Before(availableFrom) {
+		return false
+	}
+	if !availableTo.IsZero() && !now.Before(availableTo) {
+		return false
+	}
+	return true
+}
+
+// AppendExpiryToDescription adds the end of the availability of an episode to its description, so listeners know
+// when the episode disappears. Nothing is added for episodes without time limit.
+func AppendExpiryToDescription(episode *Episode) {
+	availableTo := episode.AvailableTo
+	if availableTo.IsZero() {
+		return
+	}
+	location, err := time.LoadLocation(expiryTimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+	appendToDescription(episode, "Verfügbar bis "+availableTo.In(location).Format(expiryFormat)+" Uhr")
+}
@@ -0,0 +1,32 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsAvailable(t *testing.T) {
+	from := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	assertIsAvailable(t, true, from, to, from)
+	assertIsAvailable(t, false, from, to, from.Add(-time.Second))
+	assertIsAvailable(t, false, from, to, to)
+	assertIsAvailable(t, true, time.Time{}, to, from.AddDate(-10, 0, 0))
+	assertIsAvailable(t, true, from, time.Time{}, to.AddDate(10, 0, 0))
+}
+
+func TestAppendExpiryToDescription(t *testing.T) {
+	episode := Episode{Description: "Synopsis", AvailableTo: time.Date(2021, 3, 18, 22, 59, 0, 0, time.UTC)}
+	AppendExpiryToDescription(&episode)
+	assertEquals(t, "Synopsis\n\nVerfügbar bis 18.03.2021, 23:59 Uhr", episode.Description)
+
+	episode = Episode{Description: "Synopsis"}
+	AppendExpiryToDescription(&episode)
+	assertEquals(t, "Synopsis", episode.Description)
+}
+
+func assertIsAvailable(t *testing.T, expected bool, from, to, now time.Time) {
+	if IsAvailable(from, to, now) != expected {
+		t.Fatalf("Expected availability %v for %v between %v and %v.", expected, now, from, to)
+	}
+}
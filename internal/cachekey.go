@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// cacheKeyVersion is part of every cache key. Increase it whenever the feed output changes, so feeds that have been
+// cached by a previous version are not used anymore.
+const cacheKeyVersion = 4
+
+// CreateCacheKey yields the canonical cache key of the feed of a show of a provider such as ard or zdf.
+//
+// The key consists of the cache key version, the provider, the show and the request parameters in query notation.
+// Parameters are sorted by their name and parameters with default values are omitted, so equivalent requests
+// share a key independent of the layout of RequestParameters.
+func CreateCacheKey(provider, showID string, parameters RequestParameters) string {
+	return fmt.Sprintf("v%v/%v/%v?%v", cacheKeyVersion, provider, url.PathEscape(showID), parameters.canonicalQuery())
+}
+
+// canonicalQuery yields the request parameters that differ from their defaults in query notation.
+func (parameters RequestParameters) canonicalQuery() string {
+	query := url.Values{}
+	addInteger := func(name string, value, defaultValue int) {
+		if value != defaultValue {
+			query.Set(name, strconv.Itoa(value))
+		}
+	}
+	addString := func(name, value, defaultValue string) {
+		if value != defaultValue {
+			query.Set(name, value)
+		}
+	}
+
+	addInteger("width", parameters.Width, defaultMediaWidth)
+	addInteger("minLength", parameters.MinimumLengthInSeconds, defaultMinLengthInSeconds)
+	addInteger("maxLength", parameters.MaximumLengthInSeconds, 0)
+	if !parameters.Since.IsZero() {
+		query.Set("since", parameters.Since.Format(requestDateFormat))
+	}
+	if !parameters.Until.IsZero() {
+		query.Set("until", parameters.Until.Format(requestDateFormat))
+	}
+	addString("audio", strconv.FormatBool(parameters.AudioOnly), "false")
+	// the audio format only matters for audio feeds
+	if parameters.AudioOnly {
+		addString("audioFormat", parameters.AudioFormat, defaultAudioFormat)
+	}
+	addInteger("maxHeight", parameters.MaxHeight, 0)
+	addInteger("maxBitrate", parameters.MaxBitrate, 0)
+	addString("codec", parameters.Codec, "")
+	addString("prefer", parameters.Prefer, "")
+	addString("lang", parameters.Language, "")
+	addString("variant", parameters.Variant, VariantMain)
+	addString("onMissingMedia", parameters.OnMissingMedia, MissingMediaSkip)
+	addString("annotateExpiry", strconv.FormatBool(parameters.AnnotateExpiry), "false")
+	addString("excludeGeo", strings.Join(parameters.ExcludedGeoRegions, ","), "")
+	addInteger("maxFsk", parameters.MaxAgeRating, defaultMaxAgeRating)
+	if parameters.Include != nil {
+		query.Set("include", parameters.Include.String())
+	}
+	if parameters.Exclude != nil {
+		query.Set("exclude", parameters.Exclude.String())
+	}
+	addInteger("limit", parameters.Limit, 0)
+	addString("sort", parameters.Sort, "")
+	addString("serial", strconv.FormatBool(parameters.Serial), "false")
+	addInteger("season", parameters.Season, 0)
+	addString("format", parameters.Format, FormatRSS)
+	return query.Encode()
+}
@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestCreateCacheKey(t *testing.T) {
+	parameters := RequestParameters{
+		Width:                  42,
+		MinimumLengthInSeconds: 3,
+		AudioFormat:            defaultAudioFormat,
+		Variant:                VariantMain,
+		OnMissingMedia:         MissingMediaSkip,
+		MaxAgeRating:           defaultMaxAgeRating,
+		Format:                 FormatRSS,
+	}
+	assertEquals(t, "v4/ard/123?minLength=3&width=42", CreateCacheKey("ard", "123", parameters))
+}
+
+func TestCreateCacheKeyWithDefaultParameters(t *testing.T) {
+	URL, _ := url.Parse("https://localhost/zdf/show/byPath/comedy/zdf-magazin-royale")
+	parameters := CreateRequestParametersFromURL(URL)
+	assertEquals(t, "v4/zdf/comedy%2Fzdf-magazin-royale?", CreateCacheKey("zdf", "comedy/zdf-magazin-royale", parameters))
+}
+
+func TestCreateCacheKeyWithAllParameters(t *testing.T) {
+	parameters := RequestParameters{
+		Width:                  720,
+		MinimumLengthInSeconds: 60,
+		MaximumLengthInSeconds: 3600,
+		Since:                  time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		Until:                  time.Date(2021, 1, 31, 23, 59, 59, 0, time.UTC),
+		AudioOnly:              true,
+		AudioFormat:            "mp3",
+		MaxHeight:              576,
+		MaxBitrate:             2000,
+		Codec:                  "h264",
+		Prefer:                 "smallest",
+		Language:               "eng",
+		Variant:                VariantAudioDescription,
+		OnMissingMedia:         MissingMediaLink,
+		AnnotateExpiry:         true,
+		ExcludedGeoRegions:     []string{"dach", "de"},
+		MaxAgeRating:           12,
+		Include:                regexp.MustCompile("Folge"),
+		Exclude:                regexp.MustCompile("(?i)trailer"),
+		Limit:                  10,
+		Sort:                   SortByTitle,
+		Serial:                 true,
+		Season:                 3,
+		Format:                 FormatJSON,
+	}
+	assertEquals(t, "v4/ard/123?annotateExpiry=true&audio=true&audioFormat=mp3&codec=h264&exclude=%28%3Fi%29trailer&excludeGeo=dach%2Cde&format=json&include=Folge&lang=eng&limit=10&maxBitrate=2000&maxFsk=12&maxHeight=576&maxLength=3600&minLength=60&onMissingMedia=link&prefer=smallest&season=3&serial=true&since=2021-01-01&sort=title&until=2021-01-31&variant=ad&width=720",
+		CreateCacheKey("ard", "123", parameters))
+}
+
+func TestCreateCacheKeyIgnoresAudioFormatOfVideoFeeds(t *testing.T) {
+	URL, _ := url.Parse("https://localhost/ard/show/123?audioFormat=mp3")
+	assertEquals(t, "v4/ard/123?", CreateCacheKey("ard", "123", CreateRequestParametersFromURL(URL)))
+
+	URL, _ = url.Parse("https://localhost/ard/show/123?audio=1&audioFormat=mp3")
+	assertEquals(t, "v4/ard/123?audio=true&audioFormat=mp3", CreateCacheKey("ard", "123", CreateRequestParametersFromURL(URL)))
+}
+
+func TestCreateCacheKeyIsNamespacedByProvider(t *testing.T) {
+	parameters := RequestParameters{}
+	if CreateCacheKey("ard", "abc", parameters) == CreateCacheKey("zdf", "abc", parameters) {
+		t.Fatal("We expected different cache keys for different providers.")
+	}
+}
@@ -0,0 +1,17 @@
+package internal
+
+// prependToDescription adds a text in front of the description of an episode.
+func prependToDescription(episode *Episode, text string) {
+	if episode.Description != "" {
+		text += " "
+	}
+	episode.Description = text + episode.Description
+}
+
+// appendToDescription adds a paragraph to the description of an episode.
+func appendToDescription(episode *Episode, text string) {
+	if episode.Description != "" {
+		episode.Description += "\n\n"
+	}
+	episode.Description += text
+}
@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"regexp"
+)
+
+// IsExcluded determines if the episode shall be dropped according to the length, date, season and text filters of
+// the request parameters. The text filters match the title or the description.
+func (episode Episode) IsExcluded(parameters RequestParameters) bool {
+	if parameters.Season > 0 && episode.Season != parameters.Season {
+		return true
+	}
+	if episode.DurationInSeconds < parameters.MinimumLengthInSeconds {
+		return true
+	}
+	if parameters.MaximumLengthInSeconds > 0 && episode.DurationInSeconds > parameters.MaximumLengthInSeconds {
+		return true
+	}
+	if !parameters.Since.IsZero() && episode.Date.Before(parameters.Since) {
+		return true
+	}
+	if !parameters.Until.IsZero() && episode.Date.After(parameters.Until) {
+		return true
+	}
+	if parameters.Include != nil && !episode.matches(parameters.Include) {
+		return true
+	}
+	if parameters.Exclude != nil && episode.matches(parameters.Exclude) {
+		return true
+	}
+	return false
+}
+
+// IsExcludedBeforeDetails determines if the episode shall be dropped before its details such as the description are
+// known. Unknown values, i.e. zero values, never exclude the episode and the exclude filter only matches the title.
+// Episodes that are not excluded have to be checked by IsExcluded again once their details are known.
+func (episode Episode) IsExcludedBeforeDetails(parameters RequestParameters) bool {
+	if parameters.Season > 0 && episode.Season > 0 && episode.Season != parameters.Season {
+		return true
+	}
+	if episode.DurationInSeconds > 0 && episode.DurationInSeconds < parameters.MinimumLengthInSeconds {
+		return true
+	}
+	if parameters.MaximumLengthInSeconds > 0 && episode.DurationInSeconds > parameters.MaximumLengthInSeconds {
+		return true
+	}
+	if !episode.Date.IsZero() && !parameters.Since.IsZero() && episode.Date.Before(parameters.Since) {
+		return true
+	}
+	if !episode.Date.IsZero() && !parameters.Until.IsZero() && episode.Date.After(parameters.Until) {
+		return true
+	}
+	return parameters.Exclude != nil && parameters.Exclude.MatchString(episode.Title)
+}
+
+// IsLimitReached determines if a feed with the given number of items already contains the requested number of episodes.
+func IsLimitReached(numberOfItems int, parameters RequestParameters) bool {
+	return parameters.Limit > 0 && numberOfItems >= parameters.Limit
+}
+
+func (episode Episode) matches(pattern *regexp.Regexp) bool {
+	return pattern.MatchString(episode.Title) || pattern.MatchString(episode.Description)
+}
@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var testEpisode = Episode{
+	Title:             "Folge 12: Trailer",
+	Description:       "Die Vorschau auf die nächste Folge",
+	DurationInSeconds: 90,
+	Date:              time.Date(2021, 1, 15, 20, 15, 0, 0, time.UTC),
+}
+
+func TestEpisodeIsExcludedByLength(t *testing.T) {
+	assertEpisodeIsExcluded(t, false, RequestParameters{MinimumLengthInSeconds: 90, MaximumLengthInSeconds: 90})
+	assertEpisodeIsExcluded(t, true, RequestParameters{MinimumLengthInSeconds: 91})
+	assertEpisodeIsExcluded(t, true, RequestParameters{MaximumLengthInSeconds: 89})
+}
+
+func TestEpisodeIsExcludedByDate(t *testing.T) {
+	URL, _ := url.Parse("https://localhost/zdf/show?since=2021-01-15&until=2021-01-15")
+	assertEpisodeIsExcluded(t, false, CreateRequestParametersFromURL(URL))
+	URL, _ = url.Parse("https://localhost/zdf/show?since=2021-01-16")
+	assertEpisodeIsExcluded(t, true, CreateRequestParametersFromURL(URL))
+	URL, _ = url.Parse("https://localhost/zdf/show?until=2021-01-14")
+	assertEpisodeIsExcluded(t, true, CreateRequestParametersFromURL(URL))
+}
+
+func TestEpisodeIsExcludedBySeason(t *testing.T) {
+	seasonEpisode := testEpisode
+	seasonEpisode.Season = 2
+	if seasonEpisode.IsExcluded(RequestParameters{Season: 2}) || seasonEpisode.IsExcluded(RequestParameters{}) {
+		t.Fatal("We did not expect the episode of the requested season to be excluded.")
+	}
+	if !seasonEpisode.IsExcluded(RequestParameters{Season: 3}) || !testEpisode.IsExcluded(RequestParameters{Season: 2}) {
+		t.Fatal("We expected episodes of other or unknown seasons to be excluded.")
+	}
+}
+
+func TestEpisodeIsExcludedByText(t *testing.T) {
+	assertEpisodeIsExcluded(t, false, RequestParameters{Include: regexp.MustCompile("^Folge")})
+	assertEpisodeIsExcluded(t, false, RequestParameters{Include: regexp.MustCompile("Vorschau")})
+	assertEpisodeIsExcluded(t, true, RequestParameters{Include: regexp.MustCompile("Ganze Sendung")})
+	assertEpisodeIsExcluded(t, true, RequestParameters{Exclude: regexp.MustCompile("(?i)trailer|vorschau")})
+	assertEpisodeIsExcluded(t, false, RequestParameters{Exclude: regexp.MustCompile("Gebärdensprache")})
+}
+
+func TestEpisodeIsExcludedBeforeDetails(t *testing.T) {
+	teaser := Episode{Title: "Folge 12: Trailer", DurationInSeconds: 90}
+	assertEquals(t, "true", fmt.Sprint(teaser.IsExcludedBeforeDetails(RequestParameters{MinimumLengthInSeconds: 91})))
+	assertEquals(t, "true", fmt.Sprint(teaser.IsExcludedBeforeDetails(RequestParameters{Exclude: regexp.MustCompile("Trailer")})))
+	// the description, the date and the season are unknown
+	assertEquals(t, "false", fmt.Sprint(teaser.IsExcludedBeforeDetails(RequestParameters{Include: regexp.MustCompile("Vorschau")})))
+	assertEquals(t, "false", fmt.Sprint(teaser.IsExcludedBeforeDetails(RequestParameters{Since: time.Date(2021, 1, 16, 0, 0, 0, 0, time.UTC)})))
+	assertEquals(t, "false", fmt.Sprint(teaser.IsExcludedBeforeDetails(RequestParameters{Season: 2})))
+
+	teaser.Date = testEpisode.Date
+	teaser.Season = 1
+	assertEquals(t, "true", fmt.Sprint(teaser.IsExcludedBeforeDetails(RequestParameters{Since: time.Date(2021, 1, 16, 0, 0, 0, 0, time.UTC)})))
+	assertEquals(t, "true", fmt.Sprint(teaser.IsExcludedBeforeDetails(RequestParameters{Season: 2})))
+}
+
+func TestIsLimitReached(t *testing.T) {
+	if IsLimitReached(100, RequestParameters{}) || IsLimitReached(1, RequestParameters{Limit: 2}) {
+		t.Fatal("We did not expect the limit to be reached.")
+	}
+	if !IsLimitReached(2, RequestParameters{Limit: 2}) {
+		t.Fatal("We expected the limit to be reached.")
+	}
+}
+
+func TestCreateRequestParametersWithInvalidRegex(t *testing.T) {
+	URL, _ := url.Parse("https://localhost/zdf/show?include=(Folge&exclude=Trailer")
+	parameters := CreateRequestParametersFromURL(URL)
+	if parameters.Include != nil {
+		t.Fatal("We expected an invalid regular expression to be ignored.")
+	}
+	assertEquals(t, "Trailer", parameters.Exclude.String())
+}
+
+func assertEpisodeIsExcluded(t *testing.T, expected bool, parameters RequestParameters) {
+	if testEpisode.IsExcluded(parameters) != expected {
+		t.Fatalf("Expected the exclusion of the episode to be %v for %+v.", expected, parameters)
+	}
+}
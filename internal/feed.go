@@ -1,19 +1,31 @@
 package internal
 
 import (
-	"fmt"
 	"log"
+
+	"github.com/seiferma/docker_mediathek2rss/internal/rssfeed"
+)
+
+// Values of the feed validation mode
+const (
+	// ValidationOff serves and caches feeds without validation.
+	ValidationOff = "off"
+	// ValidationWarn logs the issues of invalid feeds, but serves and caches them nevertheless.
+	ValidationWarn = "warn"
+	// ValidationStrict logs the issues of invalid feeds and serves them without caching, so they are regenerated on
+	// the next request.
+	ValidationStrict = "strict"
 )
 
-// CreateRssFeedCached produces a RSS feed for a show.
-// It takes the identifier of the show as requested by the JSON API, request parameters, a pointer to the
-// cache and a function to dispatch the feed creation to. It yields the RSS feed as string and an error.
+// CreateRssFeedCached produces a feed for a show in the requested format.
+// It takes the name of the provider, the identifier of the show as requested by the JSON API, request parameters, a pointer to the
+// cache, the feed validation mode and a function to dispatch the feed creation to. It yields the RSS feed as string and an error.
 //
 // The requested width might not be met perfectly depending on the available media. However, the logic tries to get to the requested
 // width as close as possible.
-func CreateRssFeedCached(showIdentifier string, parameters RequestParameters, cache *Cache, fnCreate func(string, RequestParameters) (string, error)) (result string, err error) {
+func CreateRssFeedCached(provider, showIdentifier string, parameters RequestParameters, cache *Cache, validationMode string, fnCreate func(string, RequestParameters) (string, error)) (result string, err error) {
 	// directly return valid cache entry
-	cacheKey := getCacheKey(showIdentifier, parameters)
+	cacheKey := CreateCacheKey(provider, showIdentifier, parameters)
 	var foundCacheEntry bool
 	result, foundCacheEntry = cache.GetContent(cacheKey)
 	if foundCacheEntry {
@@ -25,12 +37,24 @@ func CreateRssFeedCached(showIdentifier string, parameters RequestParameters, ca
 	result, err = fnCreate(showIdentifier, parameters)
 
 	// cache result
-	if err == nil {
+	if err == nil && isCacheable(provider, showIdentifier, result, parameters.Format, validationMode) {
 		cache.StoreContent(cacheKey, result)
 	}
 	return
 }
 
-func getCacheKey(showID string, parameters RequestParameters) string {
-	return fmt.Sprintf("%v#%v", showID, parameters)
+// isCacheable validates a feed according to the validation mode and determines if the feed may be cached. Only RSS
+// feeds are validated, which is the format of requests without format parameter.
+func isCacheable(provider, showIdentifier, feed, format, validationMode string) bool {
+	if validationMode != ValidationWarn && validationMode != ValidationStrict {
+		return true
+	}
+	if format != "" && format != FormatRSS {
+		return true
+	}
+	report := rssfeed.Validate([]byte(feed))
+	for _, issue := range report.Issues {
+		log.Printf("Feed of %v show %v has an issue: %v", provider, showIdentifier, issue)
+	}
+	return report.IsValid() || validationMode != ValidationStrict
 }
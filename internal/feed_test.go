@@ -27,33 +27,59 @@ func TestCreateRssFeedCachedValid(t *testing.T) {
 	}
 
 	var result string
-	result, _ = CreateRssFeedCached(showID, parameters, &cache, fnCreate)
+	result, _ = CreateRssFeedCached("zdf", showID, parameters, &cache, ValidationOff, fnCreate)
 	assertEquals(t, "1", result)
-	result, _ = CreateRssFeedCached(showID, parameters, &cache, fnCreate)
+	result, _ = CreateRssFeedCached("zdf", showID, parameters, &cache, ValidationOff, fnCreate)
 	assertEquals(t, "1", result)
 	currentTime = currentTime.Add(cacheDuration + 1)
-	result, _ = CreateRssFeedCached(showID, parameters, &cache, fnCreate)
+	result, _ = CreateRssFeedCached("zdf", showID, parameters, &cache, ValidationOff, fnCreate)
 	assertEquals(t, "2", result)
 }
 
-func TestGetCacheKey(t *testing.T) {
-	parameters := RequestParameters{
-		Width:                  42,
-		MinimumLengthInSeconds: 3,
+func TestCreateRssFeedCachedSeparatesProviders(t *testing.T) {
+	cache := CreateCache(cacheDuration)
+	fnCreate := func(provider string) func(string, RequestParameters) (string, error) {
+		return func(s string, parameters RequestParameters) (string, error) {
+			return provider, nil
+		}
 	}
-	assertGetCacheKey(t, "123", parameters, "123#{42 3}")
+
+	result, _ := CreateRssFeedCached("ard", "test", RequestParameters{}, &cache, ValidationOff, fnCreate("ard"))
+	assertEquals(t, "ard", result)
+	result, _ = CreateRssFeedCached("zdf", "test", RequestParameters{}, &cache, ValidationOff, fnCreate("zdf"))
+	assertEquals(t, "zdf", result)
 }
 
-func TestGetCacheKeyWithMissingParameters(t *testing.T) {
-	parameters := RequestParameters{
-		Width: 42,
+func TestCreateRssFeedCachedDoesNotCacheInvalidFeedsInStrictMode(t *testing.T) {
+	counter := 0
+	fnCreate := func(s string, parameters RequestParameters) (string, error) {
+		counter = counter + 1
+		return "<rss version=\"2.0\"><channel></channel></rss>", nil
 	}
-	assertGetCacheKey(t, "123", parameters, "123#{42 0}")
+
+	cache := CreateCache(cacheDuration)
+	CreateRssFeedCached("zdf", "test", RequestParameters{}, &cache, ValidationStrict, fnCreate)
+	result, _ := CreateRssFeedCached("zdf", "test", RequestParameters{}, &cache, ValidationStrict, fnCreate)
+	assertEquals(t, "2", fmt.Sprint(counter))
+	assertEquals(t, "<rss version=\"2.0\"><channel></channel></rss>", result)
+
+	cache = CreateCache(cacheDuration)
+	CreateRssFeedCached("zdf", "test", RequestParameters{}, &cache, ValidationWarn, fnCreate)
+	CreateRssFeedCached("zdf", "test", RequestParameters{}, &cache, ValidationWarn, fnCreate)
+	assertEquals(t, "3", fmt.Sprint(counter))
 }
 
-func assertGetCacheKey(t *testing.T, showID string, parameters RequestParameters, expectedKey string) {
-	cacheKey := getCacheKey(showID, parameters)
-	assertEquals(t, expectedKey, cacheKey)
+func TestCreateRssFeedCachedDoesNotValidateOtherFormats(t *testing.T) {
+	counter := 0
+	fnCreate := func(s string, parameters RequestParameters) (string, error) {
+		counter = counter + 1
+		return "{\"version\":\"https://jsonfeed.org/version/1.1\"}", nil
+	}
+
+	cache := CreateCache(cacheDuration)
+	CreateRssFeedCached("zdf", "test", RequestParameters{Format: FormatJSON}, &cache, ValidationStrict, fnCreate)
+	CreateRssFeedCached("zdf", "test", RequestParameters{Format: FormatJSON}, &cache, ValidationStrict, fnCreate)
+	assertEquals(t, "1", fmt.Sprint(counter))
 }
 
 func assertEquals(t *testing.T, expected, actual string) {
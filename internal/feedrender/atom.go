@@ -0,0 +1,116 @@
+package feedrender
+
+import (
+	"crypto/sha1"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/seiferma/docker_mediathek2rss/internal"
+)
+
+// atomNamespace is the XML namespace of Atom feeds.
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+// atomIDNamespace is the namespace of the name-based UUIDs that identify shows and episodes in Atom feeds.
+var atomIDNamespace = [16]byte{0x4e, 0x5c, 0x0b, 0x7a, 0x21, 0x3d, 0x5f, 0x8e, 0x9a, 0x61, 0x3c, 0x0d, 0x52, 0xe7, 0x94, 0x18}
+
+// atomFeed represents the root element of an Atom feed.
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"feed"`
+	XMLNS    string      `xml:"xmlns,attr"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Author   *atomAuthor `xml:"author"`
+	Links    []atomLink  `xml:"link"`
+	Logo     string      `xml:"logo,omitempty"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+// atomAuthor represents the author of an Atom feed.
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+// atomLink represents a link of an Atom feed or entry. Media files are links of the relation enclosure.
+type atomLink struct {
+	Href     string `xml:"href,attr"`
+	Rel      string `xml:"rel,attr,omitempty"`
+	Type     string `xml:"type,attr,omitempty"`
+	HrefLang string `xml:"hreflang,attr,omitempty"`
+	Length   int64  `xml:"length,attr,omitempty"`
+}
+
+// atomEntry represents an episode in an Atom feed.
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published"`
+	Summary   string     `xml:"summary,omitempty"`
+	Links     []atomLink `xml:"link"`
+}
+
+func renderAtom(show *internal.Show, now time.Time) (result string, err error) {
+	feed := atomFeed{
+		XMLNS:    atomNamespace,
+		ID:       createAtomID(show.ProviderID, show.ID),
+		Title:    show.Title,
+		Subtitle: show.Description,
+		Updated:  now.Format(time.RFC3339),
+		Author:   &atomAuthor{Name: show.Author},
+		Links:    []atomLink{{Href: show.Link, Rel: "alternate"}},
+		Logo:     show.Image.URL,
+		Entries:  make([]atomEntry, 0, len(show.Episodes)),
+	}
+	if show.FeedURL != "" {
+		feed.Links = append(feed.Links, atomLink{Href: show.FeedURL, Rel: "self", Type: contentTypeByFormat[internal.FormatAtom]})
+	}
+	for i := range show.Episodes {
+		feed.Entries = append(feed.Entries, createAtomEntry(show, &show.Episodes[i]))
+	}
+	serialized, err := xml.MarshalIndent(feed, "", "    ")
+	if err != nil {
+		return
+	}
+	result = xml.Header + string(serialized)
+	return
+}
+
+func createAtomEntry(show *internal.Show, episode *internal.Episode) atomEntry {
+	entry := atomEntry{
+		ID:        createAtomID(show.ProviderID, show.ID, episode.ID),
+		Title:     episode.Title,
+		Updated:   episode.Date.Format(time.RFC3339),
+		Published: episode.Date.Format(time.RFC3339),
+		Summary:   episode.Description,
+	}
+	if episode.Link != "" {
+		entry.Links = append(entry.Links, atomLink{Href: episode.Link, Rel: "alternate"})
+	}
+	if media := episode.Media; media != nil && media.URL != "" {
+		entry.Links = append(entry.Links, atomLink{Href: media.URL, Rel: "enclosure", Type: media.MimeType, Length: media.Length})
+	}
+	for _, subtitle := range episode.Subtitles {
+		entry.Links = append(entry.Links, atomLink{Href: subtitle.URL, Rel: "related", Type: subtitle.MimeType, HrefLang: languageCodes[subtitle.Language]})
+	}
+	return entry
+}
+
+// createAtomID yields the IRI that identifies a show or an episode given by the provider, the show and the episode
+// ID. Atom requires IRIs, but the IDs of the television channels are arbitrary strings, so the IRI is the name-based
+// UUID (version 5) of the IDs.
+func createAtomID(ids ...string) string {
+	hash := sha1.New()
+	hash.Write(atomIDNamespace[:])
+	for _, id := range ids {
+		fmt.Fprintf(hash, "%v/", url.PathEscape(id))
+	}
+	uuid := hash.Sum(nil)[:16]
+	uuid[6] = (uuid[6] & 0x0f) | 0x50
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16])
+}
@@ -0,0 +1,61 @@
+package feedrender
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/seiferma/docker_mediathek2rss/internal"
+	"github.com/seiferma/docker_mediathek2rss/internal/rssfeed"
+)
+
+// defaultFeedLanguage is the language of shows without language, because the television channels broadcast in German.
+const defaultFeedLanguage = "de"
+
+// serialType is the value of itunes:type for shows whose episodes shall be consumed in order.
+const serialType = "serial"
+
+// createChannel creates the channel of a show, which contains the metadata that podcast clients need for showing
+// author, category and artwork. The author is the television channel that publishes the show, e.g. ZDF or funk.
+// The channel is explicit if one of its episodes is explicit.
+func createChannel(show *internal.Show, now time.Time) rssfeed.Channel {
+	channel := rssfeed.Channel{
+		Title:         show.Title,
+		Link:          show.Link,
+		Description:   &rssfeed.FeedDescription{Text: show.Description},
+		Language:      getFeedLanguage(show),
+		LastBuildDate: rssfeed.CreateDate(now),
+		Image: &rssfeed.Image{
+			URL:   show.Image.URL,
+			Title: show.Image.Title,
+			Link:  show.Link,
+		},
+		ITunesSubtitle: show.Title,
+		ITunesAuthor:   show.Author,
+		ITunesSummary:  &rssfeed.ITunesSummary{Text: show.Description},
+		ITunesCategory: &rssfeed.ITunesCategory{Text: show.Category},
+		ITunesImage:    &rssfeed.ITunesImage{URL: show.Image.URL},
+		ITunesExplicit: strconv.FormatBool(hasExplicitEpisode(show)),
+		FeedItems:      make([]rssfeed.FeedItem, 0, len(show.Episodes)),
+	}
+	if show.Serial {
+		channel.ITunesType = serialType
+	}
+	return channel
+}
+
+func hasExplicitEpisode(show *internal.Show) bool {
+	for i := range show.Episodes {
+		if show.Episodes[i].IsExplicit() {
+			return true
+		}
+	}
+	return false
+}
+
+// getFeedLanguage yields the language of the show, which is German unless the provider states another language.
+func getFeedLanguage(show *internal.Show) string {
+	if show.Language == "" {
+		return defaultFeedLanguage
+	}
+	return show.Language
+}
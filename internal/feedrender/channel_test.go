@@ -0,0 +1,27 @@
+package feedrender
+
+import (
+	"testing"
+)
+
+func TestCreateChannel(t *testing.T) {
+	show := createTestShow()
+	channel := createChannel(&show, testNow)
+	assertEquals(t, "de", channel.Language)
+	assertEquals(t, "funk", channel.ITunesAuthor)
+	assertEquals(t, "TV & Film", channel.ITunesCategory.Text)
+	assertEquals(t, "true", channel.ITunesExplicit)
+	assertEquals(t, "Walulis", channel.ITunesSubtitle)
+	assertEquals(t, "Mediensatire", channel.ITunesSummary.Text)
+	assertEquals(t, "https://example.org/image.jpg", channel.ITunesImage.URL)
+	assertEquals(t, "", channel.ITunesType)
+
+	show.Serial = true
+	assertEquals(t, "serial", createChannel(&show, testNow).ITunesType)
+
+	show.Episodes = show.Episodes[:1]
+	assertEquals(t, "false", createChannel(&show, testNow).ITunesExplicit)
+
+	show.Language = "fr"
+	assertEquals(t, "fr", createChannel(&show, testNow).Language)
+}
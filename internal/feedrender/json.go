@@ -0,0 +1,99 @@
+package feedrender
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/seiferma/docker_mediathek2rss/internal"
+)
+
+// jsonFeedVersion is the URL of the version of the JSON Feed specification that the feeds follow.
+const jsonFeedVersion = "https://jsonfeed.org/version/1.1"
+
+// jsonFeed represents the root object of a JSON Feed.
+type jsonFeed struct {
+	Version     string       `json:"version"`
+	Title       string       `json:"title"`
+	HomePageURL string       `json:"home_page_url,omitempty"`
+	FeedURL     string       `json:"feed_url,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Icon        string       `json:"icon,omitempty"`
+	Authors     []jsonAuthor `json:"authors,omitempty"`
+	Language    string       `json:"language,omitempty"`
+	Items       []jsonItem   `json:"items"`
+}
+
+// jsonAuthor represents the author of a JSON Feed.
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+// jsonItem represents an episode in a JSON Feed.
+type jsonItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url,omitempty"`
+	Title         string           `json:"title"`
+	ContentText   string           `json:"content_text"`
+	Image         string           `json:"image,omitempty"`
+	DatePublished string           `json:"date_published"`
+	Attachments   []jsonAttachment `json:"attachments,omitempty"`
+}
+
+// jsonAttachment represents a media or subtitle file of an episode in a JSON Feed.
+type jsonAttachment struct {
+	URL               string `json:"url"`
+	MimeType          string `json:"mime_type"`
+	SizeInBytes       int64  `json:"size_in_bytes,omitempty"`
+	DurationInSeconds int    `json:"duration_in_seconds,omitempty"`
+	Language          string `json:"language,omitempty"`
+}
+
+func renderJSON(show *internal.Show) (result string, err error) {
+	feed := jsonFeed{
+		Version:     jsonFeedVersion,
+		Title:       show.Title,
+		HomePageURL: show.Link,
+		FeedURL:     show.FeedURL,
+		Description: show.Description,
+		Icon:        show.Image.URL,
+		Authors:     []jsonAuthor{{Name: show.Author}},
+		Language:    getFeedLanguage(show),
+		Items:       make([]jsonItem, 0, len(show.Episodes)),
+	}
+	for i := range show.Episodes {
+		feed.Items = append(feed.Items, createJSONItem(&show.Episodes[i]))
+	}
+	serialized, err := json.MarshalIndent(feed, "", "    ")
+	if err != nil {
+		return
+	}
+	result = string(serialized)
+	return
+}
+
+func createJSONItem(episode *internal.Episode) jsonItem {
+	item := jsonItem{
+		ID:            episode.ID,
+		URL:           episode.Link,
+		Title:         episode.Title,
+		ContentText:   episode.Description,
+		Image:         episode.Image.URL,
+		DatePublished: episode.Date.Format(time.RFC3339),
+	}
+	if media := episode.Media; media != nil && media.URL != "" {
+		item.Attachments = append(item.Attachments, jsonAttachment{
+			URL:               media.URL,
+			MimeType:          media.MimeType,
+			SizeInBytes:       media.Length,
+			DurationInSeconds: episode.DurationInSeconds,
+		})
+	}
+	for _, subtitle := range episode.Subtitles {
+		item.Attachments = append(item.Attachments, jsonAttachment{
+			URL:      subtitle.URL,
+			MimeType: subtitle.MimeType,
+			Language: languageCodes[subtitle.Language],
+		})
+	}
+	return item
+}
@@ -0,0 +1,44 @@
+package feedrender
+
+import (
+	"time"
+
+	"github.com/seiferma/docker_mediathek2rss/internal"
+)
+
+// content type of the feed for each value of the format request parameter
+var contentTypeByFormat = map[string](string){
+	internal.FormatRSS:  "application/rss+xml",
+	internal.FormatAtom: "application/atom+xml",
+	internal.FormatJSON: "application/feed+json",
+}
+
+// ISO 639-1 codes of the ISO 639-2 codes of the languages of the television channels, which feeds use for
+// languages of subtitles
+var languageCodes = map[string](string){
+	"deu": "de",
+	"ger": "de",
+	"eng": "en",
+	"fra": "fr",
+	"fre": "fr",
+}
+
+// Render turns a show into a feed of the given format, which is RSS, Atom or JSON Feed. An empty format yields RSS.
+// The current time is the build date of the feed.
+func Render(show *internal.Show, format string, now time.Time) (result string, err error) {
+	switch format {
+	case internal.FormatAtom:
+		return renderAtom(show, now)
+	case internal.FormatJSON:
+		return renderJSON(show)
+	}
+	return renderRSS(show, now)
+}
+
+// GetContentType yields the content type of feeds of the given format.
+func GetContentType(format string) string {
+	if contentType, found := contentTypeByFormat[format]; found {
+		return contentType
+	}
+	return contentTypeByFormat[internal.FormatRSS]
+}
@@ -0,0 +1,147 @@
+package feedrender
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seiferma/docker_mediathek2rss/internal"
+)
+
+var testNow = time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func createTestShow() internal.Show {
+	return internal.Show{
+		ProviderID:  "ard",
+		ID:          "Y3JpZDovL2Z1bmsubmV0LzEwMzE",
+		FeedURL:     "http://localhost:8080/ard/show/Y3JpZDovL2Z1bmsubmV0LzEwMzE?format=atom",
+		Title:       "Walulis",
+		Description: "Mediensatire",
+		Link:        "https://example.org/walulis",
+		Image:       internal.Image{URL: "https://example.org/image.jpg", Title: "Walulis"},
+		Author:      "funk",
+		Category:    internal.DefaultITunesCategory,
+		Episodes: []internal.Episode{
+			{
+				ID:                "episode-1",
+				Title:             "Episode 1",
+				Description:       "Description of episode 1",
+				Link:              "https://example.org/episode-1",
+				Image:             internal.Image{URL: "https://example.org/episode-1.jpg"},
+				Date:              time.Date(2020, 12, 1, 20, 15, 0, 0, time.UTC),
+				DurationInSeconds: 1800,
+				Media:             &internal.MediaVariant{URL: "https://example.org/episode-1.mp4", MimeType: internal.VideoMimeType, Length: 42},
+				Subtitles:         []internal.Subtitle{{URL: "https://example.org/episode-1.vtt", MimeType: "text/vtt", Language: "deu"}},
+			},
+			{
+				ID:                "episode-2",
+				Title:             "Episode 2",
+				Description:       "Description of episode 2",
+				Date:              time.Date(2020, 12, 2, 20, 15, 0, 0, time.UTC),
+				DurationInSeconds: 60,
+				Restrictions:      internal.CreateRestrictions("", "FSK18"),
+			},
+		},
+	}
+}
+
+func TestRenderRSS(t *testing.T) {
+	show := createTestShow()
+	result, err := Render(&show, internal.FormatRSS, testNow)
+	assertNoError(t, err)
+	assertEquals(t, 2, strings.Count(result, "<item>"))
+	assertContains(t, result, `xmlns:podcast="https://podcastindex.org/namespace/1.0"`)
+	assertContains(t, result, `<enclosure url="https://example.org/episode-1.mp4" type="video/mp4" length="42"></enclosure>`)
+	assertContains(t, result, `<podcast:transcript url="https://example.org/episode-1.vtt" type="text/vtt" language="de" rel="captions"></podcast:transcript>`)
+	assertContains(t, result, "<itunes:explicit>true</itunes:explicit>")
+	assertEquals(t, 1, strings.Count(result, "<enclosure"))
+}
+
+func TestRenderRSSOmitsPodcastNamespaceWithoutSubtitles(t *testing.T) {
+	show := createTestShow()
+	show.Episodes[0].Subtitles = nil
+	result, err := Render(&show, "", testNow)
+	assertNoError(t, err)
+	assertEquals(t, false, strings.Contains(result, "xmlns:podcast"))
+}
+
+func TestRenderAtom(t *testing.T) {
+	show := createTestShow()
+	result, err := Render(&show, internal.FormatAtom, testNow)
+	assertNoError(t, err)
+	assertEquals(t, true, strings.HasPrefix(result, xml.Header))
+	assertEquals(t, 2, strings.Count(result, "<entry>"))
+	assertContains(t, result, `<feed xmlns="http://www.w3.org/2005/Atom">`)
+	assertContains(t, result, "<updated>2021-01-01T12:00:00Z</updated>")
+	assertContains(t, result, `<link href="https://example.org/episode-1.mp4" rel="enclosure" type="video/mp4" length="42"></link>`)
+	assertContains(t, result, `<link href="https://example.org/episode-1.vtt" rel="related" type="text/vtt" hreflang="de"></link>`)
+	assertContains(t, result, `<link href="http://localhost:8080/ard/show/Y3JpZDovL2Z1bmsubmV0LzEwMzE?format=atom" rel="self" type="application/atom+xml"></link>`)
+
+	var feed atomFeed
+	assertNoError(t, xml.Unmarshal([]byte(result), &feed))
+	ids := []string{feed.ID, feed.Entries[0].ID, feed.Entries[1].ID}
+	for i, id := range ids {
+		if parsedID, err := url.Parse(id); err != nil || !parsedID.IsAbs() || !strings.HasPrefix(id, "urn:uuid:") {
+			t.Fatalf("Expected the ID %q to be an IRI.", id)
+		}
+		for _, otherID := range ids[:i] {
+			assertEquals(t, false, id == otherID)
+		}
+	}
+	assertEquals(t, feed.Entries[0].ID, createAtomEntry(&show, &show.Episodes[0]).ID)
+}
+
+func TestCreateAtomIDDistinguishesSeparators(t *testing.T) {
+	assertEquals(t, createAtomID("zdf", "byPath/comedy", "episode"), createAtomID("zdf", "byPath/comedy", "episode"))
+	assertEquals(t, false, createAtomID("zdf", "byPath/comedy", "episode") == createAtomID("zdf", "byPath", "comedy/episode"))
+	assertEquals(t, "urn:uuid:", createAtomID("zdf")[:9])
+	assertEquals(t, 45, len(createAtomID("zdf")))
+}
+
+func TestRenderJSON(t *testing.T) {
+	show := createTestShow()
+	result, err := Render(&show, internal.FormatJSON, testNow)
+	assertNoError(t, err)
+
+	var feed jsonFeed
+	assertNoError(t, json.Unmarshal([]byte(result), &feed))
+	assertEquals(t, "https://jsonfeed.org/version/1.1", feed.Version)
+	assertEquals(t, "http://localhost:8080/ard/show/Y3JpZDovL2Z1bmsubmV0LzEwMzE?format=atom", feed.FeedURL)
+	assertEquals(t, "funk", feed.Authors[0].Name)
+	assertEquals(t, "de", feed.Language)
+	assertEquals(t, 2, len(feed.Items))
+	assertEquals(t, "2020-12-01T20:15:00Z", feed.Items[0].DatePublished)
+	assertEquals(t, 2, len(feed.Items[0].Attachments))
+	assertEquals(t, int64(42), feed.Items[0].Attachments[0].SizeInBytes)
+	assertEquals(t, 1800, feed.Items[0].Attachments[0].DurationInSeconds)
+	assertEquals(t, "de", feed.Items[0].Attachments[1].Language)
+	assertEquals(t, 0, len(feed.Items[1].Attachments))
+}
+
+func TestGetContentType(t *testing.T) {
+	assertEquals(t, "application/rss+xml", GetContentType(""))
+	assertEquals(t, "application/rss+xml", GetContentType(internal.FormatRSS))
+	assertEquals(t, "application/atom+xml", GetContentType(internal.FormatAtom))
+	assertEquals(t, "application/feed+json", GetContentType(internal.FormatJSON))
+}
+
+func assertContains(t *testing.T, result, expected string) {
+	if !strings.Contains(result, expected) {
+		t.Fatalf("Expected %v to be contained in:\n%v", expected, result)
+	}
+}
+
+func assertNoError(t *testing.T, err error) {
+	if err != nil {
+		t.Fatalf("There should not be an error.\n%v", err)
+	}
+}
+
+func assertEquals(t *testing.T, expected, actual interface{}) {
+	if expected != actual {
+		t.Fatalf("Expected %v but got %v.", expected, actual)
+	}
+}
@@ -0,0 +1,63 @@
+package feedrender
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/seiferma/docker_mediathek2rss/internal"
+	"github.com/seiferma/docker_mediathek2rss/internal/rssfeed"
+)
+
+// transcriptRel marks subtitles as captions, which podcast players show while playing the episode.
+const transcriptRel = "captions"
+
+func renderRSS(show *internal.Show, now time.Time) (result string, err error) {
+	feed := rssfeed.CreateFeed()
+	feed.Channel = createChannel(show, now)
+	for i := range show.Episodes {
+		item := createFeedItem(&show.Episodes[i])
+		if len(item.Transcripts) > 0 {
+			feed.XMLNSPodcast = rssfeed.PodcastNamespace
+		}
+		feed.Channel.FeedItems = append(feed.Channel.FeedItems, item)
+	}
+	result, err = feed.SerializeToString()
+	return
+}
+
+func createFeedItem(episode *internal.Episode) rssfeed.FeedItem {
+	item := rssfeed.FeedItem{
+		Title:                episode.Title,
+		Link:                 episode.Link,
+		Description:          &rssfeed.FeedDescription{Text: episode.Description},
+		PubDate:              rssfeed.CreateDate(episode.Date),
+		GUID:                 rssfeed.CreateGUID(episode.ID),
+		ITunesDurationString: rssfeed.CreateItunesDurationStringFromSeconds(episode.DurationInSeconds),
+		ITunesTitle:          episode.Title,
+		ITunesSummary:        &rssfeed.ItunesSummary{Text: episode.Description},
+		ITunesImage:          &rssfeed.ITunesImage{URL: episode.Image.URL},
+		ITunesSeason:         episode.Season,
+		ITunesEpisode:        episode.Number,
+		ITunesEpisodeType:    episode.Type,
+	}
+	if media := episode.Media; media != nil {
+		// RSS 2.0 requires the length, which is zero if the media file has not been probed
+		item.Enclosure = &rssfeed.FeedItemEnclosure{
+			URL:    media.URL,
+			Type:   media.MimeType,
+			Length: strconv.FormatInt(media.Length, 10),
+		}
+	}
+	if episode.IsExplicit() {
+		item.ITunesExplicit = "true"
+	}
+	for _, subtitle := range episode.Subtitles {
+		item.Transcripts = append(item.Transcripts, rssfeed.Transcript{
+			URL:      subtitle.URL,
+			Type:     subtitle.MimeType,
+			Language: languageCodes[subtitle.Language],
+			Rel:      transcriptRel,
+		})
+	}
+	return item
+}
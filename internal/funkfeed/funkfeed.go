@@ -0,0 +1,161 @@
+package funkfeed
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/seiferma/docker_mediathek2rss/internal"
+	"github.com/seiferma/docker_mediathek2rss/internal/nexxapi"
+	"github.com/seiferma/docker_mediathek2rss/internal/streamselect"
+)
+
+// author is the author of all shows of funk.
+const author = "funk"
+
+// websiteBase is the base of the links to the channels, series and videos on the funk website.
+const websiteBase = "https://www.funk.net"
+
+// routes of funk
+const (
+	routeChannel = "channel"
+	routeSeries  = "series"
+)
+
+// path of the pages of channels and series on the funk website for each route
+var websitePathByRoute = map[string](string){
+	routeChannel: "channel",
+	routeSeries:  "serie",
+}
+
+var collectionIDRegex = regexp.MustCompile("^[0-9]+$")
+
+// funkShow holds the channel or series of a show and its videos, which the API lists together with their streams.
+type funkShow struct {
+	collection nexxapi.Collection
+	videos     []nexxapi.Video
+	link       string
+}
+
+// Provider offers the channels and series of funk, which are identified by their ID at nexx.
+type Provider struct {
+	api         *nexxapi.NexxAPI
+	maxEpisodes int
+}
+
+// CreateProvider creates the provider of funk, which lists up to maxEpisodes episodes per show. The given API is
+// shared with other providers, so all of them use the same session.
+func CreateProvider(maxEpisodes int, funkAPI *nexxapi.NexxAPI) *Provider {
+	return &Provider{
+		api:         funkAPI,
+		maxEpisodes: maxEpisodes,
+	}
+}
+
+// ID yields funk.
+func (funkProvider *Provider) ID() string {
+	return "funk"
+}
+
+// Routes yields channel and series, which serve channels at /funk/show/channel/{ID} and series at
+// /funk/show/series/{ID}.
+func (funkProvider *Provider) Routes() []string {
+	return []string{routeChannel, routeSeries}
+}
+
+// IsValidShowID checks if the ID is a number like 1031.
+func (funkProvider *Provider) IsValidShowID(route, showID string) bool {
+	return collectionIDRegex.MatchString(showID)
+}
+
+// LookupShow yields the channel or series with the given ID including its videos.
+func (funkProvider *Provider) LookupShow(route, showID string, parameters internal.RequestParameters) (show internal.Show, err error) {
+	details := &funkShow{}
+	if route == routeSeries {
+		details.collection, err = funkProvider.api.GetSeries(showID)
+		if err == nil {
+			details.videos, err = funkProvider.api.GetSeriesVideos(showID, funkProvider.maxEpisodes)
+		}
+	} else {
+		details.collection, err = funkProvider.api.GetChannel(showID)
+		if err == nil {
+			details.videos, err = funkProvider.api.GetChannelVideos(showID, funkProvider.maxEpisodes)
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	general := &details.collection.General
+	details.link = fmt.Sprintf("%v/%v/%v-%v", websiteBase, websitePathByRoute[route], general.Slug, general.ID)
+	description := general.Description
+	if description == "" {
+		description = general.Subtitle
+	}
+	show = internal.Show{
+		Title:       general.Title,
+		Description: description,
+		Link:        details.link,
+		Image:       internal.Image{URL: details.collection.ImageData.Thumb, Title: general.Title},
+		Author:      author,
+		Category:    internal.DefaultITunesCategory,
+		Details:     details,
+	}
+	return
+}
+
+// ListEpisodes yields the videos of the channel or series from newest to oldest.
+func (funkProvider *Provider) ListEpisodes(show *internal.Show, parameters internal.RequestParameters, fnVisit func(internal.Episode) bool) error {
+	details := show.Details.(*funkShow)
+	for i := range details.videos {
+		video := &details.videos[i]
+		general := &video.General
+		description := general.Description
+		if description == "" {
+			description = general.Teaser
+		}
+		episode := internal.Episode{
+			ID:                strconv.Itoa(general.ID),
+			Title:             general.Title,
+			Description:       description,
+			Link:              fmt.Sprintf("%v/%v-%v", details.link, general.Slug, general.ID),
+			Image:             internal.Image{URL: video.ImageData.Thumb, Title: general.Title},
+			Date:              video.GetUploadDate(),
+			DurationInSeconds: video.GetDurationInSeconds(),
+			Details:           video,
+		}
+		if !fnVisit(episode) {
+			break
+		}
+	}
+	return nil
+}
+
+// ResolveMedia selects the MP4 file of a video that matches the request parameters best. funk offers no adaptive
+// streams, so the files are listed with their resolution and bitrate.
+func (funkProvider *Provider) ResolveMedia(show *internal.Show, episode *internal.Episode, parameters internal.RequestParameters, services *internal.MediaServices) (err error) {
+	streams := episode.Details.(*nexxapi.Video).StreamData.GetStreams()
+	if len(streams) == 0 {
+		err = errors.New("no media streams")
+		return
+	}
+	URL, mimeType := streamselect.Resolve(createCandidates(streams), parameters, services, nil)
+	episode.Media = &internal.MediaVariant{URL: URL, MimeType: mimeType}
+	return
+}
+
+func createCandidates(streams []nexxapi.Stream) []streamselect.Candidate {
+	candidates := make([]streamselect.Candidate, 0, len(streams))
+	for _, stream := range streams {
+		candidates = append(candidates, streamselect.Candidate{
+			URL:       stream.URL,
+			MimeType:  internal.VideoMimeType,
+			Width:     stream.Width,
+			Height:    stream.Height,
+			Bitrate:   stream.Bitrate,
+			Container: "mp4",
+		})
+	}
+	return candidates
+}
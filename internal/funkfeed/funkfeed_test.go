@@ -0,0 +1,103 @@
+package funkfeed
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seiferma/docker_mediathek2rss/internal"
+	"github.com/seiferma/docker_mediathek2rss/internal/nexxapi"
+	"github.com/seiferma/docker_mediathek2rss/internal/provider"
+	"github.com/seiferma/docker_mediathek2rss/internal/rssfeed"
+)
+
+// testNow is the current time during the tests, which is the build date of the feeds.
+var testNow = time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+var defaultParameters = internal.RequestParameters{
+	Width: 1920,
+}
+
+const sessionInitURL = "https://api.nexx.cloud/v3/741/session/init"
+
+var urlToFilename = map[string](string){
+	sessionInitURL: "nexx-session-init.json",
+	"https://api.nexx.cloud/v3/741/channels/byid/1031":    "nexx-channel-1031.json",
+	"https://api.nexx.cloud/v3/741/videos/bychannel/1031": "nexx-channel-1031-videos.json",
+	"https://api.nexx.cloud/v3/741/series/byid/1031":      "nexx-unknown.json",
+}
+
+func TestCreateRssFeedValid(t *testing.T) {
+	result, _, err := createRssFeedMocked(routeChannel, "1031", defaultParameters)
+	if err != nil {
+		t.Fatalf("There should not be an error.\n%v", err)
+	}
+	if report := rssfeed.Validate([]byte(result)); !report.IsValid() {
+		t.Fatalf("The created feed is not valid.\n%v", report)
+	}
+	expectedBytes, err := ioutil.ReadFile("../testdata/funk-channel-1031.xml")
+	expected := string(expectedBytes)
+
+	if strings.Compare(result, expected) != 0 {
+		// ioutil.WriteFile("/tmp/actual.xml", []byte(result), 0644)
+		// ioutil.WriteFile("/tmp/expected.xml", []byte(expected), 0644)
+		t.Fatalf("The created XML is not as expected. Created:\n%v", result)
+	}
+}
+
+func TestCreateRssFeedInitializesSessionOnce(t *testing.T) {
+	_, requests, err := createRssFeedMocked(routeChannel, "1031", defaultParameters)
+	if err != nil {
+		t.Fatalf("There should not be an error.\n%v", err)
+	}
+	assertEquals(t, 1, requests[sessionInitURL])
+}
+
+func TestCreateRssFeedWithMaxHeight(t *testing.T) {
+	result, _, err := createRssFeedMocked(routeChannel, "1031", internal.RequestParameters{Width: 1920, MaxHeight: 720})
+	if err != nil {
+		t.Fatalf("There should not be an error.\n%v", err)
+	}
+	assertEquals(t, 2, strings.Count(result, "<enclosure"))
+	assertEquals(t, true, strings.Contains(result, `<enclosure url="https://funk-02.akamaized.net/funk/files/de/1707442_src/2-w7FWPztXm9hnZpbjMcKq.mp4" type="video/mp4" length="0"></enclosure>`))
+}
+
+func TestCreateRssFeedOfUnknownShow(t *testing.T) {
+	if _, _, err := createRssFeedMocked(routeSeries, "1031", defaultParameters); err == nil {
+		t.Fatal("There should be an error.")
+	}
+}
+
+func TestIsValidShowID(t *testing.T) {
+	funkProvider := CreateProvider(3, nil)
+	assertEquals(t, true, funkProvider.IsValidShowID(routeChannel, "1031"))
+	assertEquals(t, false, funkProvider.IsValidShowID(routeChannel, "walulis"))
+	assertEquals(t, false, funkProvider.IsValidShowID(routeSeries, "../1031"))
+	assertEquals(t, false, funkProvider.IsValidShowID(routeSeries, ""))
+}
+
+func createRssFeedMocked(route, showID string, parameters internal.RequestParameters) (result string, requests map[string](int), err error) {
+	requests = map[string](int){}
+	fnPostHTTP := func(URL string, data url.Values, headers map[string]string) (result []byte, err error) {
+		requests[URL]++
+		filename, ok := urlToFilename[URL]
+		if !ok {
+			err = errors.New("unknown URL")
+			return
+		}
+		result, err = ioutil.ReadFile("../testdata/" + filename)
+		return
+	}
+	funkProvider := CreateProvider(3, nexxapi.CreateNexxAPIWithPostFunc(741, "CA4SDGOBTRM421IRNO0", fnPostHTTP))
+	result, err = provider.CreateFeed(funkProvider, route, showID, "", parameters, &internal.MediaServices{}, testNow)
+	return
+}
+
+func assertEquals(t *testing.T, expected, actual interface{}) {
+	if expected != actual {
+		t.Fatalf("Expected %v but got %v.", expected, actual)
+	}
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"sort"
+	"strings"
+)
+
+// Values of the sort request parameter
+const (
+	// SortByDate orders the episodes from oldest to newest.
+	SortByDate = "date"
+	// SortByDateDescending orders the episodes from newest to oldest.
+	SortByDateDescending = "-date"
+	// SortByTitle orders the episodes alphabetically by their title.
+	SortByTitle = "title"
+	// SortByDuration orders the episodes from shortest to longest.
+	SortByDuration = "duration"
+)
+
+// OrderEpisodes sorts the episodes of a show according to the sort request parameter. Without sort parameter, the
+// order of the television channel is kept.
+//
+// In serial mode, the episodes are ordered from oldest to newest regardless of the sort parameter and the show is
+// marked as serial, so podcast players present the episodes in order. The numbers of the television channel are only
+// kept if all episodes have one. Otherwise, all episodes are numbered by their position starting with one, so the
+// numbers do not collide.
+func OrderEpisodes(show *Show, parameters RequestParameters) {
+	order := parameters.Sort
+	if parameters.Serial {
+		order = SortByDate
+	}
+	episodes := show.Episodes
+	switch order {
+	case SortByDate:
+		sort.SliceStable(episodes, func(i, j int) bool { return episodes[i].Date.Before(episodes[j].Date) })
+	case SortByDateDescending:
+		sort.SliceStable(episodes, func(i, j int) bool { return episodes[i].Date.After(episodes[j].Date) })
+	case SortByTitle:
+		sort.SliceStable(episodes, func(i, j int) bool { return strings.ToLower(episodes[i].Title) < strings.ToLower(episodes[j].Title) })
+	case SortByDuration:
+		sort.SliceStable(episodes, func(i, j int) bool { return episodes[i].DurationInSeconds < episodes[j].DurationInSeconds })
+	}
+
+	if parameters.Serial {
+		show.Serial = true
+		if !haveNumbers(episodes) {
+			for i := range episodes {
+				episodes[i].Number = i + 1
+			}
+		}
+	}
+}
+
+// IsReordered determines if the request parameters change the order of the television channel.
+func IsReordered(parameters RequestParameters) bool {
+	return parameters.Sort != "" || parameters.Serial
+}
+
+// haveNumbers determines if all episodes are numbered by the television channel.
+func haveNumbers(episodes []Episode) bool {
+	for _, episode := range episodes {
+		if episode.Number == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOrderEpisodesKeepsOrderByDefault(t *testing.T) {
+	show := createTestShow()
+	OrderEpisodes(&show, RequestParameters{})
+	assertEquals(t, "b c a", getTitles(&show))
+	assertEquals(t, "false", fmt.Sprint(show.Serial))
+}
+
+func TestOrderEpisodesBySortParameter(t *testing.T) {
+	assertOrder(t, SortByDate, "a b c")
+	assertOrder(t, SortByDateDescending, "c b a")
+	assertOrder(t, SortByTitle, "a b c")
+	assertOrder(t, SortByDuration, "c a b")
+}
+
+func TestOrderEpisodesSerial(t *testing.T) {
+	show := createTestShow()
+	OrderEpisodes(&show, RequestParameters{Sort: SortByDuration, Serial: true})
+	assertEquals(t, "a b c", getTitles(&show))
+	assertEquals(t, "true", fmt.Sprint(show.Serial))
+	for i, episode := range show.Episodes {
+		assertEquals(t, fmt.Sprint(i+1), fmt.Sprint(episode.Number))
+	}
+}
+
+func TestOrderEpisodesSerialKeepsEpisodeNumbers(t *testing.T) {
+	show := createTestShow()
+	show.Episodes[0].Number = 42
+	show.Episodes[1].Number = 43
+	show.Episodes[2].Number = 41
+	OrderEpisodes(&show, RequestParameters{Serial: true})
+	assertEquals(t, "41 42 43", fmt.Sprint(show.Episodes[0].Number, show.Episodes[1].Number, show.Episodes[2].Number))
+}
+
+func TestOrderEpisodesSerialNumbersAllEpisodesIfNumbersAreMissing(t *testing.T) {
+	show := createTestShow()
+	show.Episodes[0].Number = 2
+	OrderEpisodes(&show, RequestParameters{Serial: true})
+	assertEquals(t, "1 2 3", fmt.Sprint(show.Episodes[0].Number, show.Episodes[1].Number, show.Episodes[2].Number))
+}
+
+func TestIsReordered(t *testing.T) {
+	assertEquals(t, "false", fmt.Sprint(IsReordered(RequestParameters{Limit: 2})))
+	assertEquals(t, "true", fmt.Sprint(IsReordered(RequestParameters{Sort: SortByTitle})))
+	assertEquals(t, "true", fmt.Sprint(IsReordered(RequestParameters{Serial: true})))
+}
+
+func assertOrder(t *testing.T, order, expectedTitles string) {
+	show := createTestShow()
+	OrderEpisodes(&show, RequestParameters{Sort: order})
+	assertEquals(t, expectedTitles, getTitles(&show))
+}
+
+// createTestShow creates a show with the episodes b, c and a, which are published in alphabetical order.
+func createTestShow() Show {
+	createEpisode := func(title string, day int, durationInSeconds int) Episode {
+		date := time.Date(2021, 1, day, 20, 15, 0, 0, time.UTC)
+		return Episode{Title: title, Date: date, DurationInSeconds: durationInSeconds}
+	}
+	return Show{
+		Episodes: []Episode{
+			createEpisode("b", 2, 3600),
+			createEpisode("c", 3, 59),
+			createEpisode("a", 1, 1800),
+		},
+	}
+}
+
+func getTitles(show *Show) string {
+	titles := make([]string, 0)
+	for _, episode := range show.Episodes {
+		titles = append(titles, episode.Title)
+	}
+	return strings.Join(titles, " ")
+}
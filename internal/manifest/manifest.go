@@ -0,0 +1,384 @@
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MIME types of the supported manifests
+const (
+	HLSMimeType  = "application/x-mpegURL"
+	DASHMimeType = "application/dash+xml"
+)
+
+const requestTimeout = 10 * time.Second
+
+// Variant represents one rendition of an adaptive stream. Unknown numeric values are zero and unknown strings are empty.
+//
+// HLS variants have the MIME type of the manifest, because their variant playlist is played. DASH variants consist of
+// a single file and have the MIME type of that file.
+type Variant struct {
+	URL      string
+	MimeType string
+	Width    int
+	Height   int
+	Bitrate  int // in kbit/s
+	Codecs   string
+	IsAudio  bool
+}
+
+// IsManifestMimeType determines if the given MIME type describes an HLS or DASH manifest.
+func IsManifestMimeType(mimeType string) bool {
+	return strings.EqualFold(mimeType, HLSMimeType) || strings.EqualFold(mimeType, "application/vnd.apple.mpegurl") || mimeType == DASHMimeType
+}
+
+// Reader downloads manifests and yields their variants.
+// The results are cached per URL, so feeds can be refreshed without downloading all manifests again.
+//
+// Users should always create this via CreateReader to correctly initialize the cache.
+type Reader struct {
+	entries       sync.Map
+	entryDuration time.Duration
+	fnGet         func(string) ([]byte, error)
+	fnNow         func() time.Time
+}
+
+type cacheValue struct {
+	ValidTo  time.Time
+	Variants []Variant
+}
+
+// CreateReader creates a new reader that caches the variants of a manifest for the given cacheDuration.
+func CreateReader(cacheDuration time.Duration) *Reader {
+	return CreateReaderWithGetFunction(cacheDuration, doHTTPGetRequest, time.Now)
+}
+
+// CreateReaderWithGetFunction creates a new reader with a user defined function for downloading a manifest
+// as well as a user defined now function.
+func CreateReaderWithGetFunction(cacheDuration time.Duration, fnGet func(string) ([]byte, error), fnNow func() time.Time) *Reader {
+	return &Reader{
+		entryDuration: cacheDuration,
+		fnGet:         fnGet,
+		fnNow:         fnNow,
+	}
+}
+
+// GetVariants yields the variants of the HLS or DASH manifest at the given URL.
+func (reader *Reader) GetVariants(URL string) (variants []Variant, err error) {
+	loadResult, found := reader.entries.Load(URL)
+	if found {
+		entry := loadResult.(cacheValue)
+		if !reader.fnNow().After(entry.ValidTo) {
+			return entry.Variants, nil
+		}
+		reader.entries.Delete(URL)
+	}
+
+	content, err := reader.fnGet(URL)
+	if err != nil {
+		return
+	}
+	variants, err = Parse(URL, content)
+	if err != nil {
+		return
+	}
+	reader.entries.Store(URL, cacheValue{
+		ValidTo:  reader.fnNow().Add(reader.entryDuration),
+		Variants: variants,
+	})
+	return
+}
+
+// Parse yields the variants of an HLS or DASH manifest. The format is detected from the content.
+func Parse(manifestURL string, content []byte) ([]Variant, error) {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(content, []byte("\xef\xbb\xbf")))
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("#EXTM3U")):
+		return ParseHLS(manifestURL, trimmed)
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return ParseDASH(manifestURL, trimmed)
+	}
+	return nil, errors.New("Unknown manifest format")
+}
+
+// ParseHLS yields the variants of an HLS master playlist. A media playlist is a single variant of unknown quality.
+//
+// Variants whose audio is a separate rendition (#EXT-X-MEDIA with a URI) are skipped, because their variant playlist
+// contains no audio. Such streams can only be played via the master playlist.
+func ParseHLS(manifestURL string, content []byte) (variants []Variant, err error) {
+	baseURL, err := url.Parse(manifestURL)
+	if err != nil {
+		return
+	}
+	variants = make([]Variant, 0)
+	isMediaPlaylist := false
+	separateAudioGroups := map[string](bool){}
+	variantAudioGroups := make([]string, 0)
+	var pending *Variant
+	var pendingAudioGroup string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#EXT-X-MEDIA:"):
+			attributes := parseAttributes(strings.TrimPrefix(line, "#EXT-X-MEDIA:"))
+			if attributes["TYPE"] == "AUDIO" && attributes["URI"] != "" {
+				separateAudioGroups[attributes["GROUP-ID"]] = true
+			}
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			attributes := parseAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+			pending = createHLSVariant(attributes)
+			pendingAudioGroup = attributes["AUDIO"]
+		case strings.HasPrefix(line, "#EXTINF:"):
+			isMediaPlaylist = true
+		case strings.HasPrefix(line, "#"):
+			continue
+		case pending != nil:
+			pending.URL = resolve(baseURL, line)
+			variants = append(variants, *pending)
+			variantAudioGroups = append(variantAudioGroups, pendingAudioGroup)
+			pending = nil
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	if len(variants) == 0 && isMediaPlaylist {
+		variants = append(variants, Variant{
+			URL:      manifestURL,
+			MimeType: HLSMimeType,
+		})
+		return
+	}
+	variantsWithAudio := make([]Variant, 0, len(variants))
+	for i, variant := range variants {
+		if !separateAudioGroups[variantAudioGroups[i]] {
+			variantsWithAudio = append(variantsWithAudio, variant)
+		}
+	}
+	variants = variantsWithAudio
+	return
+}
+
+func createHLSVariant(attributes map[string](string)) *Variant {
+	variant := &Variant{
+		MimeType: HLSMimeType,
+		Codecs:   attributes["CODECS"],
+	}
+	if bandwidth, err := strconv.Atoi(attributes["BANDWIDTH"]); err == nil {
+		variant.Bitrate = bandwidth / 1000
+	}
+	if resolution := strings.Split(attributes["RESOLUTION"], "x"); len(resolution) == 2 {
+		variant.Width, _ = strconv.Atoi(resolution[0])
+		variant.Height, _ = strconv.Atoi(resolution[1])
+	}
+	variant.IsAudio = variant.Width == 0 && variant.Codecs != "" && !hasVideoCodec(variant.Codecs)
+	return variant
+}
+
+// parseAttributes parses an HLS attribute list. Quoted values may contain commas.
+func parseAttributes(attributeList string) map[string](string) {
+	attributes := map[string](string){}
+	for len(attributeList) > 0 {
+		separator := strings.Index(attributeList, "=")
+		if separator < 0 {
+			break
+		}
+		name := strings.TrimSpace(attributeList[:separator])
+		attributeList = attributeList[separator+1:]
+		var value string
+		if strings.HasPrefix(attributeList, "\"") {
+			end := strings.Index(attributeList[1:], "\"")
+			if end < 0 {
+				end = len(attributeList) - 1
+			}
+			value = attributeList[1 : end+1]
+			attributeList = attributeList[min(end+2, len(attributeList)):]
+		} else {
+			end := strings.Index(attributeList, ",")
+			if end < 0 {
+				end = len(attributeList)
+			}
+			value = attributeList[:end]
+			attributeList = attributeList[end:]
+		}
+		attributes[name] = value
+		attributeList = strings.TrimPrefix(attributeList, ",")
+	}
+	return attributes
+}
+
+func hasVideoCodec(codecs string) bool {
+	for _, codec := range []string{"avc", "hvc", "hev", "vp9", "vp09", "av01"} {
+		if strings.Contains(codecs, codec) {
+			return true
+		}
+	}
+	return false
+}
+
+type dashMPD struct {
+	BaseURL string       `xml:"BaseURL"`
+	Periods []dashPeriod `xml:"Period"`
+}
+
+type dashPeriod struct {
+	BaseURL        string              `xml:"BaseURL"`
+	AdaptationSets []dashAdaptationSet `xml:"AdaptationSet"`
+}
+
+type dashAdaptationSet struct {
+	BaseURL         string               `xml:"BaseURL"`
+	MimeType        string               `xml:"mimeType,attr"`
+	ContentType     string               `xml:"contentType,attr"`
+	Codecs          string               `xml:"codecs,attr"`
+	Width           int                  `xml:"width,attr"`
+	Height          int                  `xml:"height,attr"`
+	SegmentTemplate *struct{}            `xml:"SegmentTemplate"`
+	SegmentList     *struct{}            `xml:"SegmentList"`
+	Representations []dashRepresentation `xml:"Representation"`
+}
+
+type dashRepresentation struct {
+	BaseURL         string    `xml:"BaseURL"`
+	MimeType        string    `xml:"mimeType,attr"`
+	Codecs          string    `xml:"codecs,attr"`
+	Width           int       `xml:"width,attr"`
+	Height          int       `xml:"height,attr"`
+	Bandwidth       int       `xml:"bandwidth,attr"`
+	SegmentTemplate *struct{} `xml:"SegmentTemplate"`
+	SegmentList     *struct{} `xml:"SegmentList"`
+}
+
+// ParseDASH yields the audio and video representations of the first period of a DASH manifest that consist of a single
+// file. Segmented representations are skipped, because they can only be played via the manifest. Video
+// representations are skipped if the period has a separate audio adaptation set, because their files are silent.
+func ParseDASH(manifestURL string, content []byte) (variants []Variant, err error) {
+	baseURL, err := url.Parse(manifestURL)
+	if err != nil {
+		return
+	}
+	var mpd dashMPD
+	err = xml.Unmarshal(content, &mpd)
+	if err != nil {
+		return
+	}
+	variants = make([]Variant, 0)
+	if len(mpd.Periods) == 0 {
+		return
+	}
+	period := mpd.Periods[0]
+	periodURL := resolveBase(resolveBase(baseURL, mpd.BaseURL), period.BaseURL)
+	hasSeparateAudio := false
+	for _, set := range period.AdaptationSets {
+		hasSeparateAudio = hasSeparateAudio || set.isAudio()
+	}
+	for _, set := range period.AdaptationSets {
+		setURL := resolveBase(periodURL, set.BaseURL)
+		for _, representation := range set.Representations {
+			isSegmented := representation.SegmentTemplate != nil || representation.SegmentList != nil || set.SegmentTemplate != nil || set.SegmentList != nil
+			if isSegmented || representation.BaseURL == "" {
+				continue
+			}
+			mimeType := firstNonEmpty(representation.MimeType, set.MimeType)
+			variant := Variant{
+				URL:      resolve(setURL, representation.BaseURL),
+				MimeType: mimeType,
+				Width:    firstNonZero(representation.Width, set.Width),
+				Height:   firstNonZero(representation.Height, set.Height),
+				Bitrate:  representation.Bandwidth / 1000,
+				Codecs:   firstNonEmpty(representation.Codecs, set.Codecs),
+			}
+			variant.IsAudio = strings.HasPrefix(mimeType, "audio/") || set.ContentType == "audio"
+			if !variant.IsAudio && (hasSeparateAudio || (!strings.HasPrefix(mimeType, "video/") && set.ContentType != "video")) {
+				continue
+			}
+			variants = append(variants, variant)
+		}
+	}
+	return
+}
+
+// isAudio determines if an adaptation set holds audio, which is given by its content type or the MIME type of the
+// set or of its representations.
+func (set *dashAdaptationSet) isAudio() bool {
+	if set.ContentType == "audio" || strings.HasPrefix(set.MimeType, "audio/") {
+		return true
+	}
+	for _, representation := range set.Representations {
+		if strings.HasPrefix(representation.MimeType, "audio/") {
+			return true
+		}
+	}
+	return false
+}
+
+func resolveBase(base *url.URL, reference string) *url.URL {
+	if reference == "" {
+		return base
+	}
+	resolved, err := base.Parse(strings.TrimSpace(reference))
+	if err != nil {
+		return base
+	}
+	return resolved
+}
+
+func resolve(base *url.URL, reference string) string {
+	return resolveBase(base, reference).String()
+}
+
+func firstNonZero(values ...int) int {
+	for _, value := range values {
+		if value != 0 {
+			return value
+		}
+	}
+	return 0
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func doHTTPGetRequest(URL string) (result []byte, err error) {
+	client := &http.Client{
+		Timeout: requestTimeout,
+	}
+	resp, err := client.Get(URL)
+	if err != nil {
+		log.Printf("Error during HTTP GET request for manifest %v: %v.", URL, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("Received HTTP status %v", resp.StatusCode)
+		return
+	}
+	return ioutil.ReadAll(resp.Body)
+}
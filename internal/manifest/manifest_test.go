@@ -0,0 +1,197 @@
+package manifest
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const masterPlaylist = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:BANDWIDTH=1200000,RESOLUTION=640x360,CODECS="avc1.4d401e,mp4a.40.2"
+360/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=3500000,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1280x720
+https://cdn.example.org/720/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=64000,CODECS="mp4a.40.2"
+audio/index.m3u8
+`
+
+const masterPlaylistWithAudioGroups = `#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="separate",NAME="Deutsch",LANGUAGE="de",URI="audio/de.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="muxed",NAME="Deutsch",LANGUAGE="de"
+#EXT-X-STREAM-INF:BANDWIDTH=3500000,RESOLUTION=1280x720,CODECS="avc1.640028,mp4a.40.2",AUDIO="separate"
+720/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=1200000,RESOLUTION=640x360,CODECS="avc1.4d401e,mp4a.40.2",AUDIO="muxed"
+360/index.m3u8
+`
+
+const mediaPlaylist = `#EXTM3U
+#EXT-X-TARGETDURATION:10
+#EXTINF:10.0,
+segment1.ts
+#EXTINF:10.0,
+segment2.ts
+#EXT-X-ENDLIST
+`
+
+const dashManifest = `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static">
+  <BaseURL>media/</BaseURL>
+  <Period>
+    <AdaptationSet mimeType="video/mp4" codecs="avc1.640028">
+      <Representation id="1" bandwidth="900000" width="640" height="360">
+        <BaseURL>video_360.mp4</BaseURL>
+        <SegmentBase indexRange="0-100"/>
+      </Representation>
+      <Representation id="2" bandwidth="4000000" width="1920" height="1080" codecs="hvc1.1.6.L120">
+        <SegmentTemplate media="1080/$Number$.m4s" initialization="1080/init.mp4"/>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet contentType="audio" mimeType="audio/mp4">
+      <Representation id="3" bandwidth="128000" codecs="mp4a.40.2">
+        <BaseURL>audio.m4a</BaseURL>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet mimeType="text/vtt">
+      <Representation id="4" bandwidth="100"><BaseURL>subtitles.vtt</BaseURL></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+
+func TestParseHLSMasterPlaylist(t *testing.T) {
+	variants, err := Parse("https://example.org/show/master.m3u8?token=1", []byte(masterPlaylist))
+	if err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	assertEquals(t, 3, len(variants))
+	assertEquals(t, Variant{
+		URL:      "https://example.org/show/360/index.m3u8",
+		MimeType: HLSMimeType,
+		Width:    640,
+		Height:   360,
+		Bitrate:  1200,
+		Codecs:   "avc1.4d401e,mp4a.40.2",
+	}, variants[0])
+	assertEquals(t, "https://cdn.example.org/720/index.m3u8", variants[1].URL)
+	assertEquals(t, 1280, variants[1].Width)
+	assertEquals(t, 3500, variants[1].Bitrate)
+	assertEquals(t, true, variants[2].IsAudio)
+}
+
+func TestParseHLSSkipsVariantsWithSeparateAudio(t *testing.T) {
+	variants, err := Parse("https://example.org/show/master.m3u8", []byte(masterPlaylistWithAudioGroups))
+	if err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	assertEquals(t, 1, len(variants))
+	assertEquals(t, "https://example.org/show/360/index.m3u8", variants[0].URL)
+}
+
+func TestParseHLSMediaPlaylist(t *testing.T) {
+	variants, err := Parse("https://example.org/show/index.m3u8", []byte(mediaPlaylist))
+	if err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	assertEquals(t, 1, len(variants))
+	assertEquals(t, Variant{URL: "https://example.org/show/index.m3u8", MimeType: HLSMimeType}, variants[0])
+}
+
+func TestParseDASH(t *testing.T) {
+	const manifestURL = "https://example.org/show/manifest.mpd"
+	variants, err := Parse(manifestURL, []byte(dashManifest))
+	if err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	// the video representation is silent, because the audio is a separate adaptation set
+	assertEquals(t, 1, len(variants))
+	assertEquals(t, "https://example.org/show/media/audio.m4a", variants[0].URL)
+	assertEquals(t, true, variants[0].IsAudio)
+}
+
+func TestParseDASHWithMuxedAudio(t *testing.T) {
+	const muxedManifest = `<MPD xmlns="urn:mpeg:dash:schema:mpd:2011">
+  <BaseURL>media/</BaseURL>
+  <Period>
+    <AdaptationSet mimeType="video/mp4" codecs="avc1.640028,mp4a.40.2">
+      <Representation id="1" bandwidth="900000" width="640" height="360"><BaseURL>video_360.mp4</BaseURL></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+	variants, err := Parse("https://example.org/show/manifest.mpd", []byte(muxedManifest))
+	if err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	assertEquals(t, 1, len(variants))
+	assertEquals(t, Variant{
+		URL:      "https://example.org/show/media/video_360.mp4",
+		MimeType: "video/mp4",
+		Width:    640,
+		Height:   360,
+		Bitrate:  900,
+		Codecs:   "avc1.640028,mp4a.40.2",
+	}, variants[0])
+}
+
+func TestParseDASHSkipsSegmentedRepresentations(t *testing.T) {
+	const segmentedManifest = `<MPD xmlns="urn:mpeg:dash:schema:mpd:2011">
+  <Period>
+    <AdaptationSet mimeType="video/mp4">
+      <SegmentTemplate media="$RepresentationID$/$Number$.m4s" initialization="$RepresentationID$/init.mp4"/>
+      <Representation id="360" bandwidth="900000" width="640" height="360"><BaseURL>360/</BaseURL></Representation>
+      <Representation id="1080" bandwidth="4000000" width="1920" height="1080"/>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+	variants, err := Parse("https://example.org/show/manifest.mpd", []byte(segmentedManifest))
+	if err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	assertEquals(t, 0, len(variants))
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	_, err := Parse("https://example.org/video.mp4", []byte("ftypisom"))
+	if err == nil {
+		t.Fatal("We expected an error.")
+	}
+}
+
+func TestReaderCachesVariants(t *testing.T) {
+	now := time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC)
+	requests := 0
+	fnGet := func(URL string) ([]byte, error) {
+		requests++
+		if URL != "https://example.org/index.m3u8" {
+			return nil, errors.New("test error")
+		}
+		return []byte(mediaPlaylist), nil
+	}
+	reader := CreateReaderWithGetFunction(time.Hour, fnGet, func() time.Time { return now })
+
+	reader.GetVariants("https://example.org/index.m3u8")
+	variants, _ := reader.GetVariants("https://example.org/index.m3u8")
+	assertEquals(t, 1, len(variants))
+	assertEquals(t, 1, requests)
+
+	now = now.Add(2 * time.Hour)
+	reader.GetVariants("https://example.org/index.m3u8")
+	assertEquals(t, 2, requests)
+
+	_, err := reader.GetVariants("https://example.org/other.m3u8")
+	if err == nil {
+		t.Fatal("We expected an error.")
+	}
+}
+
+func TestIsManifestMimeType(t *testing.T) {
+	assertEquals(t, true, IsManifestMimeType("application/x-mpegURL"))
+	assertEquals(t, true, IsManifestMimeType("application/vnd.apple.mpegurl"))
+	assertEquals(t, true, IsManifestMimeType("application/dash+xml"))
+	assertEquals(t, false, IsManifestMimeType("video/mp4"))
+}
+
+func assertEquals(t *testing.T, expected, actual interface{}) {
+	if actual != expected {
+		t.Fatalf("Expected %v but got %v.", expected, actual)
+	}
+}
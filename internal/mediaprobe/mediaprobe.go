@@ -0,0 +1,131 @@
+package mediaprobe
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const probeTimeout = 10 * time.Second
+
+// failureCacheDuration is how long failed probes are remembered, so dead URLs are not probed on every request.
+const failureCacheDuration = 5 * time.Minute
+
+// cleanupInterval is the minimum time between two removals of expired cache entries.
+const cleanupInterval = 10 * time.Minute
+
+// Metadata holds the information about a media file that is available without downloading it.
+type Metadata struct {
+	Length      int64
+	ContentType string
+}
+
+// Prober determines the metadata of media files via HTTP HEAD requests.
+// The results are cached per URL, so feeds can be refreshed without probing all media files again. Failures are
+// cached for a short time. Expired entries are removed regularly, so the cache does not grow without bound.
+// The prober is safe for concurrent use.
+//
+// Users should always create this via CreateProber to correctly initialize the cache.
+type Prober struct {
+	entries       sync.Map
+	entryDuration time.Duration
+	fnHead        func(string) (Metadata, error)
+	fnNow         func() time.Time
+
+	cleanupMutex sync.Mutex
+	nextCleanup  time.Time
+}
+
+type cacheValue struct {
+	ValidTo  time.Time
+	Metadata Metadata
+	Err      error // the error of a failed probe
+}
+
+// CreateProber creates a new prober that caches the metadata of a URL for the given cacheDuration.
+func CreateProber(cacheDuration time.Duration) *Prober {
+	return CreateProberWithHeadFunction(cacheDuration, doHTTPHeadRequest, time.Now)
+}
+
+// CreateProberWithHeadFunction creates a new prober with a user defined function for retrieving the metadata of a URL
+// as well as a user defined now function.
+func CreateProberWithHeadFunction(cacheDuration time.Duration, fnHead func(string) (Metadata, error), fnNow func() time.Time) *Prober {
+	return &Prober{
+		entryDuration: cacheDuration,
+		fnHead:        fnHead,
+		fnNow:         fnNow,
+	}
+}
+
+// Probe determines the length in bytes and the MIME type of the media file at the given URL.
+// Unknown values are zero or empty respectively.
+func (prober *Prober) Probe(URL string) (length int64, contentType string, err error) {
+	now := prober.fnNow()
+	loadResult, found := prober.entries.Load(URL)
+	if found {
+		entry := loadResult.(cacheValue)
+		if !now.After(entry.ValidTo) {
+			return entry.Metadata.Length, entry.Metadata.ContentType, entry.Err
+		}
+		prober.entries.Delete(URL)
+	}
+
+	metadata, err := prober.fnHead(URL)
+	entry := cacheValue{
+		ValidTo:  now.Add(prober.entryDuration),
+		Metadata: metadata,
+		Err:      err,
+	}
+	if err != nil {
+		entry.ValidTo = now.Add(failureCacheDuration)
+	}
+	prober.entries.Store(URL, entry)
+	prober.removeExpiredEntries(now)
+	return metadata.Length, metadata.ContentType, err
+}
+
+// removeExpiredEntries deletes the expired entries from the cache if the last cleanup is long enough ago.
+func (prober *Prober) removeExpiredEntries(now time.Time) {
+	prober.cleanupMutex.Lock()
+	if now.Before(prober.nextCleanup) {
+		prober.cleanupMutex.Unlock()
+		return
+	}
+	prober.nextCleanup = now.Add(cleanupInterval)
+	prober.cleanupMutex.Unlock()
+
+	prober.entries.Range(func(key, value interface{}) bool {
+		if now.After(value.(cacheValue).ValidTo) {
+			prober.entries.Delete(key)
+		}
+		return true
+	})
+}
+
+func doHTTPHeadRequest(URL string) (metadata Metadata, err error) {
+	client := &http.Client{
+		Timeout: probeTimeout,
+	}
+	resp, err := client.Head(URL)
+	if err != nil {
+		log.Printf("Error during HTTP HEAD request for URL %v: %v.", URL, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		err = fmt.Errorf("Received HTTP status %v", resp.StatusCode)
+		return
+	}
+
+	if resp.ContentLength > 0 {
+		metadata.Length = resp.ContentLength
+	}
+	mediaType, _, parseErr := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if parseErr == nil {
+		metadata.ContentType = mediaType
+	}
+	return
+}
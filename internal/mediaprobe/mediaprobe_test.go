@@ -0,0 +1,115 @@
+package mediaprobe
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProbeIsCached(t *testing.T) {
+	now := time.Unix(0, 0)
+	fnNow := func() time.Time {
+		return now
+	}
+	calls := 0
+	fnHead := func(URL string) (Metadata, error) {
+		calls++
+		return Metadata{Length: 42, ContentType: "video/mp4"}, nil
+	}
+	prober := CreateProberWithHeadFunction(time.Minute, fnHead, fnNow)
+
+	length, contentType, err := prober.Probe("https://foo.bar/video.mp4")
+	if err != nil {
+		t.Fatal("We did not expect an error.")
+	}
+	assertEquals(t, int64(42), length)
+	assertEquals(t, "video/mp4", contentType)
+	prober.Probe("https://foo.bar/video.mp4")
+	assertEquals(t, 1, calls)
+
+	now = now.Add(time.Minute + 1)
+	prober.Probe("https://foo.bar/video.mp4")
+	assertEquals(t, 2, calls)
+}
+
+func TestProbeErrorsAreCachedBriefly(t *testing.T) {
+	now := time.Unix(0, 0)
+	fnNow := func() time.Time {
+		return now
+	}
+	calls := 0
+	fnHead := func(URL string) (Metadata, error) {
+		calls++
+		return Metadata{}, errors.New("test error")
+	}
+	prober := CreateProberWithHeadFunction(time.Hour, fnHead, fnNow)
+
+	_, _, err := prober.Probe("https://foo.bar/video.mp4")
+	if err == nil {
+		t.Fatal("There should be an error.")
+	}
+	_, _, err = prober.Probe("https://foo.bar/video.mp4")
+	if err == nil {
+		t.Fatal("There should be an error.")
+	}
+	assertEquals(t, 1, calls)
+
+	now = now.Add(failureCacheDuration + 1)
+	prober.Probe("https://foo.bar/video.mp4")
+	assertEquals(t, 2, calls)
+}
+
+func TestExpiredEntriesAreRemoved(t *testing.T) {
+	now := time.Unix(0, 0)
+	fnNow := func() time.Time {
+		return now
+	}
+	fnHead := func(URL string) (Metadata, error) {
+		return Metadata{Length: 42, ContentType: "video/mp4"}, nil
+	}
+	prober := CreateProberWithHeadFunction(time.Minute, fnHead, fnNow)
+	prober.Probe("https://foo.bar/1.mp4")
+	prober.Probe("https://foo.bar/2.mp4")
+
+	now = now.Add(cleanupInterval + 1)
+	prober.Probe("https://foo.bar/3.mp4")
+	entries := 0
+	prober.entries.Range(func(key, value interface{}) bool {
+		entries++
+		return true
+	})
+	assertEquals(t, 1, entries)
+}
+
+func TestDoHTTPHeadRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertEquals(t, "HEAD", r.Method)
+		if r.URL.Path == "/missing.mp4" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "video/mp4; charset=binary")
+		w.Header().Set("Content-Length", "1234")
+	}))
+	defer server.Close()
+
+	metadata, err := doHTTPHeadRequest(server.URL + "/video.mp4")
+	if err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	assertEquals(t, int64(1234), metadata.Length)
+	assertEquals(t, "video/mp4", metadata.ContentType)
+
+	_, err = doHTTPHeadRequest(server.URL + "/missing.mp4")
+	if err == nil {
+		t.Fatal("There should be an error.")
+	}
+}
+
+func assertEquals(t *testing.T, expected, actual interface{}) {
+	if actual != expected {
+		t.Fatalf("Expected %v but got %v.", expected, actual)
+	}
+}
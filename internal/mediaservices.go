@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"log"
+	"sync"
+
+	"github.com/seiferma/docker_mediathek2rss/internal/manifest"
+)
+
+// AudioExtractor provides URLs to the audio tracks of video files.
+type AudioExtractor interface {
+	// GetAudioURL yields the URL of the audio track of the given video in the given format and its MIME type.
+	GetAudioURL(videoURL string, format string) (URL, mimeType string)
+	// OwnsURL determines if the given URL has been created by the extractor.
+	OwnsURL(URL string) bool
+}
+
+// MediaProber determines the metadata of media files.
+type MediaProber interface {
+	// Probe yields the length in bytes and the MIME type of the media file at the given URL.
+	// Unknown values are zero or empty respectively.
+	Probe(URL string) (length int64, contentType string, err error)
+}
+
+// ManifestReader yields the variants of adaptive streams.
+type ManifestReader interface {
+	// GetVariants yields the variants of the HLS or DASH manifest at the given URL.
+	GetVariants(URL string) ([]manifest.Variant, error)
+}
+
+// VideoRemuxer provides URLs to MP4 files that contain adaptive streams.
+type VideoRemuxer interface {
+	// GetVideoURL yields the URL of an MP4 file containing the given HLS or DASH stream and its MIME type.
+	GetVideoURL(manifestURL string) (URL, mimeType string)
+	// OwnsURL determines if the given URL has been created by the remuxer.
+	OwnsURL(URL string) bool
+}
+
+// maxConcurrentProbes is the number of media files that are probed at the same time when a feed is created.
+const maxConcurrentProbes = 8
+
+// MediaServices holds optional services that the feed builders use when resolving media.
+// Services that are not configured are nil.
+type MediaServices struct {
+	AudioExtractor AudioExtractor
+	Prober         MediaProber
+	ManifestReader ManifestReader
+	VideoRemuxer   VideoRemuxer
+}
+
+// GetAudioFromVideo yields the URL and MIME type of the audio track of the given video if an AudioExtractor is configured.
+// Otherwise, the video itself is returned.
+func (services *MediaServices) GetAudioFromVideo(videoURL, videoMimeType string, parameters RequestParameters) (URL, mimeType string) {
+	if services.AudioExtractor == nil || videoURL == "" {
+		return videoURL, videoMimeType
+	}
+	return services.AudioExtractor.GetAudioURL(videoURL, parameters.AudioFormat)
+}
+
+// GetVideoFromManifest yields the URL and MIME type of an MP4 file containing the given adaptive stream if a
+// VideoRemuxer is configured. Otherwise, the manifest itself is returned.
+func (services *MediaServices) GetVideoFromManifest(manifestURL, manifestMimeType string) (URL, mimeType string) {
+	if services.VideoRemuxer == nil || manifestURL == "" || !manifest.IsManifestMimeType(manifestMimeType) {
+		return manifestURL, manifestMimeType
+	}
+	return services.VideoRemuxer.GetVideoURL(manifestURL)
+}
+
+// CreateMediaVariant creates the media variant for a media file with the expected MIME type.
+//
+// If a MediaProber is configured, the length of the media file is filled and the MIME type is replaced by the
+// exact type of the media file. Content types that do not describe audio or video are reported but not used.
+// Manifests of adaptive streams and files created by the media services are not probed.
+func (services *MediaServices) CreateMediaVariant(URL, mimeType string) *MediaVariant {
+	variant := &MediaVariant{
+		URL:      URL,
+		MimeType: mimeType,
+	}
+	if services.Prober == nil || URL == "" || manifest.IsManifestMimeType(mimeType) {
+		return variant
+	}
+	if services.AudioExtractor != nil && services.AudioExtractor.OwnsURL(URL) {
+		return variant
+	}
+	if services.VideoRemuxer != nil && services.VideoRemuxer.OwnsURL(URL) {
+		return variant
+	}
+
+	length, contentType, err := services.Prober.Probe(URL)
+	if err != nil {
+		log.Printf("Could not probe media file %v: %v", URL, err)
+		return variant
+	}
+	variant.Length = length
+	if IsAudioMimeType(contentType) || IsVideoMimeType(contentType) {
+		variant.MimeType = contentType
+	} else if contentType != "" && contentType != "application/octet-stream" {
+		log.Printf("Media file %v has the unexpected content type %v instead of %v.", URL, contentType, mimeType)
+	}
+	return variant
+}
+
+// CompleteMediaVariants replaces the media variants of the given episodes by the variants of CreateMediaVariant.
+// The media files are probed concurrently, but at most maxConcurrentProbes at the same time. Episodes without media
+// file are left as they are.
+func (services *MediaServices) CompleteMediaVariants(episodes []Episode) {
+	semaphore := make(chan struct{}, maxConcurrentProbes)
+	var waitGroup sync.WaitGroup
+	for i := range episodes {
+		episode := &episodes[i]
+		if episode.Media == nil || episode.Media.URL == "" {
+			continue
+		}
+		waitGroup.Add(1)
+		semaphore <- struct{}{}
+		go func() {
+			defer waitGroup.Done()
+			episode.Media = services.CreateMediaVariant(episode.Media.URL, episode.Media.MimeType)
+			<-semaphore
+		}()
+	}
+	waitGroup.Wait()
+}
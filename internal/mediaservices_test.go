@@ -0,0 +1,186 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type audioExtractorMock struct{}
+
+func (extractor audioExtractorMock) GetAudioURL(videoURL string, format string) (URL, mimeType string) {
+	return videoURL + "." + format, "audio/" + format
+}
+
+func (extractor audioExtractorMock) OwnsURL(URL string) bool {
+	return strings.HasSuffix(URL, ".mp3")
+}
+
+type videoRemuxerMock struct{}
+
+func (remuxer videoRemuxerMock) GetVideoURL(manifestURL string) (URL, mimeType string) {
+	return manifestURL + ".mp4", VideoMimeType
+}
+
+func (remuxer videoRemuxerMock) OwnsURL(URL string) bool {
+	return strings.HasSuffix(URL, ".m3u8.mp4")
+}
+
+type proberMock struct {
+	length      int64
+	contentType string
+	err         error
+}
+
+func (prober proberMock) Probe(URL string) (length int64, contentType string, err error) {
+	return prober.length, prober.contentType, prober.err
+}
+
+func TestGetAudioFromVideoWithoutExtractor(t *testing.T) {
+	services := MediaServices{}
+	URL, mimeType := services.GetAudioFromVideo("https://foo.bar/video.mp4", VideoMimeType, RequestParameters{AudioFormat: "mp3"})
+	assertEquals(t, "https://foo.bar/video.mp4", URL)
+	assertEquals(t, VideoMimeType, mimeType)
+}
+
+func TestGetAudioFromVideoWithExtractor(t *testing.T) {
+	services := MediaServices{
+		AudioExtractor: audioExtractorMock{},
+	}
+	URL, mimeType := services.GetAudioFromVideo("https://foo.bar/video.mp4", VideoMimeType, RequestParameters{AudioFormat: "mp3"})
+	assertEquals(t, "https://foo.bar/video.mp4.mp3", URL)
+	assertEquals(t, "audio/mp3", mimeType)
+
+	URL, _ = services.GetAudioFromVideo("", VideoMimeType, RequestParameters{AudioFormat: "mp3"})
+	assertEquals(t, "", URL)
+}
+
+func TestCreateMediaVariantWithoutProber(t *testing.T) {
+	services := MediaServices{}
+	variant := services.CreateMediaVariant("https://foo.bar/video.mp4", VideoMimeType)
+	assertEquals(t, "https://foo.bar/video.mp4", variant.URL)
+	assertEquals(t, VideoMimeType, variant.MimeType)
+	assertEquals(t, "0", fmt.Sprint(variant.Length))
+}
+
+func TestCreateMediaVariantWithProber(t *testing.T) {
+	services := MediaServices{
+		Prober: proberMock{length: 1234, contentType: "video/webm"},
+	}
+	variant := services.CreateMediaVariant("https://foo.bar/video.mp4", VideoMimeType)
+	assertEquals(t, "video/webm", variant.MimeType)
+	assertEquals(t, "1234", fmt.Sprint(variant.Length))
+}
+
+func TestCreateMediaVariantWithUnexpectedContentType(t *testing.T) {
+	services := MediaServices{
+		Prober: proberMock{length: 1234, contentType: "text/html"},
+	}
+	variant := services.CreateMediaVariant("https://foo.bar/video.mp4", VideoMimeType)
+	assertEquals(t, VideoMimeType, variant.MimeType)
+	assertEquals(t, "1234", fmt.Sprint(variant.Length))
+}
+
+func TestCreateMediaVariantWithProbeError(t *testing.T) {
+	services := MediaServices{
+		Prober: proberMock{err: errors.New("test error")},
+	}
+	variant := services.CreateMediaVariant("https://foo.bar/video.mp4", VideoMimeType)
+	assertEquals(t, VideoMimeType, variant.MimeType)
+	assertEquals(t, "0", fmt.Sprint(variant.Length))
+}
+
+func TestCreateMediaVariantForExtractedAudio(t *testing.T) {
+	services := MediaServices{
+		AudioExtractor: audioExtractorMock{},
+		Prober:         proberMock{length: 1234, contentType: "text/html"},
+	}
+	variant := services.CreateMediaVariant("https://foo.bar/video.mp4.mp3", "audio/mpeg")
+	assertEquals(t, "audio/mpeg", variant.MimeType)
+	assertEquals(t, "0", fmt.Sprint(variant.Length))
+}
+
+// concurrencyProberMock records the maximum number of concurrent probes.
+type concurrencyProberMock struct {
+	mutex   sync.Mutex
+	running int
+	maximum int
+}
+
+func (prober *concurrencyProberMock) Probe(URL string) (length int64, contentType string, err error) {
+	prober.mutex.Lock()
+	prober.running++
+	if prober.running > prober.maximum {
+		prober.maximum = prober.running
+	}
+	prober.mutex.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	prober.mutex.Lock()
+	prober.running--
+	prober.mutex.Unlock()
+	return 1234, VideoMimeType, nil
+}
+
+func TestCompleteMediaVariants(t *testing.T) {
+	prober := &concurrencyProberMock{}
+	services := MediaServices{Prober: prober}
+	episodes := make([]Episode, 3*maxConcurrentProbes)
+	for i := range episodes {
+		episodes[i].Media = &MediaVariant{URL: fmt.Sprintf("https://foo.bar/%v.mp4", i), MimeType: VideoMimeType}
+	}
+	episodes[0].Media = &MediaVariant{}
+	episodes[1].Media = nil
+
+	services.CompleteMediaVariants(episodes)
+	assertEquals(t, "0", fmt.Sprint(episodes[0].Media.Length))
+	if episodes[1].Media != nil {
+		t.Fatal("Episodes without media file should be left as they are.")
+	}
+	for _, episode := range episodes[2:] {
+		assertEquals(t, "1234", fmt.Sprint(episode.Media.Length))
+	}
+	if prober.maximum > maxConcurrentProbes || prober.maximum < 2 {
+		t.Fatalf("Expected concurrent probes up to %v but got %v.", maxConcurrentProbes, prober.maximum)
+	}
+}
+
+func TestGetVideoFromManifestWithoutRemuxer(t *testing.T) {
+	services := MediaServices{}
+	URL, mimeType := services.GetVideoFromManifest("https://foo.bar/master.m3u8", "application/x-mpegURL")
+	assertEquals(t, "https://foo.bar/master.m3u8", URL)
+	assertEquals(t, "application/x-mpegURL", mimeType)
+}
+
+func TestGetVideoFromManifestWithRemuxer(t *testing.T) {
+	services := MediaServices{
+		VideoRemuxer: videoRemuxerMock{},
+	}
+	URL, mimeType := services.GetVideoFromManifest("https://foo.bar/master.m3u8", "application/x-mpegURL")
+	assertEquals(t, "https://foo.bar/master.m3u8.mp4", URL)
+	assertEquals(t, VideoMimeType, mimeType)
+
+	URL, _ = services.GetVideoFromManifest("https://foo.bar/video.mp4", VideoMimeType)
+	assertEquals(t, "https://foo.bar/video.mp4", URL)
+}
+
+func TestCreateMediaVariantForManifest(t *testing.T) {
+	services := MediaServices{
+		Prober: proberMock{length: 1234, contentType: "application/vnd.apple.mpegurl"},
+	}
+	variant := services.CreateMediaVariant("https://foo.bar/master.m3u8", "application/x-mpegURL")
+	assertEquals(t, "application/x-mpegURL", variant.MimeType)
+	assertEquals(t, "0", fmt.Sprint(variant.Length))
+}
+
+func TestCreateMediaVariantForRemuxedVideo(t *testing.T) {
+	services := MediaServices{
+		VideoRemuxer: videoRemuxerMock{},
+		Prober:       proberMock{length: 1234, contentType: "text/html"},
+	}
+	variant := services.CreateMediaVariant("https://foo.bar/master.m3u8.mp4", VideoMimeType)
+	assertEquals(t, VideoMimeType, variant.MimeType)
+	assertEquals(t, "0", fmt.Sprint(variant.Length))
+}
@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// VideoMimeType is the MIME type of the video files served by the feeds.
+const VideoMimeType = "video/mp4"
+
+var audioMimeTypesByExtension = map[string](string){
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".opus": "audio/opus",
+}
+
+// GetAudioMimeTypeFromURL determines the MIME type of an audio file based on the file extension in the given URL.
+// If the URL does not point to a known audio format, found will be false.
+func GetAudioMimeTypeFromURL(URL string) (mimeType string, found bool) {
+	parsedURL, err := url.Parse(URL)
+	if err != nil {
+		return
+	}
+	extension := strings.ToLower(path.Ext(parsedURL.Path))
+	mimeType, found = audioMimeTypesByExtension[extension]
+	return
+}
+
+// IsAudioMimeType determines if the given MIME type describes audio content.
+func IsAudioMimeType(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "audio/")
+}
+
+// IsVideoMimeType determines if the given MIME type describes video content.
+func IsVideoMimeType(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "video/")
+}
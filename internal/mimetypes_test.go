@@ -0,0 +1,28 @@
+package internal
+
+import "testing"
+
+func TestGetAudioMimeTypeFromURL(t *testing.T) {
+	assertGetAudioMimeTypeFromURL(t, "https://foo.bar/file.mp3", "audio/mpeg", true)
+	assertGetAudioMimeTypeFromURL(t, "https://foo.bar/file.M4A?fv=1", "audio/mp4", true)
+	assertGetAudioMimeTypeFromURL(t, "https://foo.bar/file.aac", "audio/aac", true)
+	assertGetAudioMimeTypeFromURL(t, "https://foo.bar/file.mp4", "", false)
+	assertGetAudioMimeTypeFromURL(t, "https://foo.bar/mp3", "", false)
+}
+
+func TestIsAudioMimeType(t *testing.T) {
+	if !IsAudioMimeType("audio/mp4") {
+		t.Fatal("audio/mp4 should be an audio MIME type.")
+	}
+	if IsAudioMimeType("video/mp4") {
+		t.Fatal("video/mp4 should not be an audio MIME type.")
+	}
+}
+
+func assertGetAudioMimeTypeFromURL(t *testing.T, URL, expectedMimeType string, expectedFound bool) {
+	actualMimeType, actualFound := GetAudioMimeTypeFromURL(URL)
+	if actualFound != expectedFound {
+		t.Fatalf("Expected found to be %v for %v but got %v.", expectedFound, URL, actualFound)
+	}
+	assertEquals(t, expectedMimeType, actualMimeType)
+}
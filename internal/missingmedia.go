@@ -0,0 +1,40 @@
+package internal
+
+import (
+	"expvar"
+	"log"
+)
+
+// Values of the onMissingMedia request parameter
+const (
+	// MissingMediaSkip drops episodes without media file from the feed.
+	MissingMediaSkip = "skip"
+	// MissingMediaKeep keeps episodes without media file with an empty media file.
+	MissingMediaKeep = "keep"
+	// MissingMediaLink keeps episodes without media file without enclosure but with a link to the episode in the description.
+	MissingMediaLink = "link"
+)
+
+// missingMediaEpisodes counts the episodes without media file per provider. The counters are published at /debug/vars.
+var missingMediaEpisodes = expvar.NewMap("missingMediaEpisodes")
+
+// HandleMissingMedia reports an episode of a provider whose media file could not be resolved in the log and the
+// metrics. It prepares the episode according to the policy and yields false if the episode shall be dropped from
+// the feed.
+func HandleMissingMedia(provider, showID string, episode *Episode, policy string, reason string) (keepEpisode bool) {
+	missingMediaEpisodes.Add(provider, 1)
+	log.Printf("Found no media file for episode %v of %v show %v (%v), applying policy %v.", episode.ID, provider, showID, reason, policy)
+
+	switch policy {
+	case MissingMediaKeep:
+		episode.Media = &MediaVariant{}
+		return true
+	case MissingMediaLink:
+		episode.Media = nil
+		if episode.Link != "" {
+			appendToDescription(episode, episode.Link)
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"expvar"
+	"testing"
+)
+
+func TestHandleMissingMediaSkip(t *testing.T) {
+	episode := createEpisodeWithoutMedia()
+	before := getMissingMediaCount("test-skip")
+	if HandleMissingMedia("test-skip", "show", &episode, MissingMediaSkip, "no suitable stream") {
+		t.Fatal("The episode should be dropped.")
+	}
+	if getMissingMediaCount("test-skip") != before+1 {
+		t.Fatal("The episode should be counted.")
+	}
+}
+
+func TestHandleMissingMediaKeep(t *testing.T) {
+	episode := createEpisodeWithoutMedia()
+	if !HandleMissingMedia("test", "show", &episode, MissingMediaKeep, "no suitable stream") {
+		t.Fatal("The episode should be kept.")
+	}
+	assertEquals(t, "", episode.Media.URL)
+	assertEquals(t, "Synopsis", episode.Description)
+}
+
+func TestHandleMissingMediaLink(t *testing.T) {
+	episode := createEpisodeWithoutMedia()
+	if !HandleMissingMedia("test", "show", &episode, MissingMediaLink, "no suitable stream") {
+		t.Fatal("The episode should be kept.")
+	}
+	if episode.Media != nil {
+		t.Fatal("The episode should have no media file.")
+	}
+	assertEquals(t, "Synopsis\n\nhttps://foo.bar/episode", episode.Description)
+}
+
+func createEpisodeWithoutMedia() Episode {
+	return Episode{
+		ID:          "episode",
+		Link:        "https://foo.bar/episode",
+		Description: "Synopsis",
+	}
+}
+
+func getMissingMediaCount(provider string) int64 {
+	counter := missingMediaEpisodes.Get(provider)
+	if counter == nil {
+		return 0
+	}
+	return counter.(*expvar.Int).Value()
+}
@@ -0,0 +1,76 @@
+package internal
+
+import "time"
+
+// DefaultITunesCategory is the category of the Apple Podcasts directory for shows without more specific category.
+const DefaultITunesCategory = "TV & Film"
+
+// MIME types of subtitle files
+const (
+	WebVTTMimeType = "text/vtt"
+	TTMLMimeType   = "application/ttml+xml"
+)
+
+// Show is a show of a television channel with its episodes. The providers produce shows independent of the feed
+// format, so filters, annotations and the order of the episodes apply to all formats.
+type Show struct {
+	ProviderID  string // the provider that serves the show, e.g. ard
+	ID          string // the ID of the show within its provider including the route, e.g. byPath/comedy/zdf-magazin-royale
+	FeedURL     string // the URL of the requested feed, empty if unknown
+	Title       string
+	Description string
+	Link        string
+	Image       Image
+	Author      string // the television channel that publishes the show, e.g. ZDFneo or funk
+	Category    string // the category of the Apple Podcasts directory, e.g. Comedy
+	Language    string // the ISO 639-1 code of the language of the show, e.g. fr, empty for German
+	Serial      bool   // the episodes are meant to be consumed in order
+	Episodes    []Episode
+	Details     interface{} // data of the API of the provider, which is not part of the feed
+}
+
+// Image is an image of a show or an episode.
+type Image struct {
+	URL   string
+	Title string
+}
+
+// Episode is an episode of a show.
+type Episode struct {
+	ID                string
+	Title             string
+	Description       string
+	Link              string
+	Image             Image
+	Date              time.Time
+	DurationInSeconds int
+	AvailableFrom     time.Time // zero if the episode is available without start
+	AvailableTo       time.Time // zero if the episode is available without time limit
+	Season            int       // zero if the episode belongs to no season
+	Number            int       // zero if the episode has no number
+	Type              string    // the episode type of iTunes, i.e. full, trailer or bonus
+	Restrictions      Restrictions
+	Media             *MediaVariant // nil if the episode has no media file
+	Subtitles         []Subtitle
+	Details           interface{} // data of the API of the provider, which is not part of the feed
+}
+
+// MediaVariant is the media file of an episode that has been selected according to the request parameters.
+type MediaVariant struct {
+	URL      string
+	MimeType string
+	Length   int64 // the length in bytes or zero if unknown
+}
+
+// Subtitle is a subtitle file of an episode.
+type Subtitle struct {
+	URL      string
+	MimeType string // e.g. text/vtt
+	Language string // the ISO 639-2 code of the language, e.g. deu
+}
+
+// IsExplicit determines if an episode shall be marked as explicit, which is the case for episodes rated FSK 16 or
+// higher.
+func (episode *Episode) IsExplicit() bool {
+	return episode.Restrictions.MinimumAge >= minimumExplicitAge
+}
@@ -0,0 +1,409 @@
+package nexxapi
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const apiBase = "https://api.nexx.cloud/v3"
+
+const requestTimeout = 10 * time.Second
+
+// domain of funk at nexx, which is required for all requests together with its hash
+const funkDomainID = 741
+const funkDomainHash = "CA4SDGOBTRM421IRNO0"
+
+// fileDistributionRegex matches the files of a video in the file distribution of the Azure CDN, e.g.
+// 3001:1280x720:2-w7FWPztXm9hnZpbjMcKq, and yields their bitrate, width, height and selector.
+var fileDistributionRegex = regexp.MustCompile("([0-9]+):([0-9]+)x([0-9]+):([^:,]+)")
+
+// NexxAPI gives access to the videos of a domain of the nexx API, e.g. funk.
+// It holds the session of the domain, so the session is only initialized once and shared by all requests. The API is
+// safe for concurrent use.
+type NexxAPI struct {
+	domainID       int
+	domainHash     string
+	fnPostRequest  func(string, url.Values, map[string]string) ([]byte, error)
+	sessionMutex   sync.Mutex
+	cid            string       // the ID of the current session, empty if no session has been initialized
+	pendingSession *sessionInit // the running initialization of a session, nil if there is none
+}
+
+// sessionInit is an initialization of a session, whose result is shared by all requests waiting for it.
+type sessionInit struct {
+	done chan struct{} // closed when cid and err are set
+	cid  string
+	err  error
+}
+
+// statusError is the error of a request that the API answers with an error status, e.g. 404 for unknown videos.
+type statusError struct {
+	Status    int
+	ErrorHint string
+	URL       string
+}
+
+func (err *statusError) Error() string {
+	return fmt.Sprintf("received status %v (%v) for URL %v", err.Status, err.ErrorHint, err.URL)
+}
+
+// StreamData represents the DTO describing where the files of a video are stored.
+type StreamData struct {
+	CdnType               string
+	CdnShieldHTTPS        string
+	QAccount              string
+	QPrefix               string
+	QLocator              string
+	AzureFileDistribution string // e.g. 3001:1280x720:2-w7FWPztXm9hnZpbjMcKq,4152:1920x1080:1-Nf3kJwdpTrgHGLY2jtPv
+}
+
+// ImageData represents the DTO of the images of a video or a collection of videos.
+type ImageData struct {
+	Thumb string `json:"thumb"`
+}
+
+// Video represents a DTO for a video from the API.
+type Video struct {
+	General struct {
+		ID          int    `json:"ID"`
+		Title       string `json:"title"`
+		Subtitle    string `json:"subtitle"`
+		Teaser      string `json:"teaser"`
+		Description string `json:"description"`
+		Slug        string `json:"slug"`
+		Runtime     string `json:"runtime"`  // e.g. 00:12:34
+		Uploaded    int64  `json:"uploaded"` // the Unix time of the upload
+	} `json:"general"`
+	ImageData  ImageData  `json:"imagedata"`
+	StreamData StreamData `json:"streamdata"`
+}
+
+// Collection represents a DTO for a channel or a series, which group the videos of a format.
+type Collection struct {
+	General struct {
+		ID          int    `json:"ID"`
+		Title       string `json:"title"`
+		Subtitle    string `json:"subtitle"`
+		Description string `json:"description"`
+		Slug        string `json:"slug"`
+	} `json:"general"`
+	ImageData ImageData `json:"imagedata"`
+}
+
+// Stream represents an MP4 file of a video in one resolution.
+type Stream struct {
+	CDN     string
+	URL     string
+	Width   int
+	Height  int
+	Bitrate int // in kbit/s
+}
+
+type response struct {
+	Metadata struct {
+		Status    int    `json:"status"`
+		ErrorHint string `json:"errorhint"`
+	} `json:"metadata"`
+	Result json.RawMessage `json:"result"`
+}
+
+type initSessionResult struct {
+	General struct {
+		Cid string
+	}
+}
+
+// CreateFunkAPI creates a new API instance for the videos of funk.
+func CreateFunkAPI() *NexxAPI {
+	return CreateNexxAPIWithPostFunc(funkDomainID, funkDomainHash, doPostRequest)
+}
+
+// CreateNexxAPIWithPostFunc creates a new API instance for the videos of the domain with the given ID and hash.
+// The fnPostRequest parameter provides a function that carries out a post request with the given form data and
+// headers and provides the body as byte array.
+func CreateNexxAPIWithPostFunc(domainID int, domainHash string, fnPostRequest func(string, url.Values, map[string]string) ([]byte, error)) *NexxAPI {
+	return &NexxAPI{
+		domainID:      domainID,
+		domainHash:    domainHash,
+		fnPostRequest: fnPostRequest,
+	}
+}
+
+// GetVideo retrieves a video including the data about its streams by its ID, e.g. 1707442.
+func (api *NexxAPI) GetVideo(videoID string) (result Video, err error) {
+	postData := url.Values{
+		"addStatusDetails": {"1"},
+		"addStreamDetails": {"1"},
+		"addFeatures":      {"1"},
+		"addCaptions":      {"1"},
+		"addBumpers":       {"1"},
+		"captionFormat":    {"data"},
+	}
+	err = api.post("byid", fmt.Sprintf("videos/byid/%v", videoID), postData, &result)
+	return
+}
+
+// GetChannel retrieves a channel by its ID, e.g. 1031.
+func (api *NexxAPI) GetChannel(channelID string) (result Collection, err error) {
+	err = api.getCollection("channels", channelID, &result)
+	return
+}
+
+// GetSeries retrieves a series by its ID.
+func (api *NexxAPI) GetSeries(seriesID string) (result Collection, err error) {
+	err = api.getCollection("series", seriesID, &result)
+	return
+}
+
+// GetChannelVideos retrieves up to maxVideos videos of a channel including the data about their streams from newest
+// to oldest.
+func (api *NexxAPI) GetChannelVideos(channelID string, maxVideos int) (result []Video, err error) {
+	err = api.post("bychannel", fmt.Sprintf("videos/bychannel/%v", channelID), createListData(maxVideos), &result)
+	return
+}
+
+// GetSeriesVideos retrieves up to maxVideos videos of a series including the data about their streams from newest to
+// oldest.
+func (api *NexxAPI) GetSeriesVideos(seriesID string, maxVideos int) (result []Video, err error) {
+	err = api.post("byseries", fmt.Sprintf("videos/byseries/%v", seriesID), createListData(maxVideos), &result)
+	return
+}
+
+func createListData(maxVideos int) url.Values {
+	return url.Values{
+		"addStreamDetails": {"1"},
+		"limit":            {strconv.Itoa(maxVideos)},
+		"order":            {"desc"},
+		"orderBy":          {"uploaded"},
+	}
+}
+
+func (api *NexxAPI) getCollection(kind, ID string, result *Collection) (err error) {
+	err = api.post("byid", fmt.Sprintf("%v/byid/%v", kind, ID), url.Values{}, result)
+	if err == nil && result.General.Title == "" {
+		err = fmt.Errorf("there is no %v with ID %v", kind, ID)
+	}
+	return
+}
+
+// post carries out the given operation of the API at the given path within the domain and parses the result into the
+// given DTO. The request uses the current session. If the API rejects the session, the session is discarded and the
+// request is repeated once with a new session in case the session has expired. Other errors keep the session.
+func (api *NexxAPI) post(operation, path string, postData url.Values, result interface{}) (err error) {
+	cid, err := api.postWithSession(operation, path, postData, result)
+	if isSessionError(err) {
+		log.Printf("The session has been rejected for operation %v at path %v. Retrying with a new session.", operation, path)
+		api.discardSession(cid)
+		cid, err = api.postWithSession(operation, path, postData, result)
+		if isSessionError(err) {
+			api.discardSession(cid)
+		}
+	}
+	return
+}
+
+// postWithSession carries out the given operation of the API with the current session and yields the ID of the session.
+func (api *NexxAPI) postWithSession(operation, path string, postData url.Values, result interface{}) (cid string, err error) {
+	cid, err = api.getSession()
+	if err != nil {
+		return
+	}
+	headers := map[string]string{
+		"x-request-cid":   cid,
+		"x-request-token": getMD5Hash(fmt.Sprintf("%v%v%v", operation, api.domainID, api.domainHash)),
+	}
+	URL := fmt.Sprintf("%v/%v/%v", apiBase, api.domainID, path)
+	err = api.doRequest(URL, postData, headers, result)
+	return
+}
+
+// isSessionError determines if an error is caused by a session that the API does not accept (anymore).
+func isSessionError(err error) bool {
+	var statusErr *statusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	return statusErr.Status == http.StatusUnauthorized || statusErr.Status == http.StatusForbidden
+}
+
+// getSession yields the ID of the current session and initializes a session if there is none. The mutex is not held
+// while the session is initialized, so concurrent requests wait for the running initialization instead of the mutex.
+func (api *NexxAPI) getSession() (cid string, err error) {
+	api.sessionMutex.Lock()
+	if api.cid != "" {
+		cid = api.cid
+		api.sessionMutex.Unlock()
+		return
+	}
+	pending := api.pendingSession
+	if pending != nil {
+		api.sessionMutex.Unlock()
+		<-pending.done
+		return pending.cid, pending.err
+	}
+	pending = &sessionInit{done: make(chan struct{})}
+	api.pendingSession = pending
+	api.sessionMutex.Unlock()
+
+	pending.cid, pending.err = api.initSession()
+
+	api.sessionMutex.Lock()
+	if pending.err == nil {
+		api.cid = pending.cid
+	}
+	api.pendingSession = nil
+	api.sessionMutex.Unlock()
+	close(pending.done)
+	return pending.cid, pending.err
+}
+
+// initSession initializes a new session and yields its ID.
+func (api *NexxAPI) initSession() (cid string, err error) {
+	deviceID := fmt.Sprintf("%v:%v", time.Now().Unix(), 10000+rand.Intn(90000))
+	postData := url.Values{
+		"nxp_devh": {deviceID},
+	}
+	URL := fmt.Sprintf("%v/%v/session/init", apiBase, api.domainID)
+	var initSession initSessionResult
+	err = api.doRequest(URL, postData, map[string]string{}, &initSession)
+	if err != nil {
+		return
+	}
+	if initSession.General.Cid == "" {
+		err = errors.New("no cid from initializing session")
+		return
+	}
+	cid = initSession.General.Cid
+	return
+}
+
+// discardSession forgets the session with the given ID unless another request has already replaced it.
+func (api *NexxAPI) discardSession(cid string) {
+	api.sessionMutex.Lock()
+	defer api.sessionMutex.Unlock()
+	if api.cid == cid {
+		api.cid = ""
+	}
+}
+
+func (api *NexxAPI) doRequest(URL string, postData url.Values, headers map[string]string, result interface{}) (err error) {
+	var body []byte
+	body, err = api.fnPostRequest(URL, postData, headers)
+	if err != nil {
+		return
+	}
+	var parsed response
+	err = json.Unmarshal(body, &parsed)
+	if err != nil {
+		log.Printf("Could not parse JSON body for request to URL %v. %v", URL, err)
+		return
+	}
+	if parsed.Metadata.Status != 0 && parsed.Metadata.Status != http.StatusOK {
+		err = &statusError{Status: parsed.Metadata.Status, ErrorHint: parsed.Metadata.ErrorHint, URL: URL}
+		return
+	}
+	err = json.Unmarshal(parsed.Result, result)
+	if err != nil {
+		log.Printf("Could not parse result of request to URL %v. %v", URL, err)
+	}
+	return
+}
+
+// GetStreams yields the MP4 files of a video in all resolutions listed in the file distribution.
+// The URLs have the form https://[cdnShieldHTTPS]/[qAccount]/files/[qPrefix]/[qLocator]/[selector].mp4.
+func (streamData *StreamData) GetStreams() []Stream {
+	matches := fileDistributionRegex.FindAllStringSubmatch(streamData.AzureFileDistribution, -1)
+	streams := make([]Stream, 0, len(matches))
+	for _, match := range matches {
+		bitrate, _ := strconv.Atoi(match[1])
+		width, _ := strconv.Atoi(match[2])
+		height, _ := strconv.Atoi(match[3])
+		streams = append(streams, Stream{
+			CDN: streamData.CdnType,
+			URL: fmt.Sprintf("https://%v%v/files/%v/%v/%v.mp4",
+				streamData.CdnShieldHTTPS,
+				streamData.QAccount,
+				streamData.QPrefix,
+				streamData.QLocator,
+				match[4]),
+			Width:   width,
+			Height:  height,
+			Bitrate: bitrate,
+		})
+	}
+	return streams
+}
+
+// GetDurationInSeconds yields the duration of a video from its runtime, e.g. 754 for 00:12:34.
+// It yields zero if the runtime is unknown.
+func (video *Video) GetDurationInSeconds() int {
+	duration := 0
+	for _, part := range strings.Split(video.General.Runtime, ":") {
+		value, err := strconv.Atoi(part)
+		if err != nil {
+			return 0
+		}
+		duration = duration*60 + value
+	}
+	return duration
+}
+
+// GetUploadDate yields the time when the video has been uploaded.
+func (video *Video) GetUploadDate() time.Time {
+	return time.Unix(video.General.Uploaded, 0).UTC()
+}
+
+func getMD5Hash(text string) string {
+	hash := md5.Sum([]byte(text))
+	return hex.EncodeToString(hash[:])
+}
+
+func doPostRequest(URL string, data url.Values, headers map[string]string) (result []byte, err error) {
+	var resp *http.Response
+
+	postData := strings.NewReader(data.Encode())
+	req, err := http.NewRequest("POST", URL, postData)
+	if err != nil {
+		log.Printf("Error in building POST request for URL %v: %v", URL, err)
+		return
+	}
+
+	for k, v := range headers {
+		req.Header.Add(k, v)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := &http.Client{
+		Timeout: requestTimeout,
+	}
+	resp, err = client.Do(req)
+	if err != nil {
+		log.Printf("Received error for URL %v: %v", URL, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		err = &statusError{Status: resp.StatusCode, ErrorHint: resp.Status, URL: URL}
+		return
+	}
+
+	result, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Printf("Could not read body from POST request to URL %v.", URL)
+		return
+	}
+	return
+}
@@ -0,0 +1,221 @@
+package nexxapi
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testCid = "5831297740147063921"
+
+var urlToFilename = map[string](string){
+	"https://api.nexx.cloud/v3/741/session/init":              "nexx-session-init.json",
+	"https://api.nexx.cloud/v3/741/channels/byid/1031":        "nexx-channel-1031.json",
+	"https://api.nexx.cloud/v3/741/videos/bychannel/1031":     "nexx-channel-1031-videos.json",
+	"https://api.nexx.cloud/v3/741/videos/byid/1707442":       "nexx-video-1707442.json",
+	"https://api.nexx.cloud/v3/741/channels/byid/9999":        "nexx-unknown.json",
+	"https://api.nexx.cloud/v3/741/videos/byseries/9999":      "nexx-unknown.json",
+	"https://api.nexx.cloud/v3/741/series/byid/1031":          "nexx-unknown.json",
+	"https://api.nexx.cloud/v3/741/videos/bychannel/failing":  "",
+	"https://api.nexx.cloud/v3/741/videos/bychannel/notfound": "nexx-unknown.json",
+	"https://api.nexx.cloud/v3/741/videos/bychannel/expired":  "nexx-unauthorized.json",
+}
+
+func TestSessionIsInitializedOnce(t *testing.T) {
+	api, requests := createAPIMocked(t)
+
+	_, err := api.GetChannel("1031")
+	assertNoError(t, err)
+	_, err = api.GetChannelVideos("1031", 3)
+	assertNoError(t, err)
+	_, err = api.GetVideo("1707442")
+	assertNoError(t, err)
+
+	assertEquals(t, 1, requests["https://api.nexx.cloud/v3/741/session/init"])
+	assertEquals(t, 4, requests.total())
+}
+
+func TestSessionIsKeptAfterFailure(t *testing.T) {
+	api, requests := createAPIMocked(t)
+
+	_, err := api.GetChannelVideos("failing", 3)
+	if err == nil {
+		t.Fatal("There should be an error.")
+	}
+	_, err = api.GetChannelVideos("notfound", 3)
+	if err == nil {
+		t.Fatal("There should be an error.")
+	}
+	_, err = api.GetChannelVideos("1031", 3)
+	assertNoError(t, err)
+
+	assertEquals(t, 1, requests["https://api.nexx.cloud/v3/741/session/init"])
+}
+
+func TestSessionIsRenewedAfterAuthFailure(t *testing.T) {
+	api, requests := createAPIMocked(t)
+
+	_, err := api.GetChannelVideos("expired", 3)
+	if err == nil {
+		t.Fatal("There should be an error.")
+	}
+	assertEquals(t, 2, requests["https://api.nexx.cloud/v3/741/videos/bychannel/expired"])
+	assertEquals(t, 2, requests["https://api.nexx.cloud/v3/741/session/init"])
+
+	_, err = api.GetChannelVideos("1031", 3)
+	assertNoError(t, err)
+	assertEquals(t, 3, requests["https://api.nexx.cloud/v3/741/session/init"])
+}
+
+func TestRequestIsRetriedWithRenewedSession(t *testing.T) {
+	requests := requestCounter{}
+	fnPost := func(URL string, data url.Values, headers map[string]string) ([]byte, error) {
+		requests[URL]++
+		if URL == "https://api.nexx.cloud/v3/741/videos/bychannel/1031" && requests[URL] == 1 {
+			return ioutil.ReadFile("../testdata/nexx-unauthorized.json")
+		}
+		return ioutil.ReadFile("../testdata/" + urlToFilename[URL])
+	}
+	api := CreateNexxAPIWithPostFunc(funkDomainID, funkDomainHash, fnPost)
+
+	videos, err := api.GetChannelVideos("1031", 3)
+	assertNoError(t, err)
+	assertEquals(t, 3, len(videos))
+	assertEquals(t, 2, requests["https://api.nexx.cloud/v3/741/videos/bychannel/1031"])
+	assertEquals(t, 2, requests["https://api.nexx.cloud/v3/741/session/init"])
+}
+
+func TestSessionIsInitializedOnceForConcurrentRequests(t *testing.T) {
+	var mutex sync.Mutex
+	sessionInits := 0
+	fnPost := func(URL string, data url.Values, headers map[string]string) ([]byte, error) {
+		if URL == "https://api.nexx.cloud/v3/741/session/init" {
+			mutex.Lock()
+			sessionInits++
+			mutex.Unlock()
+			time.Sleep(10 * time.Millisecond)
+		}
+		return ioutil.ReadFile("../testdata/" + urlToFilename[URL])
+	}
+	api := CreateNexxAPIWithPostFunc(funkDomainID, funkDomainHash, fnPost)
+
+	var waitGroup sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			if _, err := api.GetChannel("1031"); err != nil {
+				t.Errorf("There should not be an error.\n%v", err)
+			}
+		}()
+	}
+	waitGroup.Wait()
+	assertEquals(t, 1, sessionInits)
+}
+
+func TestGetChannel(t *testing.T) {
+	api, _ := createAPIMocked(t)
+
+	channel, err := api.GetChannel("1031")
+	assertNoError(t, err)
+	assertEquals(t, 1031, channel.General.ID)
+	assertEquals(t, "Walulis", channel.General.Title)
+	assertEquals(t, "https://nx-s.akamaized.net/images/741/channels/1031/walulis-cover.jpg", channel.ImageData.Thumb)
+
+	if _, err = api.GetChannel("9999"); err == nil {
+		t.Fatal("There should be an error.")
+	}
+	if _, err = api.GetSeries("1031"); err == nil {
+		t.Fatal("There should be an error.")
+	}
+}
+
+func TestGetChannelVideos(t *testing.T) {
+	api, _ := createAPIMocked(t)
+
+	videos, err := api.GetChannelVideos("1031", 3)
+	assertNoError(t, err)
+	assertEquals(t, 3, len(videos))
+	video := videos[0]
+	assertEquals(t, 1707442, video.General.ID)
+	assertEquals(t, "Wie Influencer Werbung verstecken", video.General.Title)
+	assertEquals(t, 754, video.GetDurationInSeconds())
+	assertEquals(t, time.Date(2020, 12, 27, 11, 0, 0, 0, time.UTC), video.GetUploadDate())
+
+	if _, err = api.GetChannelVideos("notfound", 3); err == nil {
+		t.Fatal("There should be an error.")
+	}
+	if _, err = api.GetSeriesVideos("9999", 3); err == nil {
+		t.Fatal("There should be an error.")
+	}
+}
+
+func TestGetStreams(t *testing.T) {
+	api, _ := createAPIMocked(t)
+
+	video, err := api.GetVideo("1707442")
+	assertNoError(t, err)
+	streams := video.StreamData.GetStreams()
+	assertEquals(t, 3, len(streams))
+	assertEquals(t, "https://funk-02.akamaized.net/funk/files/de/1707442_src/2-w7FWPztXm9hnZpbjMcKq.mp4", streams[1].URL)
+	assertEquals(t, 1280, streams[1].Width)
+	assertEquals(t, 720, streams[1].Height)
+	assertEquals(t, "azure", streams[1].CDN)
+	assertEquals(t, 3001, streams[1].Bitrate)
+
+	video.StreamData.AzureFileDistribution = ""
+	assertEquals(t, 0, len(video.StreamData.GetStreams()))
+}
+
+func TestGetDurationInSeconds(t *testing.T) {
+	video := Video{}
+	assertEquals(t, 0, video.GetDurationInSeconds())
+	video.General.Runtime = "01:00:05"
+	assertEquals(t, 3605, video.GetDurationInSeconds())
+	video.General.Runtime = "12:34"
+	assertEquals(t, 754, video.GetDurationInSeconds())
+}
+
+type requestCounter map[string](int)
+
+func (counter requestCounter) total() (result int) {
+	for _, count := range counter {
+		result += count
+	}
+	return
+}
+
+func createAPIMocked(t *testing.T) (*NexxAPI, requestCounter) {
+	requests := requestCounter{}
+	fnPost := func(URL string, data url.Values, headers map[string]string) (result []byte, err error) {
+		requests[URL]++
+		filename, ok := urlToFilename[URL]
+		if !ok {
+			t.Fatalf("Unexpected request to URL %v.", URL)
+		}
+		if URL != "https://api.nexx.cloud/v3/741/session/init" && headers["x-request-cid"] != testCid {
+			t.Fatalf("Expected the cid %v but got %v.", testCid, headers["x-request-cid"])
+		}
+		if filename == "" {
+			err = errors.New("request failed")
+			return
+		}
+		return ioutil.ReadFile("../testdata/" + filename)
+	}
+	return CreateNexxAPIWithPostFunc(funkDomainID, funkDomainHash, fnPost), requests
+}
+
+func assertNoError(t *testing.T, err error) {
+	if err != nil {
+		t.Fatalf("There should not be an error.\n%v", err)
+	}
+}
+
+func assertEquals(t *testing.T, expected, actual interface{}) {
+	if expected != actual {
+		t.Fatalf("Expected %v but got %v.", expected, actual)
+	}
+}
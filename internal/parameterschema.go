@@ -0,0 +1,164 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type parameterKind int
+
+const (
+	integerParameter parameterKind = iota
+	booleanParameter
+	dateParameter
+	enumParameter
+	listParameter
+	languageParameter
+	regexParameter
+)
+
+// parameterDefinition describes the valid values of a request parameter. Integers have to be in the range from
+// minimum to maximum, enums and the elements of lists have to be one of the allowed values.
+type parameterDefinition struct {
+	kind          parameterKind
+	minimum       int
+	maximum       int
+	allowedValues []string
+}
+
+// parameterSchema contains the definitions of all supported request parameters by their name.
+var parameterSchema = map[string](parameterDefinition){
+	"width":          {kind: integerParameter, minimum: 1, maximum: 7680},
+	"minLength":      {kind: integerParameter, minimum: 0, maximum: math.MaxInt32},
+	"maxLength":      {kind: integerParameter, minimum: 0, maximum: math.MaxInt32},
+	"since":          {kind: dateParameter},
+	"until":          {kind: dateParameter},
+	"audio":          {kind: booleanParameter},
+	"audioFormat":    {kind: enumParameter, allowedValues: audioFormatValues},
+	"maxHeight":      {kind: integerParameter, minimum: 0, maximum: 4320},
+	"maxBitrate":     {kind: integerParameter, minimum: 0, maximum: math.MaxInt32},
+	"codec":          {kind: enumParameter, allowedValues: codecValues},
+	"prefer":         {kind: enumParameter, allowedValues: preferValues},
+	"lang":           {kind: languageParameter},
+	"variant":        {kind: enumParameter, allowedValues: variantValues},
+	"onMissingMedia": {kind: enumParameter, allowedValues: onMissingMediaValues},
+	"annotateExpiry": {kind: booleanParameter},
+	"excludeGeo":     {kind: listParameter, allowedValues: geoRegionValues},
+	"maxFsk":         {kind: integerParameter, minimum: 0, maximum: defaultMaxAgeRating},
+	"include":        {kind: regexParameter},
+	"exclude":        {kind: regexParameter},
+	"limit":          {kind: integerParameter, minimum: 0, maximum: math.MaxInt32},
+	"sort":           {kind: enumParameter, allowedValues: sortValues},
+	"serial":         {kind: booleanParameter},
+	"season":         {kind: integerParameter, minimum: 0, maximum: math.MaxInt32},
+	"format":         {kind: enumParameter, allowedValues: formatValues},
+}
+
+// InvalidParametersError reports all request parameters that are unknown or have invalid values.
+type InvalidParametersError struct {
+	Problems []string
+}
+
+func (err *InvalidParametersError) Error() string {
+	return "Invalid request parameters:\n" + strings.Join(err.Problems, "\n")
+}
+
+// ParseRequestParametersFromURL validates the query parameters of the URL against the parameter schema and yields
+// the normalized request parameters. Unknown parameters and invalid values result in an InvalidParametersError.
+func ParseRequestParametersFromURL(URL *url.URL) (parameters RequestParameters, err error) {
+	problems := make([]string, 0)
+	query := URL.Query()
+	for name, values := range query {
+		definition, found := parameterSchema[name]
+		if !found {
+			problems = append(problems, fmt.Sprintf("%v: unknown parameter", name))
+			continue
+		}
+		if len(values) > 1 {
+			problems = append(problems, fmt.Sprintf("%v: given %v times", name, len(values)))
+			continue
+		}
+		if problem := definition.validate(values[0]); problem != "" {
+			problems = append(problems, fmt.Sprintf("%v: %v but got %q", name, problem, values[0]))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		err = &InvalidParametersError{Problems: problems}
+		return
+	}
+
+	parameters = CreateRequestParametersFromURL(URL)
+	if parameters.MaximumLengthInSeconds > 0 && parameters.MinimumLengthInSeconds > parameters.MaximumLengthInSeconds {
+		problems = append(problems, "minLength: must not be greater than maxLength")
+	}
+	if !parameters.Since.IsZero() && !parameters.Until.IsZero() && parameters.Since.After(parameters.Until) {
+		problems = append(problems, "since: must not be after until")
+	}
+	if len(problems) > 0 {
+		err = &InvalidParametersError{Problems: problems}
+	}
+	return
+}
+
+// validate yields a description of the problem with the given value or an empty string if the value is valid.
+// Surrounding white space and the case of enums and lists are ignored, because they are normalized when parsing.
+// Empty values are valid and select the default value.
+func (definition parameterDefinition) validate(value string) string {
+	trimmedValue := strings.TrimSpace(value)
+	if trimmedValue == "" {
+		return ""
+	}
+	switch definition.kind {
+	case integerParameter:
+		number, err := strconv.Atoi(trimmedValue)
+		if err != nil || number < definition.minimum || number > definition.maximum {
+			if definition.maximum == math.MaxInt32 {
+				return fmt.Sprintf("expected an integer of at least %v", definition.minimum)
+			}
+			return fmt.Sprintf("expected an integer from %v to %v", definition.minimum, definition.maximum)
+		}
+	case booleanParameter:
+		if _, err := strconv.ParseBool(trimmedValue); err != nil {
+			return "expected true or false"
+		}
+	case dateParameter:
+		if _, err := time.Parse(requestDateFormat, trimmedValue); err != nil {
+			return "expected a date like 2021-01-31"
+		}
+	case enumParameter:
+		if !contains(definition.allowedValues, strings.ToLower(trimmedValue)) {
+			return "expected one of " + strings.Join(definition.allowedValues, ", ")
+		}
+	case listParameter:
+		for _, element := range splitList(trimmedValue) {
+			if !contains(definition.allowedValues, element) {
+				return "expected a comma-separated list of " + strings.Join(definition.allowedValues, ", ")
+			}
+		}
+	case languageParameter:
+		if !languageRegex.MatchString(strings.ToLower(trimmedValue)) {
+			return "expected an ISO 639-2 language code like deu"
+		}
+	case regexParameter:
+		if _, err := regexp.Compile(value); err != nil {
+			return "expected a regular expression"
+		}
+	}
+	return ""
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseRequestParametersFromURLValid(t *testing.T) {
+	parameters := assertParseRequestParametersValid(t, "width=720&minLength=600&maxLength=3600&since=2021-01-01&until=2021-01-31&audio=1&audioFormat=MP3&codec=h264&lang=ENG&variant=ad&excludeGeo=de&maxFsk=12&include=Folge&limit=5")
+	assertEquals(t, "720", fmt.Sprint(parameters.Width))
+	assertEquals(t, "true", fmt.Sprint(parameters.AudioOnly))
+	assertEquals(t, "mp3", parameters.AudioFormat)
+	assertEquals(t, "eng", parameters.Language)
+	assertEquals(t, "Folge", parameters.Include.String())
+	assertEquals(t, "5", fmt.Sprint(parameters.Limit))
+}
+
+func TestParseRequestParametersFromURLWithEmptyValues(t *testing.T) {
+	parameters := assertParseRequestParametersValid(t, "width=&codec=")
+	assertEquals(t, "1920", fmt.Sprint(parameters.Width))
+	assertEquals(t, "", parameters.Codec)
+}
+
+func TestParseRequestParametersFromURLNormalizes(t *testing.T) {
+	first := assertParseRequestParametersValid(t, "width=%20720&excludeGeo=DE,dach,de&codec=H264")
+	second := assertParseRequestParametersValid(t, "codec=h264&excludeGeo=dach,de&width=0720")
+	assertEquals(t, CreateCacheKey("zdf", "show", first), CreateCacheKey("zdf", "show", second))
+
+	third := assertParseRequestParametersValid(t, "width=1920&variant=main&onMissingMedia=skip")
+	fourth := assertParseRequestParametersValid(t, "")
+	assertEquals(t, CreateCacheKey("zdf", "show", third), CreateCacheKey("zdf", "show", fourth))
+}
+
+func TestParseRequestParametersFromURLInvalid(t *testing.T) {
+	assertParseRequestParametersInvalid(t, "width=abc", "width: expected an integer from 1 to 7680 but got \"abc\"")
+	assertParseRequestParametersInvalid(t, "minLength=-5", "minLength: expected an integer of at least 0 but got \"-5\"")
+	assertParseRequestParametersInvalid(t, "maxFsk=21", "maxFsk: expected an integer from 0 to 18 but got \"21\"")
+	assertParseRequestParametersInvalid(t, "audio=maybe", "audio: expected true or false but got \"maybe\"")
+	assertParseRequestParametersInvalid(t, "since=01.01.2021", "since: expected a date like 2021-01-31 but got \"01.01.2021\"")
+	assertParseRequestParametersInvalid(t, "codec=vp9", "codec: expected one of h264, h265 but got \"vp9\"")
+	assertParseRequestParametersInvalid(t, "excludeGeo=de,us", "excludeGeo: expected a comma-separated list of all, dach, de, de_fr, ebu but got \"de,us\"")
+	assertParseRequestParametersInvalid(t, "lang=de", "lang: expected an ISO 639-2 language code like deu but got \"de\"")
+	assertParseRequestParametersInvalid(t, "include=(Folge", "include: expected a regular expression but got \"(Folge\"")
+	assertParseRequestParametersInvalid(t, "witdh=720", "witdh: unknown parameter")
+	assertParseRequestParametersInvalid(t, "width=720&width=1080", "width: given 2 times")
+	assertParseRequestParametersInvalid(t, "minLength=600&maxLength=300", "minLength: must not be greater than maxLength")
+	assertParseRequestParametersInvalid(t, "since=2021-02-01&until=2021-01-31", "since: must not be after until")
+}
+
+func TestParseRequestParametersFromURLListsAllProblems(t *testing.T) {
+	URL, _ := url.Parse("https://localhost/zdf/show?width=abc&foo=bar&codec=vp9")
+	_, err := ParseRequestParametersFromURL(URL)
+	invalidParametersError, ok := err.(*InvalidParametersError)
+	if !ok {
+		t.Fatalf("We expected an InvalidParametersError but got %v.", err)
+	}
+	assertEquals(t, "3", fmt.Sprint(len(invalidParametersError.Problems)))
+	assertEquals(t, "true", fmt.Sprint(strings.HasPrefix(invalidParametersError.Problems[0], "codec:")))
+	assertEquals(t, "true", fmt.Sprint(strings.HasPrefix(invalidParametersError.Problems[1], "foo:")))
+	assertEquals(t, "true", fmt.Sprint(strings.HasPrefix(invalidParametersError.Problems[2], "width:")))
+}
+
+func assertParseRequestParametersValid(t *testing.T, query string) RequestParameters {
+	URL, _ := url.Parse("https://localhost/zdf/show?" + query)
+	parameters, err := ParseRequestParametersFromURL(URL)
+	if err != nil {
+		t.Fatalf("We did not expect an error for %v.\n%v", query, err)
+	}
+	return parameters
+}
+
+func assertParseRequestParametersInvalid(t *testing.T, query, expectedProblem string) {
+	URL, _ := url.Parse("https://localhost/zdf/show?" + query)
+	_, err := ParseRequestParametersFromURL(URL)
+	invalidParametersError, ok := err.(*InvalidParametersError)
+	if !ok {
+		t.Fatalf("We expected an InvalidParametersError for %v but got %v.", query, err)
+	}
+	assertEquals(t, expectedProblem, strings.Join(invalidParametersError.Problems, "; "))
+}
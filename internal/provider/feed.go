@@ -0,0 +1,111 @@
+package provider
+
+import (
+	"path"
+	"time"
+
+	"github.com/seiferma/docker_mediathek2rss/internal"
+	"github.com/seiferma/docker_mediathek2rss/internal/feedrender"
+)
+
+// fallbackMissingMediaReason is logged for episodes whose streams do not satisfy the request parameters.
+const fallbackMissingMediaReason = "no suitable stream"
+
+// CreateFeed creates the feed of a show of a provider in the format given by the request parameters.
+//
+// It takes the route and the ID of the show, the URL of the requested feed, the request parameters, the media services
+// and the current time, which determines the build date of the feed and the available episodes. The episodes are filtered, ordered
+// and limited according to the request parameters, so the limit keeps the first episodes of the requested order. The
+// listing stops at the limit only if the episodes keep the order of the television channel. It yields the feed as a string.
+func CreateFeed(feedProvider Provider, route, showID, feedURL string, parameters internal.RequestParameters, services *internal.MediaServices, now time.Time) (result string, err error) {
+	var show internal.Show
+	show, err = feedProvider.LookupShow(route, showID, parameters)
+	if err != nil {
+		return
+	}
+	show.ProviderID = feedProvider.ID()
+	show.ID = path.Join(route, showID)
+	show.FeedURL = feedURL
+
+	show.Episodes = make([]internal.Episode, 0)
+	var detailsErr error
+	keepsOrder := !internal.IsReordered(parameters)
+	err = feedProvider.ListEpisodes(&show, parameters, func(episode internal.Episode) bool {
+		if keepsOrder && internal.IsLimitReached(len(show.Episodes), parameters) {
+			return false
+		}
+		keepEpisode, completeErr := completeEpisode(feedProvider, &show, showID, &episode, parameters, services, now)
+		if completeErr != nil {
+			detailsErr = completeErr
+			return false
+		}
+		if keepEpisode {
+			show.Episodes = append(show.Episodes, episode)
+		}
+		return true
+	})
+	if err == nil {
+		err = detailsErr
+	}
+	if err != nil {
+		return
+	}
+
+	internal.OrderEpisodes(&show, parameters)
+	if internal.IsLimitReached(len(show.Episodes), parameters) {
+		show.Episodes = show.Episodes[:parameters.Limit]
+	}
+	services.CompleteMediaVariants(show.Episodes)
+	result, err = feedrender.Render(&show, parameters.Format, now)
+	return
+}
+
+// completeEpisode loads the details of an episode if the provider is a DetailsLoader, resolves its media file and
+// annotates its description. The media file is probed later together with the media files of the other episodes.
+// It yields false if the episode is excluded by the request parameters, not available or has no media file and shall
+// be dropped.
+func completeEpisode(feedProvider Provider, show *internal.Show, showID string, episode *internal.Episode, parameters internal.RequestParameters, services *internal.MediaServices, now time.Time) (keepEpisode bool, err error) {
+	if !internal.IsAvailable(episode.AvailableFrom, episode.AvailableTo, now) {
+		return
+	}
+	if episode.Restrictions.IsExcluded(parameters) {
+		return
+	}
+	if loader, isLoader := feedProvider.(DetailsLoader); isLoader {
+		if episode.IsExcludedBeforeDetails(parameters) {
+			return
+		}
+		var found bool
+		if found, err = loader.LoadDetails(show, episode, parameters); err != nil || !found {
+			return
+		}
+		if !internal.IsAvailable(episode.AvailableFrom, episode.AvailableTo, now) || episode.Restrictions.IsExcluded(parameters) {
+			return
+		}
+	}
+	if episode.IsExcluded(parameters) {
+		return
+	}
+
+	missingMediaReason := fallbackMissingMediaReason
+	if mediaErr := feedProvider.ResolveMedia(show, episode, parameters, services); mediaErr != nil {
+		missingMediaReason = mediaErr.Error()
+		episode.Media = nil
+	}
+	// the streams may reveal restrictions that the list of episodes does not contain
+	if episode.Restrictions.IsExcluded(parameters) {
+		return
+	}
+
+	internal.AnnotateRestrictions(episode)
+	if episode.Media == nil || episode.Media.URL == "" {
+		if !internal.HandleMissingMedia(feedProvider.ID(), showID, episode, parameters.OnMissingMedia, missingMediaReason) {
+			return
+		}
+	}
+	if parameters.AnnotateExpiry {
+		internal.AppendExpiryToDescription(episode)
+	}
+	keepEpisode = true
+	return
+}
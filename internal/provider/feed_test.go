@@ -0,0 +1,221 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seiferma/docker_mediathek2rss/internal"
+)
+
+var testNow = time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// testProvider offers a single show whose episodes have a stream unless their ID is listed in missingStreams.
+type testProvider struct {
+	episodes       []internal.Episode
+	missingStreams map[string](bool)
+	lookups        int
+	visits         int
+	err            error
+}
+
+func createTestProvider() *testProvider {
+	return &testProvider{
+		episodes: []internal.Episode{
+			createTestEpisode("episode-1", "Episode 1", time.Date(2020, 12, 1, 20, 15, 0, 0, time.UTC)),
+			createTestEpisode("episode-2", "Trailer", time.Date(2020, 12, 2, 20, 15, 0, 0, time.UTC)),
+			createTestEpisode("episode-3", "Episode 3", time.Date(2020, 12, 3, 20, 15, 0, 0, time.UTC)),
+		},
+		missingStreams: map[string](bool){},
+	}
+}
+
+func createTestEpisode(ID, title string, date time.Time) internal.Episode {
+	return internal.Episode{
+		ID:                ID,
+		Title:             title,
+		Description:       "Description of " + title,
+		Link:              "https://foo.bar/" + ID,
+		Image:             internal.Image{URL: "https://foo.bar/" + ID + ".png"},
+		Date:              date,
+		DurationInSeconds: 1800,
+	}
+}
+
+func (testProvider *testProvider) ID() string {
+	return "test"
+}
+
+func (testProvider *testProvider) Routes() []string {
+	return []string{"", "byName"}
+}
+
+func (testProvider *testProvider) IsValidShowID(route, showID string) bool {
+	return showID == "show"
+}
+
+func (testProvider *testProvider) LookupShow(route, showID string, parameters internal.RequestParameters) (show internal.Show, err error) {
+	testProvider.lookups++
+	if testProvider.err != nil {
+		err = testProvider.err
+		return
+	}
+	show = internal.Show{
+		Title:       "Show",
+		Description: "Description of the show",
+		Link:        "https://foo.bar/show",
+		Image:       internal.Image{URL: "https://foo.bar/show.png", Title: "Show"},
+		Author:      "Foo",
+		Category:    internal.DefaultITunesCategory,
+	}
+	return
+}
+
+func (testProvider *testProvider) ListEpisodes(show *internal.Show, parameters internal.RequestParameters, fnVisit func(internal.Episode) bool) error {
+	for _, episode := range testProvider.episodes {
+		testProvider.visits++
+		if !fnVisit(episode) {
+			break
+		}
+	}
+	return nil
+}
+
+func (testProvider *testProvider) ResolveMedia(show *internal.Show, episode *internal.Episode, parameters internal.RequestParameters, services *internal.MediaServices) error {
+	if testProvider.missingStreams[episode.ID] {
+		return errors.New("no media streams")
+	}
+	episode.Media = &internal.MediaVariant{URL: "https://foo.bar/" + episode.ID + ".mp4", MimeType: internal.VideoMimeType}
+	episode.Subtitles = []internal.Subtitle{{URL: "https://foo.bar/" + episode.ID + ".vtt", MimeType: "text/vtt", Language: "deu"}}
+	return nil
+}
+
+// detailsTestProvider is a test provider whose episodes get their description from LoadDetails.
+type detailsTestProvider struct {
+	*testProvider
+	loadedDetails []string
+	detailsErr    error
+}
+
+func (detailsProvider *detailsTestProvider) LoadDetails(show *internal.Show, episode *internal.Episode, parameters internal.RequestParameters) (bool, error) {
+	detailsProvider.loadedDetails = append(detailsProvider.loadedDetails, episode.ID)
+	episode.Description = "Loaded description of " + episode.Title
+	return detailsProvider.detailsErr == nil, detailsProvider.detailsErr
+}
+
+func TestCreateFeed(t *testing.T) {
+	result, err := CreateFeed(createTestProvider(), "", "show", "", internal.RequestParameters{}, &internal.MediaServices{}, testNow)
+	if err != nil {
+		t.Fatalf("There should not be an error.\n%v", err)
+	}
+	assertEquals(t, 3, strings.Count(result, "<item>"))
+	assertEquals(t, true, strings.Contains(result, "<title>Show</title>"))
+	assertEquals(t, true, strings.Contains(result, "<itunes:author>Foo</itunes:author>"))
+	assertEquals(t, true, strings.Contains(result, "<lastBuildDate>Fri, 01 Jan 2021 13:00:00 +0100</lastBuildDate>"))
+	assertEquals(t, true, strings.Contains(result, `<enclosure url="https://foo.bar/episode-1.mp4" type="video/mp4" length="0"></enclosure>`))
+	assertEquals(t, true, strings.Contains(result, "<itunes:duration>30:00</itunes:duration>"))
+}
+
+func TestCreateFeedLoadsDetailsWithinLimit(t *testing.T) {
+	feedProvider := &detailsTestProvider{testProvider: createTestProvider()}
+	parameters := internal.RequestParameters{Limit: 2, Exclude: regexp.MustCompile("Trailer")}
+	result, err := CreateFeed(feedProvider, "", "show", "", parameters, &internal.MediaServices{}, testNow)
+	if err != nil {
+		t.Fatalf("There should not be an error.\n%v", err)
+	}
+	assertEquals(t, 2, strings.Count(result, "<item>"))
+	assertEquals(t, true, strings.Contains(result, "Loaded description of Episode 3"))
+	assertEquals(t, "[episode-1 episode-3]", fmt.Sprint(feedProvider.loadedDetails))
+
+	feedProvider = &detailsTestProvider{testProvider: createTestProvider(), detailsErr: errors.New("unknown episode")}
+	if _, err = CreateFeed(feedProvider, "", "show", "", internal.RequestParameters{}, &internal.MediaServices{}, testNow); err == nil {
+		t.Fatal("There should be an error.")
+	}
+	assertEquals(t, 1, len(feedProvider.loadedDetails))
+}
+
+func TestCreateFeedConsidersFilters(t *testing.T) {
+	parameters := internal.RequestParameters{Exclude: regexp.MustCompile("Trailer")}
+	result, _ := CreateFeed(createTestProvider(), "", "show", "", parameters, &internal.MediaServices{}, testNow)
+	assertEquals(t, 2, strings.Count(result, "<item>"))
+	assertEquals(t, false, strings.Contains(result, "Trailer"))
+
+	feedProvider := createTestProvider()
+	feedProvider.episodes[0].AvailableTo = testNow
+	feedProvider.episodes[1].Restrictions = internal.CreateRestrictions("", "FSK18")
+	parameters = internal.RequestParameters{MaxAgeRating: 16}
+	result, _ = CreateFeed(feedProvider, "", "show", "", parameters, &internal.MediaServices{}, testNow)
+	assertEquals(t, 1, strings.Count(result, "<item>"))
+	assertEquals(t, true, strings.Contains(result, "Episode 3"))
+}
+
+func TestCreateFeedStopsListingAtLimit(t *testing.T) {
+	feedProvider := createTestProvider()
+	parameters := internal.RequestParameters{Limit: 1}
+	result, _ := CreateFeed(feedProvider, "", "show", "", parameters, &internal.MediaServices{}, testNow)
+	assertEquals(t, 1, strings.Count(result, "<item>"))
+	assertEquals(t, true, strings.Contains(result, "Episode 1"))
+	assertEquals(t, 2, feedProvider.visits)
+}
+
+func TestCreateFeedLimitsAfterOrdering(t *testing.T) {
+	feedProvider := createTestProvider()
+	parameters := internal.RequestParameters{Limit: 1, Sort: internal.SortByDateDescending}
+	result, _ := CreateFeed(feedProvider, "", "show", "", parameters, &internal.MediaServices{}, testNow)
+	assertEquals(t, 1, strings.Count(result, "<item>"))
+	assertEquals(t, true, strings.Contains(result, "Episode 3"))
+	assertEquals(t, 3, feedProvider.visits)
+
+	feedProvider = createTestProvider()
+	feedProvider.episodes[0], feedProvider.episodes[2] = feedProvider.episodes[2], feedProvider.episodes[0]
+	parameters = internal.RequestParameters{Limit: 2, Serial: true}
+	result, _ = CreateFeed(feedProvider, "", "show", "", parameters, &internal.MediaServices{}, testNow)
+	assertEquals(t, 2, strings.Count(result, "<item>"))
+	assertEquals(t, true, strings.Contains(result, "Episode 1"))
+	assertEquals(t, false, strings.Contains(result, "Episode 3"))
+	assertEquals(t, true, strings.Contains(result, "<itunes:episode>2</itunes:episode>"))
+	assertEquals(t, false, strings.Contains(result, "<itunes:episode>3</itunes:episode>"))
+}
+
+func TestCreateFeedHandlesMissingMedia(t *testing.T) {
+	feedProvider := createTestProvider()
+	feedProvider.missingStreams["episode-2"] = true
+	result, _ := CreateFeed(feedProvider, "", "show", "", internal.RequestParameters{}, &internal.MediaServices{}, testNow)
+	assertEquals(t, 2, strings.Count(result, "<item>"))
+
+	parameters := internal.RequestParameters{OnMissingMedia: internal.MissingMediaLink}
+	result, _ = CreateFeed(feedProvider, "", "show", "", parameters, &internal.MediaServices{}, testNow)
+	assertEquals(t, 3, strings.Count(result, "<item>"))
+	assertEquals(t, 2, strings.Count(result, "<enclosure"))
+}
+
+func TestCreateFeedRendersFormat(t *testing.T) {
+	parameters := internal.RequestParameters{Format: internal.FormatJSON}
+	result, err := CreateFeed(createTestProvider(), "", "show", "", parameters, &internal.MediaServices{}, testNow)
+	if err != nil {
+		t.Fatalf("There should not be an error.\n%v", err)
+	}
+	assertEquals(t, true, strings.Contains(result, `"url": "https://foo.bar/episode-1.mp4"`))
+	assertEquals(t, true, strings.Contains(result, `"url": "https://foo.bar/episode-1.vtt"`))
+
+	parameters = internal.RequestParameters{Format: internal.FormatAtom, Sort: internal.SortByDateDescending}
+	result, _ = CreateFeed(createTestProvider(), "", "show", "", parameters, &internal.MediaServices{}, testNow)
+	assertEquals(t, 3, strings.Count(result, "<entry>"))
+	assertEquals(t, true, strings.Index(result, "Episode 3") < strings.Index(result, "Episode 1"))
+}
+
+func TestCreateFeedReportsErrors(t *testing.T) {
+	feedProvider := createTestProvider()
+	feedProvider.err = errors.New("unknown show")
+	_, err := CreateFeed(feedProvider, "", "show", "", internal.RequestParameters{}, &internal.MediaServices{}, testNow)
+	assertEquals(t, feedProvider.err, err)
+}
+
+func assertEquals(t *testing.T, expected, actual interface{}) {
+	if expected != actual {
+		t.Fatalf("Expected %v but got %v.", expected, actual)
+	}
+}
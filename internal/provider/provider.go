@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"github.com/seiferma/docker_mediathek2rss/internal"
+)
+
+// Provider is a broadcaster whose shows are offered as feeds, e.g. the ARD or the ZDF.
+//
+// A provider only knows how to talk to the API of the broadcaster. The registry mounts its routes and takes care of
+// everything all providers share: request parameters, caching, filters, the feed format and errors.
+type Provider interface {
+	// ID yields the short name of the provider, which is the first segment of its routes and separates its feeds in
+	// the cache, e.g. ard.
+	ID() string
+	// Routes yields the kinds of show IDs the provider understands, e.g. byPath for the ZDF. The shows of a route are
+	// served at /{ID}/show/{route}/{showID}. The empty route serves the shows at /{ID}/show/{showID}.
+	Routes() []string
+	// IsValidShowID checks the ID of a show before it is passed to the API of the broadcaster.
+	IsValidShowID(route, showID string) bool
+	// LookupShow yields the show with the given ID of a route without episodes. The data that the provider needs for
+	// listing the episodes is kept in the details of the show.
+	LookupShow(route, showID string, parameters internal.RequestParameters) (internal.Show, error)
+	// ListEpisodes passes the episodes of a show in the order of the broadcaster to fnVisit until it yields false.
+	// The episodes have no media file yet.
+	ListEpisodes(show *internal.Show, parameters internal.RequestParameters, fnVisit func(internal.Episode) bool) error
+	// ResolveMedia selects the media stream of an episode that matches the request parameters best and sets it as the
+	// media of the episode together with the subtitles. It leaves the media empty if there is no suitable stream and
+	// yields an error describing the problem if the episode has no streams at all. The length of the media file is
+	// filled by the caller. Restrictions that only the streams reveal are added to the episode.
+	ResolveMedia(show *internal.Show, episode *internal.Episode, parameters internal.RequestParameters, services *internal.MediaServices) error
+}
+
+// DetailsLoader is implemented by providers whose list of episodes lacks details such as the description, which cost
+// a request per episode. The details are only loaded for episodes that pass the filters on the data of the list and
+// that are within the limit of the feed.
+type DetailsLoader interface {
+	// LoadDetails completes an episode passed by ListEpisodes. It yields false if the episode shall be skipped
+	// because the broadcaster provides no details and an error if the feed cannot be created at all.
+	LoadDetails(show *internal.Show, episode *internal.Episode, parameters internal.RequestParameters) (bool, error)
+}
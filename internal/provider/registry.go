@@ -0,0 +1,126 @@
+package provider
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/seiferma/docker_mediathek2rss/internal"
+	"github.com/seiferma/docker_mediathek2rss/internal/feedrender"
+)
+
+// Registry mounts the routes of providers and serves their feeds. It should be created by the CreateRegistry function.
+type Registry struct {
+	// ValidationMode determines how feeds are validated before caching them, e.g. internal.ValidationWarn.
+	ValidationMode string
+	cache          *internal.Cache
+	services       *internal.MediaServices
+	routes         map[string](route)
+}
+
+// route is a kind of show IDs of a provider.
+type route struct {
+	feedProvider Provider
+	name         string
+}
+
+// CreateRegistry creates a registry without providers. The feeds are kept in the given cache and the media files are
+// resolved by the given media services.
+func CreateRegistry(cache *internal.Cache, services *internal.MediaServices) *Registry {
+	return &Registry{
+		ValidationMode: internal.ValidationWarn,
+		cache:          cache,
+		services:       services,
+		routes:         map[string](route){},
+	}
+}
+
+// Register mounts the routes of a provider at the given multiplexer.
+func (registry *Registry) Register(mux *http.ServeMux, feedProvider Provider) {
+	for _, name := range feedProvider.Routes() {
+		pattern := getRoutePattern(feedProvider.ID(), name)
+		registry.routes[pattern] = route{
+			feedProvider: feedProvider,
+			name:         name,
+		}
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			registry.serveFeed(w, r, pattern)
+		})
+		log.Printf("Serving feeds of %v at %v.", feedProvider.ID(), pattern)
+	}
+}
+
+// IsFeedPattern determines if a pattern of the multiplexer belongs to a route of a registered provider.
+func (registry *Registry) IsFeedPattern(pattern string) bool {
+	_, found := registry.routes[pattern]
+	return found
+}
+
+// getRoutePattern yields the path prefix of a route, e.g. /zdf/show/byPath/.
+func getRoutePattern(providerID, routeName string) string {
+	if routeName == "" {
+		return "/" + providerID + "/show/"
+	}
+	return "/" + providerID + "/show/" + routeName + "/"
+}
+
+func (registry *Registry) serveFeed(w http.ResponseWriter, r *http.Request, pattern string) {
+	feedRoute := registry.routes[pattern]
+	providerID := feedRoute.feedProvider.ID()
+
+	// extract show ID from URL
+	showID := strings.TrimPrefix(r.URL.Path, pattern)
+	if !feedRoute.feedProvider.IsValidShowID(feedRoute.name, showID) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "The given show ID is not valid.")
+		log.Printf("Received a request for invalid %v show ID.", providerID)
+		return
+	}
+
+	// extract request parameters
+	requestParameters, err := internal.ParseRequestParametersFromURL(r.URL)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, err)
+		log.Printf("Received a request for %v show %v with invalid parameters: %v", providerID, showID, err)
+		return
+	}
+	log.Printf("Received a request for %v show %v with parameters %v.", providerID, showID, requestParameters)
+
+	// create feed
+	feedURL := getRequestURL(r)
+	fnCreateFeed := func(_ string, parameters internal.RequestParameters) (string, error) {
+		return CreateFeed(feedRoute.feedProvider, feedRoute.name, showID, feedURL, parameters, registry.services, time.Now())
+	}
+	cacheIdentifier := path.Join(feedRoute.name, showID)
+	feedString, err := internal.CreateRssFeedCached(providerID, cacheIdentifier, requestParameters, registry.cache, registry.ValidationMode, fnCreateFeed)
+
+	// report an error
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, err)
+		log.Printf("There was an error while processing request for %v show %v: %v", providerID, showID, err)
+		return
+	}
+
+	// return produced feed
+	w.Header().Add("Content-Type", feedrender.GetContentType(requestParameters.Format))
+	fmt.Fprint(w, feedString)
+	log.Printf("Successfully returning %v feed for %v show %v.", requestParameters.Format, providerID, showID)
+}
+
+// getRequestURL yields the absolute URL of a request. The scheme is taken from the X-Forwarded-Proto header of
+// reverse proxies if it is present.
+func getRequestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if forwardedScheme := r.Header.Get("X-Forwarded-Proto"); forwardedScheme == "http" || forwardedScheme == "https" {
+		scheme = forwardedScheme
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
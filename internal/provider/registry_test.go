@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seiferma/docker_mediathek2rss/internal"
+)
+
+func createTestRegistry(feedProvider Provider) (*Registry, *http.ServeMux) {
+	cache := internal.CreateCache(time.Hour)
+	registry := CreateRegistry(&cache, &internal.MediaServices{})
+	mux := http.NewServeMux()
+	registry.Register(mux, feedProvider)
+	return registry, mux
+}
+
+func serve(mux *http.ServeMux, URL string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest("GET", URL, nil))
+	return recorder
+}
+
+func TestRegisterMountsRoutes(t *testing.T) {
+	registry, mux := createTestRegistry(createTestProvider())
+	assertEquals(t, true, registry.IsFeedPattern("/test/show/"))
+	assertEquals(t, true, registry.IsFeedPattern("/test/show/byName/"))
+	assertEquals(t, false, registry.IsFeedPattern("/test/"))
+
+	for _, URL := range []string{"/test/show/show", "/test/show/byName/show"} {
+		recorder := serve(mux, URL)
+		assertEquals(t, http.StatusOK, recorder.Code)
+		assertEquals(t, "application/rss+xml", recorder.Header().Get("Content-Type"))
+		assertEquals(t, 3, strings.Count(recorder.Body.String(), "<item>"))
+	}
+}
+
+func TestServeFeedSetsContentTypeOfFormat(t *testing.T) {
+	_, mux := createTestRegistry(createTestProvider())
+	recorder := serve(mux, "/test/show/show?format=atom")
+	assertEquals(t, http.StatusOK, recorder.Code)
+	assertEquals(t, "application/atom+xml", recorder.Header().Get("Content-Type"))
+	recorder = serve(mux, "/test/show/show?format=json")
+	assertEquals(t, "application/feed+json", recorder.Header().Get("Content-Type"))
+}
+
+func TestServeFeedPassesFeedURL(t *testing.T) {
+	_, mux := createTestRegistry(createTestProvider())
+	recorder := serve(mux, "http://example.org/test/show/byName/show?format=json")
+	assertEquals(t, true, strings.Contains(recorder.Body.String(), `"feed_url": "http://example.org/test/show/byName/show?format=json"`))
+
+	request := httptest.NewRequest("GET", "http://example.org/test/show/show", nil)
+	request.Header.Set("X-Forwarded-Proto", "https")
+	assertEquals(t, "https://example.org/test/show/show", getRequestURL(request))
+}
+
+func TestServeFeedUsesCache(t *testing.T) {
+	feedProvider := createTestProvider()
+	_, mux := createTestRegistry(feedProvider)
+	serve(mux, "/test/show/show?limit=1")
+	serve(mux, "/test/show/show?limit=1")
+	assertEquals(t, 1, feedProvider.lookups)
+	serve(mux, "/test/show/byName/show?limit=1")
+	assertEquals(t, 2, feedProvider.lookups)
+}
+
+func TestServeFeedRejectsInvalidRequests(t *testing.T) {
+	feedProvider := createTestProvider()
+	_, mux := createTestRegistry(feedProvider)
+
+	recorder := serve(mux, "/test/show/unknown")
+	assertEquals(t, http.StatusBadRequest, recorder.Code)
+	assertEquals(t, "The given show ID is not valid.", recorder.Body.String())
+
+	recorder = serve(mux, "/test/show/show?width=abc&foo=bar")
+	assertEquals(t, http.StatusBadRequest, recorder.Code)
+	if !strings.Contains(recorder.Body.String(), "foo: unknown parameter") || !strings.Contains(recorder.Body.String(), "width:") {
+		t.Fatalf("Expected all invalid parameters to be listed but got %v.", recorder.Body.String())
+	}
+	assertEquals(t, 0, feedProvider.lookups)
+}
+
+func TestServeFeedReportsErrors(t *testing.T) {
+	feedProvider := createTestProvider()
+	feedProvider.err = errors.New("unknown show")
+	_, mux := createTestRegistry(feedProvider)
+
+	recorder := serve(mux, "/test/show/show")
+	assertEquals(t, http.StatusInternalServerError, recorder.Code)
+	assertEquals(t, "unknown show", recorder.Body.String())
+}
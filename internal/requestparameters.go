@@ -2,21 +2,101 @@ package internal
 
 import (
 	"net/url"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const defaultMediaWidth = 1920
 const defaultMinLengthInSeconds = 0
+const defaultAudioFormat = "aac"
+const requestDateFormat = "2006-01-02"
+const defaultMaxAgeRating = 18
+
+// Values of the variant request parameter
+const (
+	VariantMain             = "main"
+	VariantAudioDescription = "ad"
+	VariantSignLanguage     = "dgs"
+	VariantOriginalVersion  = "ov"
+	VariantSubtitled        = "sub"
+)
+
+// Values of the format request parameter
+const (
+	FormatRSS  = "rss"
+	FormatAtom = "atom"
+	FormatJSON = "json"
+)
+
+var languageRegex = regexp.MustCompile("^[a-z]{3}$")
+
+// allowed values of the request parameters with a fixed set of values
+var (
+	audioFormatValues    = []string{"aac", "mp3", "opus"}
+	codecValues          = []string{"h264", "h265"}
+	preferValues         = []string{"smallest", "largest"}
+	variantValues        = []string{VariantMain, VariantAudioDescription, VariantSignLanguage, VariantOriginalVersion, VariantSubtitled}
+	onMissingMediaValues = []string{MissingMediaSkip, MissingMediaKeep, MissingMediaLink}
+	sortValues           = []string{SortByDate, SortByDateDescending, SortByTitle, SortByDuration}
+	formatValues         = []string{FormatRSS, FormatAtom, FormatJSON}
+)
 
 type RequestParameters struct {
 	Width                  int
 	MinimumLengthInSeconds int
+	MaximumLengthInSeconds int
+	Since                  time.Time
+	Until                  time.Time
+	AudioOnly              bool
+	AudioFormat            string
+	MaxHeight              int
+	MaxBitrate             int
+	Codec                  string
+	Prefer                 string
+	Language               string
+	Variant                string
+	OnMissingMedia         string
+	AnnotateExpiry         bool
+	ExcludedGeoRegions     []string
+	MaxAgeRating           int
+	Include                *regexp.Regexp
+	Exclude                *regexp.Regexp
+	Limit                  int
+	Sort                   string
+	Serial                 bool
+	Season                 int
+	Format                 string
 }
 
 func CreateRequestParametersFromURL(URL *url.URL) RequestParameters {
 	return RequestParameters{
 		Width:                  getRequestedWidth(URL),
 		MinimumLengthInSeconds: getRequestedMinimumLength(URL),
+		MaximumLengthInSeconds: getRequestedIntegerParameter(URL, "maxLength", 0),
+		Since:                  getRequestedSince(URL),
+		Until:                  getRequestedUntil(URL),
+		AudioOnly:              getRequestedAudioOnly(URL),
+		AudioFormat:            getRequestedAudioFormat(URL),
+		MaxHeight:              getRequestedIntegerParameter(URL, "maxHeight", 0),
+		MaxBitrate:             getRequestedIntegerParameter(URL, "maxBitrate", 0),
+		Codec:                  getRequestedStringParameter(URL, "codec", codecValues, ""),
+		Prefer:                 getRequestedStringParameter(URL, "prefer", preferValues, ""),
+		Language:               getRequestedLanguage(URL),
+		Variant:                getRequestedVariant(URL),
+		OnMissingMedia:         getRequestedStringParameter(URL, "onMissingMedia", onMissingMediaValues, MissingMediaSkip),
+		AnnotateExpiry:         getRequestedBooleanParameter(URL, "annotateExpiry", false),
+		ExcludedGeoRegions:     getRequestedExcludedGeoRegions(URL),
+		MaxAgeRating:           getRequestedIntegerParameter(URL, "maxFsk", defaultMaxAgeRating),
+		Include:                getRequestedRegexParameter(URL, "include"),
+		Exclude:                getRequestedRegexParameter(URL, "exclude"),
+		Limit:                  getRequestedIntegerParameter(URL, "limit", 0),
+		Sort:                   getRequestedStringParameter(URL, "sort", sortValues, ""),
+		Serial:                 getRequestedBooleanParameter(URL, "serial", false),
+		Season:                 getRequestedIntegerParameter(URL, "season", 0),
+		Format:                 getRequestedStringParameter(URL, "format", formatValues, FormatRSS),
 	}
 }
 
@@ -28,9 +108,74 @@ func getRequestedMinimumLength(URL *url.URL) int {
 	return getRequestedIntegerParameter(URL, "minLength", defaultMinLengthInSeconds)
 }
 
+func getRequestedSince(URL *url.URL) time.Time {
+	return getRequestedDateParameter(URL, "since")
+}
+
+// getRequestedUntil yields the end of the requested day, so the given day is part of the range.
+func getRequestedUntil(URL *url.URL) time.Time {
+	until := getRequestedDateParameter(URL, "until")
+	if until.IsZero() {
+		return until
+	}
+	return until.AddDate(0, 0, 1).Add(-time.Millisecond)
+}
+
+func getRequestedAudioOnly(URL *url.URL) bool {
+	return getRequestedBooleanParameter(URL, "audio", false)
+}
+
+func getRequestedAudioFormat(URL *url.URL) string {
+	return getRequestedStringParameter(URL, "audioFormat", audioFormatValues, defaultAudioFormat)
+}
+
+// getRequestedLanguage yields the requested ISO 639-2 language code of the audio track, e.g. deu or eng.
+// An empty result means that no language has been requested.
+func getRequestedLanguage(URL *url.URL) string {
+	language := strings.ToLower(getRequestedValue(URL, "lang"))
+	if !languageRegex.MatchString(language) {
+		return ""
+	}
+	return language
+}
+
+func getRequestedVariant(URL *url.URL) string {
+	return getRequestedStringParameter(URL, "variant", variantValues, VariantMain)
+}
+
+// getRequestedExcludedGeoRegions yields the regions whose geo-restricted episodes shall be dropped, e.g. de or dach.
+// The value all drops all geo-restricted episodes. The regions are sorted and free of duplicates, so equivalent
+// requests yield equal parameters.
+func getRequestedExcludedGeoRegions(URL *url.URL) []string {
+	regions := make([]string, 0)
+	for _, region := range splitList(getRequestedValue(URL, "excludeGeo")) {
+		if len(regions) == 0 || regions[len(regions)-1] != region {
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+// splitList yields the sorted lower case elements of a comma-separated list without empty elements.
+func splitList(list string) []string {
+	elements := make([]string, 0)
+	for _, element := range strings.Split(list, ",") {
+		if element = strings.ToLower(strings.TrimSpace(element)); element != "" {
+			elements = append(elements, element)
+		}
+	}
+	sort.Strings(elements)
+	return elements
+}
+
+// getRequestedValue yields the value of a parameter without surrounding white space.
+func getRequestedValue(URL *url.URL, parameterName string) string {
+	return strings.TrimSpace(URL.Query().Get(parameterName))
+}
+
 func getRequestedIntegerParameter(URL *url.URL, parameterName string, defaultValue int) int {
 	result := defaultValue
-	parameterValue := URL.Query().Get(parameterName)
+	parameterValue := getRequestedValue(URL, parameterName)
 	if parameterValue != "" {
 		tmp, err := strconv.ParseInt(parameterValue, 10, 0)
 		if err == nil {
@@ -39,3 +184,48 @@ func getRequestedIntegerParameter(URL *url.URL, parameterName string, defaultVal
 	}
 	return result
 }
+
+func getRequestedDateParameter(URL *url.URL, parameterName string) (result time.Time) {
+	parameterValue := getRequestedValue(URL, parameterName)
+	if parameterValue != "" {
+		tmp, err := time.Parse(requestDateFormat, parameterValue)
+		if err == nil {
+			result = tmp
+		}
+	}
+	return
+}
+
+// getRequestedRegexParameter yields the compiled regular expression of a parameter or nil if the parameter is
+// missing or no valid regular expression.
+func getRequestedRegexParameter(URL *url.URL, parameterName string) *regexp.Regexp {
+	parameterValue := URL.Query().Get(parameterName)
+	if strings.TrimSpace(parameterValue) == "" {
+		return nil
+	}
+	result, err := regexp.Compile(parameterValue)
+	if err != nil {
+		return nil
+	}
+	return result
+}
+
+func getRequestedBooleanParameter(URL *url.URL, parameterName string, defaultValue bool) bool {
+	result := defaultValue
+	parameterValue := getRequestedValue(URL, parameterName)
+	if parameterValue != "" {
+		tmp, err := strconv.ParseBool(parameterValue)
+		if err == nil {
+			result = tmp
+		}
+	}
+	return result
+}
+
+func getRequestedStringParameter(URL *url.URL, parameterName string, allowedValues []string, defaultValue string) string {
+	parameterValue := strings.ToLower(getRequestedValue(URL, parameterName))
+	if contains(allowedValues, parameterValue) {
+		return parameterValue
+	}
+	return defaultValue
+}
@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AllGeoRegions is the value of the excludeGeo request parameter that excludes all geo-restricted episodes.
+const AllGeoRegions = "all"
+
+// geoRegionValues are the allowed values of the excludeGeo request parameter.
+var geoRegionValues = []string{AllGeoRegions, "dach", "de", "de_fr", "ebu"}
+
+// minimumExplicitAge is the lowest age rating for which episodes are marked as explicit.
+const minimumExplicitAge = 16
+
+var ageRatingRegex = regexp.MustCompile("(?i)^(?:fsk\\s*)?([0-9]+)$")
+
+// descriptions of the geo regions of the television channels
+var geoRegionDescriptions = map[string](string){
+	"de":    "Nur in Deutschland verfügbar",
+	"dach":  "Nur in Deutschland, Österreich und der Schweiz verfügbar",
+	"ebu":   "Nur in Europa verfügbar",
+	"de_fr": "Nur in Deutschland und Frankreich verfügbar",
+}
+
+// Restrictions describe the geo-blocking and the age rating of an episode.
+type Restrictions struct {
+	GeoRegion  string // empty if the episode is available worldwide, e.g. de or dach otherwise
+	MinimumAge int    // zero if the episode has no age rating
+}
+
+// CreateRestrictions creates the restrictions of an episode from the geo region and the FSK age rating given by a
+// television channel. The age rating may be given with or without prefix, e.g. fsk16 or 16. Values such as none or
+// an empty string mean that there is no restriction.
+func CreateRestrictions(geoRegion, ageRating string) Restrictions {
+	restrictions := Restrictions{}
+	if geoRegion = strings.ToLower(geoRegion); geoRegion != "none" {
+		restrictions.GeoRegion = geoRegion
+	}
+	if matches := ageRatingRegex.FindStringSubmatch(strings.TrimSpace(ageRating)); matches != nil {
+		restrictions.MinimumAge, _ = strconv.Atoi(matches[1])
+	}
+	return restrictions
+}
+
+// IsExcluded determines if an episode with these restrictions shall be dropped according to the request parameters.
+func (restrictions Restrictions) IsExcluded(parameters RequestParameters) bool {
+	if restrictions.MinimumAge > parameters.MaxAgeRating {
+		return true
+	}
+	if restrictions.GeoRegion == "" {
+		return false
+	}
+	for _, region := range parameters.ExcludedGeoRegions {
+		if region == AllGeoRegions || region == restrictions.GeoRegion {
+			return true
+		}
+	}
+	return false
+}
+
+// AnnotateRestrictions prefixes the description of an episode with its restrictions. Episodes that are rated FSK 16
+// or higher are marked as explicit by the feed formats that support it.
+func AnnotateRestrictions(episode *Episode) {
+	restrictions := episode.Restrictions
+	notes := make([]string, 0, 2)
+	if restrictions.MinimumAge > 0 {
+		notes = append(notes, fmt.Sprintf("FSK %v", restrictions.MinimumAge))
+	}
+	if restrictions.GeoRegion != "" {
+		description, found := geoRegionDescriptions[restrictions.GeoRegion]
+		if !found {
+			description = "Nur eingeschränkt verfügbar (" + restrictions.GeoRegion + ")"
+		}
+		notes = append(notes, description)
+	}
+	if len(notes) > 0 {
+		prependToDescription(episode, "["+strings.Join(notes, ", ")+"]")
+	}
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCreateRestrictions(t *testing.T) {
+	assertRestrictions(t, Restrictions{}, CreateRestrictions("none", "none"))
+	assertRestrictions(t, Restrictions{}, CreateRestrictions("", "NONE"))
+	assertRestrictions(t, Restrictions{GeoRegion: "dach", MinimumAge: 16}, CreateRestrictions("dach", "fsk16"))
+	assertRestrictions(t, Restrictions{GeoRegion: "de", MinimumAge: 12}, CreateRestrictions("DE", "FSK12"))
+	assertRestrictions(t, Restrictions{MinimumAge: 6}, CreateRestrictions("none", "6"))
+	assertRestrictions(t, Restrictions{MinimumAge: 12}, CreateRestrictions("", " 12 "))
+	assertRestrictions(t, Restrictions{}, CreateRestrictions("", "fsk"))
+}
+
+func TestRestrictionsIsExcluded(t *testing.T) {
+	parameters := RequestParameters{MaxAgeRating: 12, ExcludedGeoRegions: []string{"de"}}
+	assertIsExcluded(t, false, Restrictions{}, parameters)
+	assertIsExcluded(t, false, Restrictions{MinimumAge: 12, GeoRegion: "dach"}, parameters)
+	assertIsExcluded(t, true, Restrictions{MinimumAge: 16}, parameters)
+	assertIsExcluded(t, true, Restrictions{GeoRegion: "de"}, parameters)
+
+	parameters.ExcludedGeoRegions = []string{AllGeoRegions}
+	assertIsExcluded(t, true, Restrictions{GeoRegion: "dach"}, parameters)
+}
+
+func TestAnnotateRestrictions(t *testing.T) {
+	episode := Episode{Description: "Synopsis", Restrictions: Restrictions{GeoRegion: "de", MinimumAge: 16}}
+	AnnotateRestrictions(&episode)
+	assertEquals(t, "[FSK 16, Nur in Deutschland verfügbar] Synopsis", episode.Description)
+	assertEquals(t, "true", fmt.Sprint(episode.IsExplicit()))
+
+	episode = Episode{Description: "Synopsis", Restrictions: Restrictions{MinimumAge: 12}}
+	AnnotateRestrictions(&episode)
+	assertEquals(t, "[FSK 12] Synopsis", episode.Description)
+	assertEquals(t, "false", fmt.Sprint(episode.IsExplicit()))
+
+	episode = Episode{Description: "Synopsis"}
+	AnnotateRestrictions(&episode)
+	assertEquals(t, "Synopsis", episode.Description)
+
+	episode = Episode{Restrictions: Restrictions{MinimumAge: 6}}
+	AnnotateRestrictions(&episode)
+	assertEquals(t, "[FSK 6]", episode.Description)
+}
+
+func assertRestrictions(t *testing.T, expected, actual Restrictions) {
+	if expected != actual {
+		t.Fatalf("Expected %v but got %v.", expected, actual)
+	}
+}
+
+func assertIsExcluded(t *testing.T, expected bool, restrictions Restrictions, parameters RequestParameters) {
+	if restrictions.IsExcluded(parameters) != expected {
+		t.Fatalf("Expected exclusion %v for %v with parameters %v.", expected, restrictions, parameters)
+	}
+}
@@ -3,16 +3,32 @@ package rssfeed
 import (
 	"encoding/xml"
 	"fmt"
+	"log"
+	"net/url"
 	"time"
+	_ "time/tzdata" // the location of the dates must be loadable when the package is initialized
 )
 
+// dateTimeZone is the time zone of the dates in the feed, which is the one of the German television channels.
+const dateTimeZone = "Europe/Berlin"
+
+// dateLocation is loaded once, because loading a location reads the time zone database.
+var dateLocation = loadDateLocation()
+
+// dateFormat is the RFC 822 format with four-digit year that RSS 2.0 requires for pubDate and lastBuildDate.
+const dateFormat = time.RFC1123Z
+
+// PodcastNamespace is the namespace of the elements of the Podcasting 2.0 initiative, e.g. podcast:transcript.
+const PodcastNamespace = "https://podcastindex.org/namespace/1.0"
+
 // Feed represents the root element of an RSS feed.
 // It should be created by the CreateFeed function in order to initialize the feed with reasonable default values.
 type Feed struct {
-	XMLName     xml.Name `xml:"rss"`
-	XMLNSItunes string   `xml:"xmlns:itunes,attr"`
-	Version     string   `xml:"version,attr"`
-	Channel     Channel  `xml:"channel"`
+	XMLName      xml.Name `xml:"rss"`
+	XMLNSItunes  string   `xml:"xmlns:itunes,attr"`
+	XMLNSPodcast string   `xml:"xmlns:podcast,attr,omitempty"`
+	Version      string   `xml:"version,attr"`
+	Channel      Channel  `xml:"channel"`
 }
 
 // Channel is the second mandatory root element of an RSS feed.
@@ -21,16 +37,25 @@ type Channel struct {
 	Title          string           `xml:"title"`
 	Description    *FeedDescription `xml:"description"`
 	Link           string           `xml:"link,omitempty"`
-	LastBuildDate  *time.Time       `xml:"lastBuildDate"`
+	Language       string           `xml:"language,omitempty"`
+	LastBuildDate  *Date            `xml:"lastBuildDate"`
 	Image          *Image           `xml:"image"`
 	ITunesSubtitle string           `xml:"itunes:subtitle,omitempty"`
 	ITunesAuthor   string           `xml:"itunes:author,omitempty"`
 	ITunesSummary  *ITunesSummary   `xml:"itunes:summary"`
-	ITunesCategory string           `xml:"itunes:category,omitempty"`
+	ITunesCategory *ITunesCategory  `xml:"itunes:category"`
 	ITunesImage    *ITunesImage     `xml:"itunes:image"`
+	ITunesExplicit string           `xml:"itunes:explicit,omitempty"`
+	ITunesType     string           `xml:"itunes:type,omitempty"`
 	FeedItems      []FeedItem       `xml:"item"`
 }
 
+// ITunesCategory represents a category of the Apple Podcasts directory, e.g. TV & Film.
+type ITunesCategory struct {
+	XMLName xml.Name `xml:"itunes:category"`
+	Text    string   `xml:"text,attr"`
+}
+
 // ITunesSummary is the XML element to represent the itunes:summary element.
 type ITunesSummary struct {
 	XMLName xml.Name `xml:"itunes:summary"`
@@ -59,7 +84,7 @@ type FeedItem struct {
 	Title                string             `xml:"title"`
 	Link                 string             `xml:"link,omitempty"`
 	Description          *FeedDescription   `xml:"description"`
-	PubDate              *time.Time         `xml:"pubDate"`
+	PubDate              *Date              `xml:"pubDate"`
 	GUID                 *FeedGUID          `xml:"guid"`
 	Enclosure            *FeedItemEnclosure `xml:"enclosure"`
 	ITunesDurationString string             `xml:"itunes:duration,omitempty"`
@@ -67,13 +92,34 @@ type FeedItem struct {
 	ITunesSubtitle       string             `xml:"itunes:subtitle,omitempty"`
 	ITunesSummary        *ItunesSummary     `xml:"itunes:summary"`
 	ITunesImage          *ITunesImage       `xml:"itunes:image"`
+	ITunesExplicit       string             `xml:"itunes:explicit,omitempty"`
+	ITunesSeason         int                `xml:"itunes:season,omitempty"`
+	ITunesEpisode        int                `xml:"itunes:episode,omitempty"`
+	ITunesEpisodeType    string             `xml:"itunes:episodeType,omitempty"`
+	Transcripts          []Transcript       `xml:"podcast:transcript"`
+}
+
+// Transcript represents a subtitle or transcript file of an episode, which requires the podcast namespace.
+type Transcript struct {
+	XMLName  xml.Name `xml:"podcast:transcript"`
+	URL      string   `xml:"url,attr"`
+	Type     string   `xml:"type,attr"`
+	Language string   `xml:"language,attr,omitempty"`
+	Rel      string   `xml:"rel,attr,omitempty"`
+}
+
+// Date represents a point in time that is serialized in the RFC 822 format of RSS 2.0 with the offset of Europe/Berlin.
+// It should be created by the CreateDate function.
+type Date struct {
+	time.Time
 }
 
 // FeedGUID represents a GUID in an RSS feed.
+// It should be created by the CreateGUID function in order to mark only URLs as permalinks.
 type FeedGUID struct {
 	XMLName   xml.Name `xml:"guid"`
 	PermaLink bool     `xml:"isPermaLink,attr"`
-	Text      string   `xml:",innerxml"`
+	Text      string   `xml:",chardata"`
 }
 
 // FeedDescription represents the RSS description element.
@@ -104,9 +150,48 @@ func CreateFeed() Feed {
 	}
 }
 
-// Serialize serializes the RSS feed to a byte array with proper identation.
+// CreateDate creates a date of the feed for the given time.
+func CreateDate(t time.Time) *Date {
+	return &Date{Time: t}
+}
+
+// MarshalXML serializes the date in the RFC 822 format. The time zone is converted to Europe/Berlin, so the dates
+// match the ones shown in the Mediathek.
+func (date Date) MarshalXML(encoder *xml.Encoder, start xml.StartElement) error {
+	return encoder.EncodeElement(date.In(dateLocation).Format(dateFormat), start)
+}
+
+// loadDateLocation yields the location of dateTimeZone or UTC if the time zone is unknown.
+func loadDateLocation() *time.Location {
+	location, err := time.LoadLocation(dateTimeZone)
+	if err != nil {
+		log.Printf("Using UTC for the dates of feeds, because the time zone %v is unknown. %v", dateTimeZone, err)
+		return time.UTC
+	}
+	return location
+}
+
+// CreateGUID creates the GUID of a feed item. The GUID is only marked as permalink if it is an absolute HTTP URL,
+// because podcast players open permalinks in the browser.
+func CreateGUID(id string) *FeedGUID {
+	return &FeedGUID{
+		PermaLink: isPermaLink(id),
+		Text:      id,
+	}
+}
+
+func isPermaLink(id string) bool {
+	parsedURL, err := url.Parse(id)
+	return err == nil && (parsedURL.Scheme == "http" || parsedURL.Scheme == "https") && parsedURL.Host != ""
+}
+
+// Serialize serializes the RSS feed to a byte array with proper identation. The result starts with an XML declaration.
 func (feed *Feed) Serialize() (result []byte, err error) {
-	result, err = xml.MarshalIndent(feed, "", "    ")
+	var content []byte
+	content, err = xml.MarshalIndent(feed, "", "    ")
+	if err == nil {
+		result = append([]byte(xml.Header), content...)
+	}
 	return
 }
 
@@ -1,9 +1,11 @@
 package rssfeed
 
 import (
+	"encoding/xml"
 	"io/ioutil"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestExampleFeed(t *testing.T) {
@@ -69,6 +71,35 @@ func TestExampleFeed(t *testing.T) {
 
 }
 
+func TestDateIsSerializedInRFC822(t *testing.T) {
+	assertSerializedDate(t, time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC), "<pubDate>Fri, 01 Jan 2021 13:00:00 +0100</pubDate>")
+	assertSerializedDate(t, time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC), "<pubDate>Thu, 01 Jul 2021 14:00:00 +0200</pubDate>")
+}
+
+func assertSerializedDate(t *testing.T, date time.Time, expected string) {
+	item := FeedItem{PubDate: CreateDate(date)}
+	actual, err := xml.Marshal(item)
+	if err != nil {
+		t.Fatalf("There should be no error but got %v.", err)
+	}
+	if !strings.Contains(string(actual), expected) {
+		t.Fatalf("Expected the date %v to be serialized as %v and not %v.", date, expected, string(actual))
+	}
+}
+
+func TestGUIDIsEscaped(t *testing.T) {
+	actual, _ := xml.Marshal(CreateGUID("episode<1>&2"))
+	assertEquals(t, `<guid isPermaLink="false">episode&lt;1&gt;&amp;2</guid>`, string(actual))
+}
+
+func TestGUIDIsPermaLinkForURLs(t *testing.T) {
+	assertEquals(t, true, CreateGUID("https://foo.bar/item.html?a=1&b=2").PermaLink)
+	assertEquals(t, true, CreateGUID("http://foo.bar/item.html").PermaLink)
+	assertEquals(t, false, CreateGUID("foo-bar-uuid").PermaLink)
+	assertEquals(t, false, CreateGUID("/item.html").PermaLink)
+	assertEquals(t, false, CreateGUID("urn:uuid:foo-bar").PermaLink)
+}
+
 func TestCreateItunesDurationString(t *testing.T) {
 	assertItunesDurationString(t, 5, "5")
 	assertItunesDurationString(t, 59, "59")
@@ -0,0 +1,357 @@
+package rssfeed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ITunesNamespace is the XML namespace of the iTunes podcast elements.
+const ITunesNamespace = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+
+// Severities of validation issues
+const (
+	// SeverityError marks violations of the RSS 2.0 or podcast requirements that break podcast clients.
+	SeverityError = "error"
+	// SeverityWarning marks missing recommended elements and deviations that most podcast clients tolerate.
+	SeverityWarning = "warning"
+)
+
+var languageCodeRegex = regexp.MustCompile("^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$")
+var itunesDurationRegex = regexp.MustCompile("^([0-9]+:)?([0-9]+:)?[0-9]+$")
+var uuidRegex = regexp.MustCompile("^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$")
+
+// formats of pubDate and lastBuildDate that conform to RFC 822 as required by RSS 2.0
+var rfc822Formats = []string{time.RFC1123Z, time.RFC1123, "Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST"}
+
+var allowedExplicitValues = []string{"true", "false", "yes", "no", "clean"}
+var allowedEpisodeTypes = []string{"full", "trailer", "bonus"}
+var allowedShowTypes = []string{"episodic", "serial"}
+
+// ValidationIssue describes a problem of a feed. The path locates the affected element, e.g. channel/item[2]/guid.
+type ValidationIssue struct {
+	Severity string
+	Path     string
+	Message  string
+}
+
+func (issue ValidationIssue) String() string {
+	return fmt.Sprintf("%v: %v: %v", issue.Severity, issue.Path, issue.Message)
+}
+
+// ValidationReport lists all issues of a feed.
+type ValidationReport struct {
+	Issues []ValidationIssue
+}
+
+// IsValid determines if the feed has no issues of severity error. Warnings are allowed.
+func (report ValidationReport) IsValid() bool {
+	return len(report.Errors()) == 0
+}
+
+// Errors yields the issues of severity error.
+func (report ValidationReport) Errors() []ValidationIssue {
+	errors := make([]ValidationIssue, 0)
+	for _, issue := range report.Issues {
+		if issue.Severity == SeverityError {
+			errors = append(errors, issue)
+		}
+	}
+	return errors
+}
+
+func (report ValidationReport) String() string {
+	lines := make([]string, 0, len(report.Issues))
+	for _, issue := range report.Issues {
+		lines = append(lines, issue.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (report *ValidationReport) addError(path, format string, args ...interface{}) {
+	report.Issues = append(report.Issues, ValidationIssue{Severity: SeverityError, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (report *ValidationReport) addWarning(path, format string, args ...interface{}) {
+	report.Issues = append(report.Issues, ValidationIssue{Severity: SeverityWarning, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks the feed against the requirements of RSS 2.0 and the recommendations for podcasts of Apple
+// Podcasts and Podcasting 2.0.
+func (feed *Feed) Validate() ValidationReport {
+	content, err := feed.Serialize()
+	if err != nil {
+		report := ValidationReport{}
+		report.addError("rss", "the feed cannot be serialized: %v", err)
+		return report
+	}
+	return Validate(content)
+}
+
+// Validate checks a serialized feed against the requirements of RSS 2.0 and the recommendations for podcasts of
+// Apple Podcasts and Podcasting 2.0. Invalid XML results in an issue of severity error.
+func Validate(content []byte) (report ValidationReport) {
+	report.Issues = make([]ValidationIssue, 0)
+	root, err := parseElementTree(content)
+	if err != nil {
+		report.addError("rss", "the feed is no valid XML: %v", err)
+		return
+	}
+	if root.Name.Local != "rss" {
+		report.addError(root.Name.Local, "the root element is not rss")
+		return
+	}
+	if root.attribute("version") != "2.0" {
+		report.addError("rss", "the version is not 2.0")
+	}
+	channels := root.children("", "channel")
+	if len(channels) != 1 {
+		report.addError("rss", "expected exactly one channel but found %v", len(channels))
+		return
+	}
+	validateChannel(&report, channels[0])
+	return
+}
+
+func validateChannel(report *ValidationReport, channel *element) {
+	const path = "channel"
+	requireText(report, channel, path, "", "title", SeverityError)
+	requireText(report, channel, path, "", "link", SeverityError)
+	if channel.child("", "description") == nil {
+		report.addError(path+"/description", "the element is missing")
+	}
+	if language := channel.childText("", "language"); language == "" {
+		report.addWarning(path+"/language", "the element is missing")
+	} else if !languageCodeRegex.MatchString(language) {
+		report.addError(path+"/language", "%q is no language code", language)
+	}
+	if lastBuildDate := channel.child("", "lastBuildDate"); lastBuildDate != nil {
+		validateDate(report, path+"/lastBuildDate", lastBuildDate.Text)
+	}
+
+	requireText(report, channel, path, ITunesNamespace, "author", SeverityWarning)
+	if image := channel.child(ITunesNamespace, "image"); image == nil || image.attribute("href") == "" {
+		report.addWarning(path+"/itunes:image", "the artwork is missing")
+	} else {
+		validateURL(report, path+"/itunes:image", image.attribute("href"))
+	}
+	if category := channel.child(ITunesNamespace, "category"); category == nil || category.attribute("text") == "" {
+		report.addWarning(path+"/itunes:category", "the category is missing")
+	}
+	validateAllowedValue(report, channel, path, "explicit", allowedExplicitValues, SeverityWarning)
+	validateAllowedValue(report, channel, path, "type", allowedShowTypes, "")
+	if guid := channel.child(PodcastNamespace, "guid"); guid != nil && !uuidRegex.MatchString(strings.TrimSpace(guid.Text)) {
+		report.addError(path+"/podcast:guid", "%q is no UUID", guid.Text)
+	}
+
+	items := channel.children("", "item")
+	if len(items) == 0 {
+		report.addWarning(path, "the feed contains no items")
+	}
+	guids := map[string](string){}
+	for i, item := range items {
+		validateItem(report, item, fmt.Sprintf("%v/item[%v]", path, i+1), guids)
+	}
+}
+
+func validateItem(report *ValidationReport, item *element, path string, guids map[string](string)) {
+	requireText(report, item, path, "", "title", SeverityError)
+
+	if guid := item.childText("", "guid"); guid == "" {
+		report.addError(path+"/guid", "the element is missing")
+	} else if otherPath, found := guids[guid]; found {
+		report.addError(path+"/guid", "the GUID %q is already used by %v", guid, otherPath)
+	} else {
+		guids[guid] = path
+	}
+
+	if pubDate := item.child("", "pubDate"); pubDate == nil {
+		report.addError(path+"/pubDate", "the element is missing")
+	} else {
+		validateDate(report, path+"/pubDate", pubDate.Text)
+	}
+
+	if enclosure := item.child("", "enclosure"); enclosure == nil {
+		report.addWarning(path+"/enclosure", "the item has no media file")
+	} else {
+		validateURL(report, path+"/enclosure", enclosure.attribute("url"))
+		if enclosure.attribute("type") == "" {
+			report.addError(path+"/enclosure", "the type is missing")
+		}
+		if length := enclosure.attribute("length"); length == "" {
+			report.addError(path+"/enclosure", "the length is missing")
+		} else if value, err := strconv.ParseInt(length, 10, 64); err != nil || value < 0 {
+			report.addError(path+"/enclosure", "the length %q is no number of bytes", length)
+		}
+	}
+
+	if duration := item.childText(ITunesNamespace, "duration"); duration != "" && !itunesDurationRegex.MatchString(duration) {
+		report.addError(path+"/itunes:duration", "%q is neither seconds nor of form HH:MM:SS", duration)
+	}
+	validatePositiveNumber(report, item, path, "season")
+	validatePositiveNumber(report, item, path, "episode")
+	validateAllowedValue(report, item, path, "episodeType", allowedEpisodeTypes, "")
+	validateAllowedValue(report, item, path, "explicit", allowedExplicitValues, "")
+	for i, transcript := range item.children(PodcastNamespace, "transcript") {
+		transcriptPath := fmt.Sprintf("%v/podcast:transcript[%v]", path, i+1)
+		validateURL(report, transcriptPath, transcript.attribute("url"))
+		if transcript.attribute("type") == "" {
+			report.addError(transcriptPath, "the type is missing")
+		}
+	}
+}
+
+func requireText(report *ValidationReport, parent *element, path, space, name, severity string) {
+	if strings.TrimSpace(parent.childText(space, name)) != "" {
+		return
+	}
+	elementPath := path + "/" + name
+	if space == ITunesNamespace {
+		elementPath = path + "/itunes:" + name
+	}
+	if severity == SeverityError {
+		report.addError(elementPath, "the element is missing or empty")
+	} else {
+		report.addWarning(elementPath, "the element is missing or empty")
+	}
+}
+
+// validateAllowedValue checks the value of an iTunes element. If missingSeverity is empty, the element is optional.
+func validateAllowedValue(report *ValidationReport, parent *element, path, name string, allowedValues []string, missingSeverity string) {
+	elementPath := path + "/itunes:" + name
+	node := parent.child(ITunesNamespace, name)
+	if node == nil {
+		if missingSeverity == SeverityWarning {
+			report.addWarning(elementPath, "the element is missing")
+		}
+		return
+	}
+	for _, allowedValue := range allowedValues {
+		if node.Text == allowedValue {
+			return
+		}
+	}
+	report.addError(elementPath, "%q is not one of %v", node.Text, strings.Join(allowedValues, ", "))
+}
+
+func validatePositiveNumber(report *ValidationReport, parent *element, path, name string) {
+	node := parent.child(ITunesNamespace, name)
+	if node == nil {
+		return
+	}
+	if value, err := strconv.Atoi(node.Text); err != nil || value < 1 {
+		report.addError(path+"/itunes:"+name, "%q is no positive number", node.Text)
+	}
+}
+
+func validateDate(report *ValidationReport, path, value string) {
+	for _, format := range rfc822Formats {
+		if date, err := time.Parse(format, value); err == nil {
+			if date.Year() < 1900 {
+				report.addError(path, "the date %q is not set", value)
+			}
+			return
+		}
+	}
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		if date.IsZero() {
+			report.addError(path, "the date %q is not set", value)
+		} else {
+			report.addWarning(path, "the date %q is not in RFC 822 format", value)
+		}
+		return
+	}
+	report.addError(path, "%q is no date in RFC 822 format", value)
+}
+
+func validateURL(report *ValidationReport, path, value string) {
+	parsedURL, err := url.Parse(value)
+	if value == "" || err != nil || !parsedURL.IsAbs() || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		report.addError(path, "%q is no absolute HTTP URL", value)
+	}
+}
+
+// element is a node of a generic XML tree. In contrast to decoding into structs, it distinguishes elements with
+// the same local name in different namespaces, e.g. title and itunes:title.
+type element struct {
+	Name       xml.Name
+	Attributes []xml.Attr
+	Text       string
+	Children   []*element
+}
+
+func parseElementTree(content []byte) (root *element, err error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	stack := make([]*element, 0)
+	for {
+		var token xml.Token
+		token, err = decoder.Token()
+		if err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil {
+			return
+		}
+		switch typedToken := token.(type) {
+		case xml.StartElement:
+			node := &element{Name: typedToken.Name, Attributes: typedToken.Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, node)
+			} else if root == nil {
+				root = node
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].Text += string(typedToken)
+			}
+		}
+	}
+	if root == nil {
+		err = fmt.Errorf("the document is empty")
+	}
+	return
+}
+
+func (node *element) children(space, name string) []*element {
+	result := make([]*element, 0)
+	for _, child := range node.Children {
+		if child.Name.Space == space && child.Name.Local == name {
+			result = append(result, child)
+		}
+	}
+	return result
+}
+
+func (node *element) child(space, name string) *element {
+	if children := node.children(space, name); len(children) > 0 {
+		return children[0]
+	}
+	return nil
+}
+
+func (node *element) childText(space, name string) string {
+	if child := node.child(space, name); child != nil {
+		return strings.TrimSpace(child.Text)
+	}
+	return ""
+}
+
+func (node *element) attribute(name string) string {
+	for _, attribute := range node.Attributes {
+		if attribute.Name.Space == "" && attribute.Name.Local == name {
+			return attribute.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+package rssfeed
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateFixtures(t *testing.T) {
+	files, _ := filepath.Glob("../testdata/*.xml")
+	if len(files) == 0 {
+		t.Fatal("We expected feed fixtures.")
+	}
+	for _, file := range files {
+		content, _ := ioutil.ReadFile(file)
+		// the build date of the ZDF fixtures is replaced, because it changes with every run
+		content = []byte(strings.Replace(string(content), "<lastBuildDate>NOW</lastBuildDate>", "<lastBuildDate>Fri, 01 Jan 2021 12:00:00 +0000</lastBuildDate>", 1))
+		report := Validate(content)
+		if !report.IsValid() {
+			t.Fatalf("We expected %v to be valid.\n%v", file, report)
+		}
+	}
+}
+
+func TestValidateExampleFeed(t *testing.T) {
+	content, _ := ioutil.ReadFile("testdata/example_rss.xml")
+	report := Validate(content)
+	assertEquals(t, "error: channel/link: the element is missing or empty\n"+
+		"error: channel/item[1]/pubDate: the element is missing\n"+
+		"error: channel/item[1]/enclosure: the length is missing\n"+
+		"error: channel/item[2]/guid: the element is missing\n"+
+		"error: channel/item[2]/pubDate: the element is missing", ValidationReport{Issues: report.Errors()}.String())
+}
+
+func TestValidateFeed(t *testing.T) {
+	feed := createValidFeed()
+	report := feed.Validate()
+	if !report.IsValid() {
+		t.Fatalf("We expected no errors but got:\n%v", report)
+	}
+}
+
+func TestValidateFeedWithInvalidItems(t *testing.T) {
+	feed := createValidFeed()
+	zeroDate := time.Time{}
+	item := feed.Channel.FeedItems[0]
+	item.Title = " "
+	item.PubDate = CreateDate(zeroDate)
+	item.Enclosure = &FeedItemEnclosure{URL: "/relative.mp4", Type: "video/mp4", Length: "many"}
+	item.ITunesDurationString = "1h"
+	item.ITunesEpisodeType = "clip"
+	item.ITunesExplicit = "maybe"
+	feed.Channel.FeedItems = append(feed.Channel.FeedItems, item)
+
+	report := feed.Validate()
+	assertEquals(t, false, report.IsValid())
+	assertEquals(t, "error: channel/item[2]/title: the element is missing or empty\n"+
+		"error: channel/item[2]/guid: the GUID \"episode-1\" is already used by channel/item[1]\n"+
+		"error: channel/item[2]/pubDate: the date \"Mon, 01 Jan 0001 00:53:28 +0053\" is not set\n"+
+		"error: channel/item[2]/enclosure: \"/relative.mp4\" is no absolute HTTP URL\n"+
+		"error: channel/item[2]/enclosure: the length \"many\" is no number of bytes\n"+
+		"error: channel/item[2]/itunes:duration: \"1h\" is neither seconds nor of form HH:MM:SS\n"+
+		"error: channel/item[2]/itunes:episodeType: \"clip\" is not one of full, trailer, bonus\n"+
+		"error: channel/item[2]/itunes:explicit: \"maybe\" is not one of true, false, yes, no, clean", ValidationReport{Issues: report.Errors()}.String())
+}
+
+func TestValidatePodcastElements(t *testing.T) {
+	feed := createValidFeed()
+	feed.XMLNSPodcast = PodcastNamespace
+	feed.Channel.FeedItems[0].Enclosure.Length = ""
+	feed.Channel.FeedItems[0].Transcripts = []Transcript{
+		{URL: "https://foo.bar/item.vtt", Type: "text/vtt"},
+		{URL: "item.srt"},
+	}
+	content, _ := feed.Serialize()
+	content = []byte(strings.Replace(string(content), "<item>", "<podcast:guid>show-1</podcast:guid><item>", 1))
+
+	report := Validate(content)
+	assertEquals(t, "error: channel/podcast:guid: \"show-1\" is no UUID\n"+
+		"error: channel/item[1]/enclosure: the length is missing\n"+
+		"error: channel/item[1]/podcast:transcript[2]: \"item.srt\" is no absolute HTTP URL\n"+
+		"error: channel/item[1]/podcast:transcript[2]: the type is missing", ValidationReport{Issues: report.Errors()}.String())
+}
+
+func TestValidateInvalidXML(t *testing.T) {
+	report := Validate([]byte("<rss><channel>"))
+	assertEquals(t, false, report.IsValid())
+	report = Validate([]byte("<feed></feed>"))
+	assertEquals(t, "error: feed: the root element is not rss", report.String())
+}
+
+func createValidFeed() Feed {
+	pubDate := time.Date(2021, 1, 1, 20, 15, 0, 0, time.UTC)
+	feed := CreateFeed()
+	feed.Channel = Channel{
+		Title:          "Test",
+		Link:           "https://foo.bar/",
+		Description:    &FeedDescription{Text: "Some long description."},
+		Language:       "de",
+		ITunesAuthor:   "Foo",
+		ITunesCategory: &ITunesCategory{Text: "TV & Film"},
+		ITunesImage:    &ITunesImage{URL: "https://foo.bar/foo.png"},
+		ITunesExplicit: "false",
+		FeedItems: []FeedItem{
+			{
+				Title:                "TestItem",
+				PubDate:              CreateDate(pubDate),
+				GUID:                 &FeedGUID{Text: "episode-1"},
+				Enclosure:            &FeedItemEnclosure{URL: "https://foo.bar/item.mp4", Type: "video/mp4", Length: "42"},
+				ITunesDurationString: CreateItunesDurationStringFromSeconds(422),
+				ITunesEpisode:        1,
+			},
+		},
+	}
+	return feed
+}
+
+func assertEquals(t *testing.T, expected, actual interface{}) {
+	if expected != actual {
+		t.Fatalf("Expected %v but got %v.", expected, actual)
+	}
+}
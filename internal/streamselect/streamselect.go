@@ -0,0 +1,287 @@
+package streamselect
+
+import (
+	"log"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/seiferma/docker_mediathek2rss/internal"
+	"github.com/seiferma/docker_mediathek2rss/internal/manifest"
+)
+
+// Values of the prefer request parameter
+const (
+	PreferClosestWidth = ""
+	PreferSmallest     = "smallest"
+	PreferLargest      = "largest"
+)
+
+// Candidate represents one media stream of an episode that might be used as enclosure.
+// Unknown numeric values are zero and unknown strings are empty.
+type Candidate struct {
+	URL        string
+	MimeType   string
+	Width      int
+	Height     int
+	Bitrate    int // in kbit/s
+	Codec      string
+	Container  string
+	IsAdaptive bool
+	Rank       int // relative quality as indicated by the provider, higher is better
+}
+
+// Constraints describe the wishes of the user regarding the selected stream.
+type Constraints struct {
+	Width      int
+	MaxHeight  int
+	MaxBitrate int
+	Codec      string
+	Prefer     string
+}
+
+// CreateConstraints creates the constraints for the stream selection from the request parameters.
+func CreateConstraints(parameters internal.RequestParameters) Constraints {
+	return Constraints{
+		Width:      parameters.Width,
+		MaxHeight:  parameters.MaxHeight,
+		MaxBitrate: parameters.MaxBitrate,
+		Codec:      parameters.Codec,
+		Prefer:     parameters.Prefer,
+	}
+}
+
+// Resolve determines the URL and MIME type of the enclosure of an episode from the given candidates.
+//
+// In audio-only mode, the best audio stream is used. If there is none, the audio is extracted from the smallest
+// video stream if the media services allow this. Otherwise, the video stream matching the request parameters
+// is used. The optional fnRefineVideo function can replace the selected video stream by a better one. If there
+// are only adaptive streams, a variant of them is used, which the media services might remux to an MP4 file.
+func Resolve(candidates []Candidate, parameters internal.RequestParameters, services *internal.MediaServices, fnRefineVideo func(Candidate, Constraints) Candidate) (URL, mimeType string) {
+	constraints := CreateConstraints(parameters)
+	if parameters.AudioOnly {
+		audio, found := SelectAudio(candidates)
+		if found {
+			return audio.URL, audio.MimeType
+		}
+		constraints.Prefer = PreferSmallest
+		video, found := SelectVideo(candidates, constraints)
+		if !found {
+			video, _ = SelectAdaptive(candidates, constraints, services)
+		}
+		return services.GetAudioFromVideo(video.URL, video.MimeType, parameters)
+	}
+
+	video, found := SelectVideo(candidates, constraints)
+	if !found {
+		video, found = SelectAdaptive(candidates, constraints, services)
+		if !found {
+			return
+		}
+		return services.GetVideoFromManifest(video.URL, video.MimeType)
+	}
+	if fnRefineVideo != nil {
+		video = fnRefineVideo(video, constraints)
+	}
+	return video.URL, video.MimeType
+}
+
+// SelectAudio selects the non-adaptive audio stream with the highest bitrate or rank.
+func SelectAudio(candidates []Candidate) (selected Candidate, found bool) {
+	for _, candidate := range candidates {
+		if candidate.IsAdaptive || !internal.IsAudioMimeType(candidate.MimeType) {
+			continue
+		}
+		if !found || candidate.Bitrate > selected.Bitrate || (candidate.Bitrate == selected.Bitrate && candidate.Rank > selected.Rank) {
+			selected = candidate
+			found = true
+		}
+	}
+	return
+}
+
+// SelectVideo selects the non-adaptive MP4 video stream that matches the constraints best.
+//
+// Streams that exceed the maximum height or bitrate or that use another codec are excluded. Unknown properties
+// of a stream never exclude it. If all streams are excluded, the smallest stream is selected, so the result gets
+// as close to the constraints as possible.
+//
+// From the remaining streams, the smallest or largest stream is selected if requested. Otherwise, the stream
+// whose width is closest to the requested width is selected.
+func SelectVideo(candidates []Candidate, constraints Constraints) (selected Candidate, found bool) {
+	videos := make([]Candidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		if isMP4Video(candidate) {
+			videos = append(videos, candidate)
+		}
+	}
+	if len(videos) == 0 {
+		return
+	}
+	return selectMatching(videos, constraints), true
+}
+
+// SelectAdaptive selects the variant of an HLS or DASH stream that matches the constraints best. The variants are
+// selected like in SelectVideo. HLS streams are preferred because each of their variants can be played on its own.
+//
+// If the media services cannot read manifests or no manifest could be read, the manifest of the first adaptive
+// stream is selected.
+func SelectAdaptive(candidates []Candidate, constraints Constraints, services *internal.MediaServices) (selected Candidate, found bool) {
+	adaptive := make([]Candidate, 0)
+	for _, candidate := range candidates {
+		if candidate.IsAdaptive && !internal.IsAudioMimeType(candidate.MimeType) {
+			adaptive = append(adaptive, candidate)
+		}
+	}
+	if len(adaptive) == 0 {
+		return
+	}
+	sort.SliceStable(adaptive, func(i, j int) bool {
+		return adaptive[i].Container == "m3u8" && adaptive[j].Container != "m3u8"
+	})
+	if services.ManifestReader == nil {
+		return adaptive[0], true
+	}
+
+	for _, candidate := range adaptive {
+		variants, err := services.ManifestReader.GetVariants(candidate.URL)
+		if err != nil {
+			log.Printf("Could not read the manifest %v: %v", candidate.URL, err)
+			continue
+		}
+		videos := createVariantCandidates(variants)
+		if len(videos) > 0 {
+			return selectMatching(videos, constraints), true
+		}
+	}
+	return adaptive[0], true
+}
+
+// GetCodec determines the video codec from the codecs of an RFC 6381 codecs parameter.
+func GetCodec(codecs string) string {
+	switch {
+	case strings.Contains(codecs, "avc1"), strings.Contains(codecs, "avc3"):
+		return "h264"
+	case strings.Contains(codecs, "hvc1"), strings.Contains(codecs, "hev1"):
+		return "h265"
+	case strings.Contains(codecs, "vp9"), strings.Contains(codecs, "vp09"):
+		return "vp9"
+	}
+	return ""
+}
+
+// createVariantCandidates creates candidates from the video variants of a manifest.
+func createVariantCandidates(variants []manifest.Variant) []Candidate {
+	candidates := make([]Candidate, 0, len(variants))
+	for _, variant := range variants {
+		if variant.IsAudio {
+			continue
+		}
+		candidate := Candidate{
+			URL:        variant.URL,
+			MimeType:   variant.MimeType,
+			Width:      variant.Width,
+			Height:     variant.Height,
+			Bitrate:    variant.Bitrate,
+			Codec:      GetCodec(variant.Codecs),
+			IsAdaptive: manifest.IsManifestMimeType(variant.MimeType),
+		}
+		if candidate.IsAdaptive {
+			candidate.Container = "m3u8"
+		} else {
+			candidate.Container = "mp4"
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// selectMatching selects the candidate that matches the constraints best. If no candidate matches, the smallest
+// one is selected.
+func selectMatching(candidates []Candidate, constraints Constraints) Candidate {
+	matching := make([]Candidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		if matches(candidate, constraints) {
+			matching = append(matching, candidate)
+		}
+	}
+	if len(matching) == 0 {
+		return selectBySize(candidates, PreferSmallest)
+	}
+
+	if constraints.Prefer == PreferSmallest || constraints.Prefer == PreferLargest {
+		return selectBySize(matching, constraints.Prefer)
+	}
+	return selectByWidth(matching, constraints.Width)
+}
+
+func isMP4Video(candidate Candidate) bool {
+	return !candidate.IsAdaptive && candidate.Container == "mp4" && !internal.IsAudioMimeType(candidate.MimeType)
+}
+
+func matches(candidate Candidate, constraints Constraints) bool {
+	if constraints.MaxHeight > 0 && candidate.Height > constraints.MaxHeight {
+		return false
+	}
+	if constraints.MaxBitrate > 0 && candidate.Bitrate > constraints.MaxBitrate {
+		return false
+	}
+	if constraints.Codec != "" && candidate.Codec != "" && candidate.Codec != constraints.Codec {
+		return false
+	}
+	return true
+}
+
+// selectBySize orders the candidates by resolution, bitrate and rank. Candidates of unknown size are only selected
+// if there are no other candidates.
+func selectBySize(candidates []Candidate, prefer string) Candidate {
+	sorted := make([]Candidate, len(candidates))
+	copy(sorted, candidates)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		iKnown, jKnown := hasKnownSize(sorted[i]), hasKnownSize(sorted[j])
+		if iKnown != jKnown {
+			return iKnown
+		}
+		iSize, jSize := getSize(sorted[i]), getSize(sorted[j])
+		if iSize == jSize {
+			iSize, jSize = sorted[i].Bitrate, sorted[j].Bitrate
+		}
+		if iSize == jSize {
+			iSize, jSize = sorted[i].Rank, sorted[j].Rank
+		}
+		if prefer == PreferLargest {
+			return iSize > jSize
+		}
+		return iSize < jSize
+	})
+	return sorted[0]
+}
+
+// selectByWidth selects the candidate whose width is closest to the requested width. Candidates of unknown width
+// are only selected if there are no other candidates.
+func selectByWidth(candidates []Candidate, width int) Candidate {
+	selected := candidates[0]
+	bestDistance := math.MaxFloat64
+	for _, candidate := range candidates {
+		if candidate.Width == 0 {
+			continue
+		}
+		distance := math.Abs(float64(width - candidate.Width))
+		if distance < bestDistance {
+			bestDistance = distance
+			selected = candidate
+		}
+	}
+	return selected
+}
+
+func hasKnownSize(candidate Candidate) bool {
+	return getSize(candidate) > 0 || candidate.Bitrate > 0
+}
+
+func getSize(candidate Candidate) int {
+	if candidate.Height > 0 {
+		return candidate.Height
+	}
+	return candidate.Width
+}
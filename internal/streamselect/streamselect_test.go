@@ -0,0 +1,243 @@
+package streamselect
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/seiferma/docker_mediathek2rss/internal"
+	"github.com/seiferma/docker_mediathek2rss/internal/manifest"
+)
+
+var candidates = []Candidate{
+	{URL: "adaptive", MimeType: "application/x-mpegURL", Container: "m3u8", IsAdaptive: true},
+	{URL: "small", MimeType: "video/mp4", Container: "mp4", Width: 640, Height: 360, Bitrate: 800, Codec: "h264"},
+	{URL: "medium", MimeType: "video/mp4", Container: "mp4", Width: 1280, Height: 720, Bitrate: 3000, Codec: "h264"},
+	{URL: "large", MimeType: "video/mp4", Container: "mp4", Width: 1920, Height: 1080, Bitrate: 6000, Codec: "h265"},
+	{URL: "webm", MimeType: "video/webm", Container: "webm", Width: 1920, Height: 1080, Codec: "vp9"},
+}
+
+func TestSelectVideoClosestToWidth(t *testing.T) {
+	assertSelectVideo(t, "large", Constraints{Width: 1920})
+	assertSelectVideo(t, "medium", Constraints{Width: 1100})
+	assertSelectVideo(t, "small", Constraints{Width: 100})
+}
+
+func TestSelectVideoWithPreference(t *testing.T) {
+	assertSelectVideo(t, "small", Constraints{Width: 1920, Prefer: PreferSmallest})
+	assertSelectVideo(t, "large", Constraints{Width: 100, Prefer: PreferLargest})
+}
+
+func TestSelectVideoWithLimits(t *testing.T) {
+	assertSelectVideo(t, "medium", Constraints{Width: 1920, MaxHeight: 720})
+	assertSelectVideo(t, "medium", Constraints{Width: 1920, MaxBitrate: 5000})
+	assertSelectVideo(t, "medium", Constraints{Width: 1920, Codec: "h264"})
+	assertSelectVideo(t, "small", Constraints{Width: 1920, MaxHeight: 240})
+}
+
+func TestSelectVideoWithUnknownProperties(t *testing.T) {
+	unknown := []Candidate{
+		{URL: "unknown", MimeType: "video/mp4", Container: "mp4"},
+		{URL: "known", MimeType: "video/mp4", Container: "mp4", Width: 960, Height: 540},
+	}
+	actual, _ := SelectVideo(unknown, Constraints{Width: 1920, Codec: "h265", MaxBitrate: 100})
+	assertEquals(t, "known", actual.URL)
+	actual, _ = SelectVideo(unknown, Constraints{Prefer: PreferSmallest})
+	assertEquals(t, "known", actual.URL)
+	actual, _ = SelectVideo(unknown[:1], Constraints{Width: 1920})
+	assertEquals(t, "unknown", actual.URL)
+}
+
+func TestSelectVideoWithoutVideos(t *testing.T) {
+	_, found := SelectVideo(candidates[:1], Constraints{Width: 1920})
+	if found {
+		t.Fatal("There should be no video found.")
+	}
+}
+
+func TestSelectAudio(t *testing.T) {
+	audio := []Candidate{
+		{URL: "low", MimeType: "audio/mp4", Rank: 1},
+		{URL: "high", MimeType: "audio/mp4", Rank: 3},
+		{URL: "video", MimeType: "video/mp4", Container: "mp4", Rank: 5},
+	}
+	actual, found := SelectAudio(audio)
+	if !found {
+		t.Fatal("There should be an audio stream found.")
+	}
+	assertEquals(t, "high", actual.URL)
+
+	_, found = SelectAudio(candidates)
+	if found {
+		t.Fatal("There should be no audio stream found.")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	parameters := internal.RequestParameters{Width: 1280}
+	URL, mimeType := Resolve(candidates, parameters, &internal.MediaServices{}, nil)
+	assertEquals(t, "medium", URL)
+	assertEquals(t, "video/mp4", mimeType)
+
+	fnRefine := func(candidate Candidate, constraints Constraints) Candidate {
+		candidate.URL = candidate.URL + "-refined"
+		return candidate
+	}
+	URL, _ = Resolve(candidates, parameters, &internal.MediaServices{}, fnRefine)
+	assertEquals(t, "medium-refined", URL)
+}
+
+func TestResolveAudioOnly(t *testing.T) {
+	parameters := internal.RequestParameters{Width: 1920, AudioOnly: true}
+	URL, mimeType := Resolve(candidates, parameters, &internal.MediaServices{}, nil)
+	assertEquals(t, "small", URL)
+	assertEquals(t, "video/mp4", mimeType)
+
+	withAudio := append([]Candidate{{URL: "audio", MimeType: "audio/mpeg"}}, candidates...)
+	URL, mimeType = Resolve(withAudio, parameters, &internal.MediaServices{}, nil)
+	assertEquals(t, "audio", URL)
+	assertEquals(t, "audio/mpeg", mimeType)
+}
+
+type manifestReaderMock map[string]([]manifest.Variant)
+
+func (reader manifestReaderMock) GetVariants(URL string) ([]manifest.Variant, error) {
+	variants, found := reader[URL]
+	if !found {
+		return nil, errors.New("test error")
+	}
+	return variants, nil
+}
+
+type remuxerMock struct{}
+
+func (remuxer remuxerMock) GetVideoURL(manifestURL string) (URL, mimeType string) {
+	return manifestURL + ".mp4", "video/mp4"
+}
+
+func (remuxer remuxerMock) OwnsURL(URL string) bool {
+	return false
+}
+
+var adaptiveCandidates = []Candidate{
+	{URL: "dash", MimeType: manifest.DASHMimeType, Container: "mpd", IsAdaptive: true},
+	{URL: "hls", MimeType: manifest.HLSMimeType, Container: "m3u8", IsAdaptive: true},
+}
+
+var manifestReader = manifestReaderMock{
+	"hls": {
+		{URL: "hls-360", MimeType: manifest.HLSMimeType, Width: 640, Height: 360, Bitrate: 1200, Codecs: "avc1.4d401e"},
+		{URL: "hls-720", MimeType: manifest.HLSMimeType, Width: 1280, Height: 720, Bitrate: 3500, Codecs: "avc1.640028"},
+		{URL: "hls-audio", MimeType: manifest.HLSMimeType, Bitrate: 64, IsAudio: true},
+	},
+	"dash": {
+		{URL: "dash-360.mp4", MimeType: "video/mp4", Width: 640, Height: 360},
+	},
+}
+
+func TestSelectAdaptive(t *testing.T) {
+	services := &internal.MediaServices{ManifestReader: manifestReader}
+	actual, found := SelectAdaptive(adaptiveCandidates, Constraints{Width: 1280}, services)
+	if !found {
+		t.Fatal("There should be a variant found.")
+	}
+	assertEquals(t, "hls-720", actual.URL)
+	assertEquals(t, "h264", actual.Codec)
+	assertEquals(t, true, actual.IsAdaptive)
+
+	actual, _ = SelectAdaptive(adaptiveCandidates, Constraints{Width: 1280, MaxHeight: 480}, services)
+	assertEquals(t, "hls-360", actual.URL)
+}
+
+func TestSelectAdaptiveWithUnreadableManifest(t *testing.T) {
+	services := &internal.MediaServices{ManifestReader: manifestReaderMock{"dash": manifestReader["dash"]}}
+	actual, _ := SelectAdaptive(adaptiveCandidates, Constraints{Width: 1280}, services)
+	assertEquals(t, "dash-360.mp4", actual.URL)
+	assertEquals(t, "mp4", actual.Container)
+	assertEquals(t, false, actual.IsAdaptive)
+
+	services = &internal.MediaServices{ManifestReader: manifestReaderMock{}}
+	actual, _ = SelectAdaptive(adaptiveCandidates, Constraints{Width: 1280}, services)
+	assertEquals(t, "hls", actual.URL)
+}
+
+func TestSelectAdaptiveWithoutManifestReader(t *testing.T) {
+	actual, found := SelectAdaptive(adaptiveCandidates, Constraints{Width: 1280}, &internal.MediaServices{})
+	if !found {
+		t.Fatal("There should be a manifest found.")
+	}
+	assertEquals(t, "hls", actual.URL)
+
+	_, found = SelectAdaptive(candidates[1:], Constraints{Width: 1280}, &internal.MediaServices{})
+	if found {
+		t.Fatal("There should be no adaptive stream found.")
+	}
+}
+
+func TestResolveAdaptiveOnly(t *testing.T) {
+	parameters := internal.RequestParameters{Width: 1280}
+	services := &internal.MediaServices{ManifestReader: manifestReader}
+	URL, mimeType := Resolve(adaptiveCandidates, parameters, services, nil)
+	assertEquals(t, "hls-720", URL)
+	assertEquals(t, manifest.HLSMimeType, mimeType)
+
+	services.VideoRemuxer = remuxerMock{}
+	URL, mimeType = Resolve(adaptiveCandidates, parameters, services, nil)
+	assertEquals(t, "hls-720.mp4", URL)
+	assertEquals(t, "video/mp4", mimeType)
+
+	parameters.AudioOnly = true
+	URL, mimeType = Resolve(adaptiveCandidates, parameters, services, nil)
+	assertEquals(t, "hls-360", URL)
+	assertEquals(t, manifest.HLSMimeType, mimeType)
+}
+
+func TestResolveDASHWithSeparateAudio(t *testing.T) {
+	const dashManifest = `<MPD xmlns="urn:mpeg:dash:schema:mpd:2011">
+  <Period>
+    <AdaptationSet mimeType="video/mp4">
+      <Representation id="1" bandwidth="900000" width="640" height="360"><BaseURL>video_360.mp4</BaseURL></Representation>
+    </AdaptationSet>
+    <AdaptationSet mimeType="audio/mp4">
+      <Representation id="2" bandwidth="128000"><BaseURL>audio.m4a</BaseURL></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+	variants, err := manifest.Parse("https://example.org/manifest.mpd", []byte(dashManifest))
+	if err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	dashCandidates := adaptiveCandidates[:1]
+	parameters := internal.RequestParameters{Width: 1280}
+	services := &internal.MediaServices{ManifestReader: manifestReaderMock{"dash": variants}}
+
+	// the silent video file is not selected, so the manifest is the enclosure
+	URL, mimeType := Resolve(dashCandidates, parameters, services, nil)
+	assertEquals(t, "dash", URL)
+	assertEquals(t, manifest.DASHMimeType, mimeType)
+
+	services.VideoRemuxer = remuxerMock{}
+	URL, mimeType = Resolve(dashCandidates, parameters, services, nil)
+	assertEquals(t, "dash.mp4", URL)
+	assertEquals(t, "video/mp4", mimeType)
+}
+
+func TestGetCodec(t *testing.T) {
+	assertEquals(t, "h264", GetCodec("avc1.4d401e,mp4a.40.2"))
+	assertEquals(t, "h265", GetCodec("hvc1.1.6.L120"))
+	assertEquals(t, "vp9", GetCodec("vp09.00.10.08"))
+	assertEquals(t, "", GetCodec("mp4a.40.2"))
+}
+
+func assertSelectVideo(t *testing.T, expectedURL string, constraints Constraints) {
+	actual, found := SelectVideo(candidates, constraints)
+	if !found {
+		t.Fatal("There should be a video found.")
+	}
+	assertEquals(t, expectedURL, actual.URL)
+}
+
+func assertEquals(t *testing.T, expected, actual interface{}) {
+	if actual != expected {
+		t.Fatalf("Expected %v but got %v.", expected, actual)
+	}
+}
@@ -0,0 +1,399 @@
+package transcoder
+
+import (
+	"container/list"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PathPrefix is the path below which the transcoder serves the extracted audio files and the remuxed videos.
+const PathPrefix = "/media/"
+
+const transcodeTimeout = 30 * time.Minute
+const temporaryFileSuffix = ".tmp"
+
+// defaultWaitTimeout is how long a request waits for a running extraction before it is asked to retry later.
+const defaultWaitTimeout = 20 * time.Second
+
+// retryAfterSeconds is the delay that clients are asked to wait if the extraction is still running.
+const retryAfterSeconds = 30
+
+// cacheFileRegex matches the names of the files created by the transcoder, which are the SHA-256 hash of the source
+// URL and the extension of the format, optionally followed by the suffix of temporary files. Other files in the cache
+// directory are neither counted nor removed.
+var cacheFileRegex = regexp.MustCompile("^[0-9a-f]{64}\\.(m4a|mp3|opus|mp4)(\\.tmp)?$")
+
+// errStillRunning is reported if an extraction did not finish while the request was waiting for it.
+var errStillRunning = errors.New("the media file is still being created")
+
+// Format describes a target format of the audio extraction or the remuxing.
+type Format struct {
+	Name      string
+	Extension string
+	MimeType  string
+	codecArgs []string
+}
+
+var audioFormats = map[string](Format){
+	"aac": {
+		Name:      "aac",
+		Extension: ".m4a",
+		MimeType:  "audio/mp4",
+		codecArgs: []string{"-vn", "-c:a", "aac", "-b:a", "128k", "-f", "mp4", "-movflags", "+faststart"},
+	},
+	"mp3": {
+		Name:      "mp3",
+		Extension: ".mp3",
+		MimeType:  "audio/mpeg",
+		codecArgs: []string{"-vn", "-c:a", "libmp3lame", "-b:a", "128k", "-f", "mp3"},
+	},
+	"opus": {
+		Name:      "opus",
+		Extension: ".opus",
+		MimeType:  "audio/ogg",
+		codecArgs: []string{"-vn", "-c:a", "libopus", "-b:a", "64k", "-f", "ogg"},
+	},
+}
+
+// remuxFormat copies the streams of an adaptive stream into a single MP4 file without transcoding them.
+var remuxFormat = Format{
+	Name:      "mp4",
+	Extension: ".mp4",
+	MimeType:  "video/mp4",
+	codecArgs: []string{"-c", "copy", "-bsf:a", "aac_adtstoasc", "-f", "mp4", "-movflags", "+faststart"},
+}
+
+// GetAudioFormat returns the audio format for a given name (aac, mp3 or opus).
+func GetAudioFormat(name string) (format Format, found bool) {
+	format, found = audioFormats[name]
+	return
+}
+
+// Config holds the configuration of a transcoder.
+type Config struct {
+	// FfmpegPath is the path to the ffmpeg binary.
+	FfmpegPath string
+	// CacheDirectory is the directory that holds the extracted audio files and the remuxed videos.
+	CacheDirectory string
+	// MaxCacheBytes is the maximum size of all files in the cache directory.
+	MaxCacheBytes int64
+	// BaseURL is the public URL of the web service, which is used for building enclosure URLs.
+	BaseURL string
+	// Secret is used for signing enclosure URLs, so the transcoder only processes URLs created by the web service.
+	Secret []byte
+}
+
+// Transcoder extracts the audio track of video files and remuxes adaptive streams via ffmpeg.
+// It serves the results from a size-bounded cache on disk.
+//
+// Users should always create this via CreateTranscoder to correctly initialize the cache.
+type Transcoder struct {
+	config      Config
+	fnTranscode func(ctx context.Context, sourceURL string, format Format, targetPath string) error
+	waitTimeout time.Duration
+
+	mutex      sync.Mutex
+	cacheBytes int64
+	lru        *list.List
+	entries    map[string](*list.Element)
+	inFlight   map[string](*transcodeJob)
+}
+
+type cacheEntry struct {
+	filename string
+	size     int64
+}
+
+// transcodeJob is a running extraction. Its error is set before done is closed.
+type transcodeJob struct {
+	done chan struct{}
+	err  error
+}
+
+// CreateTranscoder creates a transcoder and initializes its cache with the files already present in the cache directory.
+func CreateTranscoder(config Config) (transcoder *Transcoder, err error) {
+	if config.FfmpegPath == "" || config.BaseURL == "" || len(config.Secret) == 0 {
+		err = errors.New("The transcoder requires the ffmpeg path, the base URL and a secret")
+		return
+	}
+	err = os.MkdirAll(config.CacheDirectory, 0755)
+	if err != nil {
+		return
+	}
+	transcoder = &Transcoder{
+		config:      config,
+		waitTimeout: defaultWaitTimeout,
+		lru:         list.New(),
+		entries:     map[string](*list.Element){},
+		inFlight:    map[string](*transcodeJob){},
+	}
+	transcoder.fnTranscode = transcoder.runFfmpeg
+	err = transcoder.loadCache()
+	return
+}
+
+// GetAudioURL yields the URL of the audio track of the given video in the given format and its MIME type.
+// The audio is extracted when the URL is requested for the first time.
+func (transcoder *Transcoder) GetAudioURL(videoURL string, formatName string) (URL, mimeType string) {
+	format, found := GetAudioFormat(formatName)
+	if !found {
+		format = audioFormats["aac"]
+	}
+	return transcoder.createURL(videoURL, format)
+}
+
+// GetVideoURL yields the URL of an MP4 file containing the given HLS or DASH stream and its MIME type.
+// The stream is remuxed when the URL is requested for the first time.
+func (transcoder *Transcoder) GetVideoURL(manifestURL string) (URL, mimeType string) {
+	return transcoder.createURL(manifestURL, remuxFormat)
+}
+
+func (transcoder *Transcoder) createURL(sourceURL string, format Format) (URL, mimeType string) {
+	encodedURL := base64.RawURLEncoding.EncodeToString([]byte(sourceURL))
+	signature := transcoder.sign(sourceURL, format)
+	baseURL := strings.TrimSuffix(transcoder.config.BaseURL, "/")
+	URL = fmt.Sprintf("%v%v%v/%v%v", baseURL, PathPrefix, signature, encodedURL, format.Extension)
+	mimeType = format.MimeType
+	return
+}
+
+// OwnsURL determines if the given URL points to a file served by the transcoder.
+func (transcoder *Transcoder) OwnsURL(URL string) bool {
+	return strings.HasPrefix(URL, strings.TrimSuffix(transcoder.config.BaseURL, "/")+PathPrefix)
+}
+
+// ServeHTTP serves the file for a URL created by GetAudioURL or GetVideoURL. Range requests are supported.
+// If the file is not created within the wait timeout, the client is asked to retry later while ffmpeg keeps running.
+func (transcoder *Transcoder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	videoURL, format, err := transcoder.parseRequestPath(r.URL.Path)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "The given media URL is not valid.")
+		log.Printf("Received a request for an invalid media URL: %v", err)
+		return
+	}
+
+	filename, err := transcoder.getOrCreate(r.Context(), videoURL, format)
+	if err == errStillRunning {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, "The media file is being created, please retry later.")
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprint(w, "The media file could not be created.")
+		log.Printf("Could not serve the media file for %v: %v", videoURL, err)
+		return
+	}
+
+	file, err := os.Open(filepath.Join(transcoder.config.CacheDirectory, filename))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("Could not open transcoded file %v: %v", filename, err)
+		return
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("Could not read transcoded file %v: %v", filename, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.MimeType)
+	http.ServeContent(w, r, filename, stat.ModTime(), file)
+}
+
+func (transcoder *Transcoder) parseRequestPath(path string) (videoURL string, format Format, err error) {
+	segments := strings.Split(strings.TrimPrefix(path, PathPrefix), "/")
+	if len(segments) != 2 {
+		err = errors.New("unexpected number of path segments")
+		return
+	}
+	signature := segments[0]
+	extension := filepath.Ext(segments[1])
+	format, found := getFormatByExtension(extension)
+	if !found {
+		err = fmt.Errorf("unknown file extension %v", extension)
+		return
+	}
+	decodedURL, err := base64.RawURLEncoding.DecodeString(strings.TrimSuffix(segments[1], extension))
+	if err != nil {
+		return
+	}
+	videoURL = string(decodedURL)
+	if !hmac.Equal([]byte(signature), []byte(transcoder.sign(videoURL, format))) {
+		err = errors.New("invalid signature")
+	}
+	return
+}
+
+func getFormatByExtension(extension string) (format Format, found bool) {
+	if remuxFormat.Extension == extension {
+		return remuxFormat, true
+	}
+	for _, candidate := range audioFormats {
+		if candidate.Extension == extension {
+			return candidate, true
+		}
+	}
+	return
+}
+
+func (transcoder *Transcoder) sign(videoURL string, format Format) string {
+	mac := hmac.New(sha256.New, transcoder.config.Secret)
+	mac.Write([]byte(format.Name + "|" + videoURL))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// getOrCreate yields the name of the cache file for the given video and format. If there is no such file yet,
+// ffmpeg will be run in the background. Concurrent requests for the same file share the running extraction. The
+// request waits at most for the wait timeout and yields errStillRunning if the extraction has not finished by then.
+func (transcoder *Transcoder) getOrCreate(ctx context.Context, videoURL string, format Format) (filename string, err error) {
+	hash := sha256.Sum256([]byte(videoURL))
+	filename = hex.EncodeToString(hash[:]) + format.Extension
+
+	transcoder.mutex.Lock()
+	if element, found := transcoder.entries[filename]; found {
+		transcoder.lru.MoveToFront(element)
+		transcoder.mutex.Unlock()
+		return
+	}
+	job, running := transcoder.inFlight[filename]
+	if !running {
+		job = &transcodeJob{done: make(chan struct{})}
+		transcoder.inFlight[filename] = job
+		go transcoder.transcode(job, videoURL, format, filename)
+	}
+	transcoder.mutex.Unlock()
+
+	timer := time.NewTimer(transcoder.waitTimeout)
+	defer timer.Stop()
+	select {
+	case <-job.done:
+		err = job.err
+	case <-timer.C:
+		err = errStillRunning
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
+
+// transcode runs ffmpeg for the given job and adds the result to the cache. The extraction is not aborted if the
+// requesting client goes away, so later requests can use the result.
+func (transcoder *Transcoder) transcode(job *transcodeJob, videoURL string, format Format, filename string) {
+	defer func() {
+		transcoder.mutex.Lock()
+		delete(transcoder.inFlight, filename)
+		transcoder.mutex.Unlock()
+		close(job.done)
+	}()
+
+	transcodeContext, cancel := context.WithTimeout(context.Background(), transcodeTimeout)
+	defer cancel()
+	targetPath := filepath.Join(transcoder.config.CacheDirectory, filename)
+	temporaryPath := targetPath + temporaryFileSuffix
+	job.err = transcoder.fnTranscode(transcodeContext, videoURL, format, temporaryPath)
+	if job.err != nil {
+		os.Remove(temporaryPath)
+		log.Printf("There was an error while transcoding %v: %v", videoURL, job.err)
+		return
+	}
+	job.err = os.Rename(temporaryPath, targetPath)
+	if job.err != nil {
+		return
+	}
+	stat, err := os.Stat(targetPath)
+	if err != nil {
+		job.err = err
+		return
+	}
+
+	transcoder.mutex.Lock()
+	transcoder.addEntry(filename, stat.Size())
+	transcoder.evict()
+	transcoder.mutex.Unlock()
+}
+
+func (transcoder *Transcoder) runFfmpeg(ctx context.Context, sourceURL string, format Format, targetPath string) error {
+	args := []string{"-nostdin", "-loglevel", "error", "-y", "-i", sourceURL}
+	args = append(args, format.codecArgs...)
+	args = append(args, targetPath)
+	cmd := exec.CommandContext(ctx, transcoder.config.FfmpegPath, args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg failed: %v %v", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// loadCache registers the files of the cache directory in the order of their last modification. Only files named
+// like the files of the transcoder are registered, so unrelated files in the directory are never removed.
+func (transcoder *Transcoder) loadCache() error {
+	files, err := ioutil.ReadDir(transcoder.config.CacheDirectory)
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+
+	transcoder.mutex.Lock()
+	defer transcoder.mutex.Unlock()
+	for _, file := range files {
+		if file.IsDir() || !cacheFileRegex.MatchString(file.Name()) {
+			continue
+		}
+		if strings.HasSuffix(file.Name(), temporaryFileSuffix) {
+			os.Remove(filepath.Join(transcoder.config.CacheDirectory, file.Name()))
+			continue
+		}
+		transcoder.addEntry(file.Name(), file.Size())
+	}
+	transcoder.evict()
+	return nil
+}
+
+func (transcoder *Transcoder) addEntry(filename string, size int64) {
+	element := transcoder.lru.PushFront(cacheEntry{
+		filename: filename,
+		size:     size,
+	})
+	transcoder.entries[filename] = element
+	transcoder.cacheBytes += size
+}
+
+// evict removes the least recently used files until the cache fits its maximum size.
+// The most recently used file is always kept.
+func (transcoder *Transcoder) evict() {
+	for transcoder.cacheBytes > transcoder.config.MaxCacheBytes && transcoder.lru.Len() > 1 {
+		element := transcoder.lru.Back()
+		entry := element.Value.(cacheEntry)
+		transcoder.lru.Remove(element)
+		delete(transcoder.entries, entry.filename)
+		transcoder.cacheBytes -= entry.size
+		err := os.Remove(filepath.Join(transcoder.config.CacheDirectory, entry.filename))
+		if err != nil {
+			log.Printf("Could not remove cached file %v: %v", entry.filename, err)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package transcoder
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const videoURL = "https://foo.bar/video.mp4"
+
+func TestGetAudioURL(t *testing.T) {
+	transcoder := createTranscoder(t, 1024)
+	URL, mimeType := transcoder.GetAudioURL(videoURL, "mp3")
+	assertEquals(t, "audio/mpeg", mimeType)
+	if !strings.HasPrefix(URL, "https://mediathek2rss.example"+PathPrefix) || !strings.HasSuffix(URL, ".mp3") {
+		t.Fatalf("The URL %v does not have the expected form.", URL)
+	}
+
+	actualVideoURL, actualFormat, err := transcoder.parseRequestPath(strings.TrimPrefix(URL, "https://mediathek2rss.example"))
+	if err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	assertEquals(t, videoURL, actualVideoURL)
+	assertEquals(t, "mp3", actualFormat.Name)
+}
+
+func TestGetAudioURLWithUnknownFormat(t *testing.T) {
+	transcoder := createTranscoder(t, 1024)
+	URL, mimeType := transcoder.GetAudioURL(videoURL, "wav")
+	assertEquals(t, "audio/mp4", mimeType)
+	if !strings.HasSuffix(URL, ".m4a") {
+		t.Fatalf("The URL %v does not have the expected form.", URL)
+	}
+}
+
+func TestGetVideoURL(t *testing.T) {
+	const manifestURL = "https://foo.bar/master.m3u8"
+	transcoder := createTranscoder(t, 1024)
+	URL, mimeType := transcoder.GetVideoURL(manifestURL)
+	assertEquals(t, "video/mp4", mimeType)
+	assertEquals(t, true, transcoder.OwnsURL(URL))
+	if !strings.HasSuffix(URL, ".mp4") {
+		t.Fatalf("The URL %v does not have the expected form.", URL)
+	}
+
+	actualManifestURL, actualFormat, err := transcoder.parseRequestPath(strings.TrimPrefix(URL, "https://mediathek2rss.example"))
+	if err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	assertEquals(t, manifestURL, actualManifestURL)
+	assertEquals(t, "mp4", actualFormat.Name)
+}
+
+func TestParseRequestPathWithInvalidSignature(t *testing.T) {
+	transcoder := createTranscoder(t, 1024)
+	URL, _ := transcoder.GetAudioURL(videoURL, "aac")
+	path := strings.TrimPrefix(URL, "https://mediathek2rss.example")
+	path = strings.Replace(path, PathPrefix, PathPrefix+"0", 1)
+	_, _, err := transcoder.parseRequestPath(path)
+	if err == nil {
+		t.Fatal("There should be an error.")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	transcoder := createTranscoder(t, 1024)
+	calls := 0
+	transcoder.fnTranscode = func(ctx context.Context, sourceURL string, format Format, targetPath string) error {
+		calls++
+		assertEquals(t, videoURL, sourceURL)
+		return ioutil.WriteFile(targetPath, []byte("0123456789"), 0644)
+	}
+	URL, _ := transcoder.GetAudioURL(videoURL, "aac")
+
+	response := serve(transcoder, URL, "")
+	assertEquals(t, http.StatusOK, response.Code)
+	assertEquals(t, "audio/mp4", response.Header().Get("Content-Type"))
+	assertEquals(t, "10", response.Header().Get("Content-Length"))
+	assertEquals(t, "0123456789", response.Body.String())
+
+	response = serve(transcoder, URL, "bytes=2-4")
+	assertEquals(t, http.StatusPartialContent, response.Code)
+	assertEquals(t, "3", response.Header().Get("Content-Length"))
+	assertEquals(t, "234", response.Body.String())
+	assertEquals(t, 1, calls)
+}
+
+func TestServeHTTPWhileTranscoding(t *testing.T) {
+	transcoder := createTranscoder(t, 1024)
+	transcoder.waitTimeout = 10 * time.Millisecond
+	release := make(chan struct{})
+	transcoder.fnTranscode = func(ctx context.Context, sourceURL string, format Format, targetPath string) error {
+		<-release
+		return ioutil.WriteFile(targetPath, []byte("0123456789"), 0644)
+	}
+	URL, _ := transcoder.GetAudioURL(videoURL, "aac")
+
+	response := serve(transcoder, URL, "")
+	assertEquals(t, http.StatusServiceUnavailable, response.Code)
+	assertEquals(t, "30", response.Header().Get("Retry-After"))
+
+	close(release)
+	transcoder.waitTimeout = time.Minute
+	response = serve(transcoder, URL, "")
+	assertEquals(t, http.StatusOK, response.Code)
+	assertEquals(t, "0123456789", response.Body.String())
+}
+
+func TestServeHTTPWithInvalidURL(t *testing.T) {
+	transcoder := createTranscoder(t, 1024)
+	response := serve(transcoder, "https://mediathek2rss.example"+PathPrefix+"foo/bar.m4a", "")
+	assertEquals(t, http.StatusBadRequest, response.Code)
+}
+
+func TestEviction(t *testing.T) {
+	transcoder := createTranscoder(t, 15)
+	transcoder.fnTranscode = func(ctx context.Context, sourceURL string, format Format, targetPath string) error {
+		return ioutil.WriteFile(targetPath, []byte("0123456789"), 0644)
+	}
+	first, _ := transcoder.getOrCreate(context.Background(), "https://foo.bar/1.mp4", audioFormats["aac"])
+	second, _ := transcoder.getOrCreate(context.Background(), "https://foo.bar/2.mp4", audioFormats["aac"])
+
+	assertEquals(t, 1, transcoder.lru.Len())
+	assertEquals(t, int64(10), transcoder.cacheBytes)
+	if _, err := os.Stat(filepath.Join(transcoder.config.CacheDirectory, first)); !os.IsNotExist(err) {
+		t.Fatal("The least recently used file should have been removed.")
+	}
+	if _, err := os.Stat(filepath.Join(transcoder.config.CacheDirectory, second)); err != nil {
+		t.Fatal("The most recently used file should have been kept.")
+	}
+}
+
+func TestLoadCache(t *testing.T) {
+	directory, err := ioutil.TempDir("", "transcoder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+	cachedFile := strings.Repeat("a", 64) + ".m4a"
+	temporaryFile := strings.Repeat("b", 64) + ".m4a.tmp"
+	ioutil.WriteFile(filepath.Join(directory, cachedFile), []byte("0123456789"), 0644)
+	ioutil.WriteFile(filepath.Join(directory, temporaryFile), []byte("0123456789"), 0644)
+	ioutil.WriteFile(filepath.Join(directory, "holiday.mp4"), []byte("0123456789"), 0644)
+	ioutil.WriteFile(filepath.Join(directory, "notes.txt.tmp"), []byte("0123456789"), 0644)
+
+	transcoder, err := CreateTranscoder(Config{
+		FfmpegPath:     "ffmpeg",
+		CacheDirectory: directory,
+		MaxCacheBytes:  5,
+		BaseURL:        "https://mediathek2rss.example",
+		Secret:         []byte("secret"),
+	})
+	if err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	assertEquals(t, 1, transcoder.lru.Len())
+	assertEquals(t, int64(10), transcoder.cacheBytes)
+	if _, err := os.Stat(filepath.Join(directory, temporaryFile)); !os.IsNotExist(err) {
+		t.Fatal("Temporary files should have been removed.")
+	}
+
+	// files that the transcoder has not created are kept even if the cache is full
+	transcoder.fnTranscode = func(ctx context.Context, sourceURL string, format Format, targetPath string) error {
+		return ioutil.WriteFile(targetPath, []byte("0123456789"), 0644)
+	}
+	if _, err = transcoder.getOrCreate(context.Background(), videoURL, audioFormats["aac"]); err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	if _, err := os.Stat(filepath.Join(directory, cachedFile)); !os.IsNotExist(err) {
+		t.Fatal("The least recently used file should have been removed.")
+	}
+	for _, filename := range []string{"holiday.mp4", "notes.txt.tmp"} {
+		if _, err := os.Stat(filepath.Join(directory, filename)); err != nil {
+			t.Fatalf("The unrelated file %v should have been kept.", filename)
+		}
+	}
+}
+
+func TestCreateTranscoderWithoutConfiguration(t *testing.T) {
+	_, err := CreateTranscoder(Config{})
+	if err == nil {
+		t.Fatal("There should be an error.")
+	}
+}
+
+func createTranscoder(t *testing.T, maxCacheBytes int64) *Transcoder {
+	directory, err := ioutil.TempDir("", "transcoder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(directory)
+	})
+	transcoder, err := CreateTranscoder(Config{
+		FfmpegPath:     "ffmpeg",
+		CacheDirectory: directory,
+		MaxCacheBytes:  maxCacheBytes,
+		BaseURL:        "https://mediathek2rss.example/",
+		Secret:         []byte("secret"),
+	})
+	if err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	return transcoder
+}
+
+func serve(transcoder *Transcoder, URL, rangeHeader string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest("GET", URL, nil)
+	if rangeHeader != "" {
+		request.Header.Set("Range", rangeHeader)
+	}
+	response := httptest.NewRecorder()
+	transcoder.ServeHTTP(response, request)
+	return response
+}
+
+func assertEquals(t *testing.T, expected, actual interface{}) {
+	if actual != expected {
+		t.Fatalf("Expected %v but got %v.", expected, actual)
+	}
+}
@@ -2,20 +2,61 @@ package zdfapi
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
 	"time"
 )
 
-const bearerTokenSourceURL = "https://www.zdf.de/nachrichten/heute-journal"
-const zdfAPIBase = "https://api.zdf.de"
-const showAPIPrefix = zdfAPIBase + "/content/documents/zdf/"
 const playerID = "ngplayer_2_4"
+const searchPageSize = 25
+const searchDateFormat = "2006-01-02T15:04:05.000-0700"
+const defaultVariant = "default"
+
+// Platform describes a Mediathek that runs on the infrastructure of the ZDF, e.g. the one of 3sat.
+type Platform struct {
+	Name           string // the short name of the platform, e.g. 3sat
+	Author         string // the television channel that publishes the shows, e.g. 3sat
+	APIBase        string // the base URL of the API, e.g. https://api.3sat.de
+	DocumentPrefix string // the path of the documents of the shows below the API base, e.g. /content/documents/zdf/
+	TokenSourceURL string // the URL of a web page of the platform that contains the API token
+}
+
+// Platforms on the infrastructure of the ZDF
+var (
+	PlatformZDF = Platform{
+		Name:           "zdf",
+		Author:         "ZDF",
+		APIBase:        "https://api.zdf.de",
+		DocumentPrefix: "/content/documents/zdf/",
+		TokenSourceURL: "https://www.zdf.de/nachrichten/heute-journal",
+	}
+	Platform3sat = Platform{
+		Name:           "3sat",
+		Author:         "3sat",
+		APIBase:        "https://api.3sat.de",
+		DocumentPrefix: "/content/documents/3sat/",
+		TokenSourceURL: "https://www.3sat.de",
+	}
+	PlatformPhoenix = Platform{
+		Name:           "phoenix",
+		Author:         "phoenix",
+		APIBase:        "https://api.zdf.de",
+		DocumentPrefix: "/content/documents/phoenix/",
+		TokenSourceURL: "https://www.phoenix.de",
+	}
+	PlatformKiKA = Platform{
+		Name:           "kika",
+		Author:         "KiKA",
+		APIBase:        "https://api.zdf.de",
+		DocumentPrefix: "/content/documents/kika/",
+		TokenSourceURL: "https://www.kika.de",
+	}
+)
 
 // Show holds all information about a show except the corresponding videos.
 type Show struct {
@@ -49,16 +90,65 @@ type VideoDescription struct {
 	Image       ZDFTeaserImage `json:"teaserImageRef"`
 	URL         string         `json:"http://zdf.de/rels/sharing-url"`
 	Streams     struct {
-		Streams struct {
-			Duration    int    `json:"duration"`
-			URLTemplate string `json:"http://zdf.de/rels/streams/ptmd-template"`
-		} `json:"http://zdf.de/rels/target"`
+		Streams VideoContent `json:"http://zdf.de/rels/target"`
 	} `json:"mainVideoContent"`
+	ContentType    string          `json:"contentType"`
+	TVService      string          `json:"tvService"`
+	ProgrammeItems []ProgrammeItem `json:"programmeItem"`
+}
+
+// ProgrammeItem describes a broadcast of a video in the programme including its season and episode number and its
+// FSK age rating (e.g. 6). Zero numbers mean that the video is not part of a season or not numbered.
+type ProgrammeItem struct {
+	Target struct {
+		FSK           string `json:"fsk"`
+		EpisodeNumber int    `json:"episodeNumber"`
+		Season        struct {
+			SeasonNumber int `json:"seasonNumber"`
+		} `json:"http://zdf.de/rels/cmdm/season"`
+	} `json:"http://zdf.de/rels/target"`
+}
+
+// VideoContent describes the main content of a video including its variants, e.g. a version with sign language.
+// Zero times of the visibility leave the corresponding end of the availability open. The geo location
+// (e.g. none, de or dach) and the FSK age rating (e.g. none or fsk16) describe restrictions of the video.
+type VideoContent struct {
+	Duration    int                              `json:"duration"`
+	URLTemplate string                           `json:"http://zdf.de/rels/streams/ptmd-template"`
+	Variants    map[string](VideoContentVariant) `json:"streams"`
+	VisibleFrom time.Time                        `json:"visibleFrom"`
+	VisibleTo   time.Time                        `json:"visibleTo"`
+	GeoLocation string                           `json:"geoLocation"`
+	FSK         string                           `json:"fsk"`
+}
+
+// VideoContentVariant describes a variant of the main content of a video.
+type VideoContentVariant struct {
+	Label       string `json:"label"`
+	URLTemplate string `json:"http://zdf.de/rels/streams/ptmd-template"`
 }
 
-// VideoStreams holds all available streams for a video.
+// VideoStreams holds all available streams for a video together with the restrictions of the video.
 type VideoStreams struct {
-	Streams []VideoStream `json:"priorityList"`
+	Attributes struct {
+		FSK         StreamAttribute `json:"fsk"`
+		GeoLocation StreamAttribute `json:"geoLocation"`
+	} `json:"attributes"`
+	Streams  []VideoStream `json:"priorityList"`
+	Captions []Caption     `json:"captions"`
+}
+
+// StreamAttribute is an attribute of the streams of a video, e.g. the FSK age rating fsk16 or the geo location dach.
+type StreamAttribute struct {
+	Value string `json:"value"`
+}
+
+// Caption represents a subtitle file of a video, e.g. in the format webvtt.
+type Caption struct {
+	Class    string `json:"class"` // e.g. hoh for subtitles for the hard of hearing
+	Format   string `json:"format"`
+	Language string `json:"language"`
+	URL      string `json:"uri"`
 }
 
 // VideoStream represents one video stream with various formats.
@@ -93,23 +183,39 @@ type ZDFTeaserImage struct {
 //
 // Users should always create this via CreateZDFApi to correctly initialize the API.
 type ZDFApi struct {
+	platform    Platform
 	maxEpisodes int
 	bearerToken string
 	fnGet       func(*ZDFApi, string, bool) ([]byte, error)
 }
 
-// CreateZDFApi creates and initializes the API.
+// CreateZDFApi creates and initializes the API of the ZDF.
 //
 // If the initialization fails, an error will be returned.
 func CreateZDFApi(maxEpisodes int) (api ZDFApi, err error) {
-	return CreateZDFApiWithFnGet(maxEpisodes, doHTTPGetRequest)
+	return CreateZDFApiForPlatform(PlatformZDF, maxEpisodes)
 }
 
-// CreateZDFApiWithFnGet creates and initializes the API with a given HTTP GET function.
+// CreateZDFApiForPlatform creates and initializes the API of a platform on the infrastructure of the ZDF.
+//
+// If the initialization fails, an error will be returned.
+func CreateZDFApiForPlatform(platform Platform, maxEpisodes int) (api ZDFApi, err error) {
+	return CreateZDFApiForPlatformWithFnGet(platform, maxEpisodes, doHTTPGetRequest)
+}
+
+// CreateZDFApiWithFnGet creates and initializes the API of the ZDF with a given HTTP GET function.
 //
 // If the initialization fails, an error will be returned.
 func CreateZDFApiWithFnGet(maxEpisodes int, fnGet func(*ZDFApi, string, bool) ([]byte, error)) (api ZDFApi, err error) {
+	return CreateZDFApiForPlatformWithFnGet(PlatformZDF, maxEpisodes, fnGet)
+}
+
+// CreateZDFApiForPlatformWithFnGet creates and initializes the API of a platform with a given HTTP GET function.
+//
+// If the initialization fails, an error will be returned.
+func CreateZDFApiForPlatformWithFnGet(platform Platform, maxEpisodes int, fnGet func(*ZDFApi, string, bool) ([]byte, error)) (api ZDFApi, err error) {
 	api = ZDFApi{
+		platform:    platform,
 		maxEpisodes: maxEpisodes,
 		bearerToken: "",
 		fnGet:       fnGet,
@@ -120,7 +226,7 @@ func CreateZDFApiWithFnGet(maxEpisodes int, fnGet func(*ZDFApi, string, bool) ([
 
 // GetShow loads a show for a given showPath.
 func (api *ZDFApi) GetShow(showPath string) (show Show, err error) {
-	requestURL := showAPIPrefix + showPath
+	requestURL := api.platform.APIBase + api.platform.DocumentPrefix + showPath
 	result, err := api.Get(requestURL, false)
 	if err != nil {
 		return
@@ -131,18 +237,54 @@ func (api *ZDFApi) GetShow(showPath string) (show Show, err error) {
 
 // GetShowVideos loads the videos available for a given show up to a number of maxEpisodes.
 func (api *ZDFApi) GetShowVideos(show Show) (searchResult ShowSearchResult, err error) {
-	searchURL := show.getSearchURL(api.maxEpisodes)
-	result, err := api.Get(searchURL, false)
-	if err != nil {
-		return
+	return api.GetShowVideosInRange(show, time.Time{}, time.Time{})
+}
+
+// GetShowVideosInRange loads the videos of a given show up to a number of maxEpisodes whose editorial date
+// lies between from and to. A zero from or to leaves the corresponding end of the range open.
+//
+// The search results are requested page by page until maxEpisodes or the total number of results is reached.
+func (api *ZDFApi) GetShowVideosInRange(show Show, from, to time.Time) (searchResult ShowSearchResult, err error) {
+	pageSize := api.maxEpisodes
+	if pageSize > searchPageSize {
+		pageSize = searchPageSize
+	}
+
+	for page := 1; len(searchResult.Results) < api.maxEpisodes; page++ {
+		searchURL := show.getSearchURL(api.platform.APIBase, pageSize, page, from, to)
+		var result []byte
+		result, err = api.Get(searchURL, false)
+		if err != nil {
+			return
+		}
+		var pageResult ShowSearchResult
+		err = json.Unmarshal(result, &pageResult)
+		if err != nil {
+			return
+		}
+
+		searchResult.ResultCount = pageResult.ResultCount
+		searchResult.Results = append(searchResult.Results, pageResult.Results...)
+		if len(pageResult.Results) < pageSize || len(searchResult.Results) >= pageResult.ResultCount {
+			break
+		}
+	}
+
+	if len(searchResult.Results) > api.maxEpisodes {
+		searchResult.Results = searchResult.Results[:api.maxEpisodes]
 	}
-	err = json.Unmarshal(result, &searchResult)
 	return
 }
 
 // GetStreams loads the information about available video streams for a given video description.
 func (api *ZDFApi) GetStreams(description VideoDescription) (stream VideoStreams, err error) {
-	streamsURL := description.getStreamsURL()
+	return api.GetStreamsOfVariant(description, defaultVariant)
+}
+
+// GetStreamsOfVariant loads the information about available video streams of a variant of a given video description,
+// e.g. "dgs" for the version with sign language. If the variant does not exist, the streams of the main content are loaded.
+func (api *ZDFApi) GetStreamsOfVariant(description VideoDescription, variant string) (stream VideoStreams, err error) {
+	streamsURL := description.getStreamsURL(api.platform.APIBase, variant)
 	result, err := api.Get(streamsURL, false)
 	if err != nil {
 		return
@@ -173,13 +315,13 @@ func (api *ZDFApi) Get(URL string, onlyPeek bool) ([]byte, error) {
 
 func (api *ZDFApi) initBearerToken() error {
 	regex := regexp.MustCompile("[\"']?apiToken[\"']?:\\s*[\"']([a-z0-9]+)[\"']")
-	mainPageContent, err := api.fnGet(api, bearerTokenSourceURL, false)
+	mainPageContent, err := api.fnGet(api, api.platform.TokenSourceURL, false)
 	if err != nil {
 		return err
 	}
 	matches := regex.FindSubmatch(mainPageContent)
 	if len(matches) != 2 {
-		err = errors.New("Could not find bearer token on ZDF main page")
+		err = fmt.Errorf("Could not find bearer token on %v page", api.platform.Name)
 		return err
 	}
 	token := string(matches[1])
@@ -226,13 +368,46 @@ func doHTTPGetRequest(api *ZDFApi, URL string, onlyPeek bool) (result []byte, er
 	return
 }
 
-func (show *Show) getSearchURL(maxEpisodes int) string {
-	limitParameter := fmt.Sprintf("limit=%v", maxEpisodes)
+func (show *Show) getSearchURL(apiBase string, limit, page int, from, to time.Time) string {
+	limitParameter := fmt.Sprintf("limit=%v", limit)
 	searchPath := strings.Replace(show.Search.SearchURLTemplate, "limit=0", limitParameter, -1)
-	return fmt.Sprintf("%v%v", zdfAPIBase, searchPath)
+	if page > 1 {
+		searchPath += fmt.Sprintf("&page=%v", page)
+	}
+	if !from.IsZero() {
+		searchPath += "&from=" + url.QueryEscape(from.Format(searchDateFormat))
+	}
+	if !to.IsZero() {
+		searchPath += "&to=" + url.QueryEscape(to.Format(searchDateFormat))
+	}
+	return fmt.Sprintf("%v%v", apiBase, searchPath)
 }
 
-func (description *VideoDescription) getStreamsURL() string {
-	streamsPath := strings.Replace(description.Streams.Streams.URLTemplate, "{playerId}", playerID, -1)
-	return fmt.Sprintf("%v%v", zdfAPIBase, streamsPath)
+// GetSeasonAndEpisode yields the season and episode number of the first programme item of a video.
+// Zero values mean that the numbers are unknown.
+func (description *VideoDescription) GetSeasonAndEpisode() (season, episode int) {
+	if len(description.ProgrammeItems) == 0 {
+		return
+	}
+	target := description.ProgrammeItems[0].Target
+	return target.Season.SeasonNumber, target.EpisodeNumber
+}
+
+// GetAgeRating yields the FSK age rating of a video, e.g. fsk16 or 6. The rating of the main content is preferred,
+// but it is often none, so the rating of the first programme item is used in this case.
+func (description *VideoDescription) GetAgeRating() string {
+	ageRating := description.Streams.Streams.FSK
+	if (ageRating == "" || strings.EqualFold(ageRating, "none")) && len(description.ProgrammeItems) > 0 {
+		ageRating = description.ProgrammeItems[0].Target.FSK
+	}
+	return ageRating
+}
+
+func (description *VideoDescription) getStreamsURL(apiBase, variant string) string {
+	urlTemplate := description.Streams.Streams.URLTemplate
+	if contentVariant, found := description.Streams.Streams.Variants[variant]; found && contentVariant.URLTemplate != "" {
+		urlTemplate = contentVariant.URLTemplate
+	}
+	streamsPath := strings.Replace(urlTemplate, "{playerId}", playerID, -1)
+	return fmt.Sprintf("%v%v", apiBase, streamsPath)
 }
@@ -2,7 +2,9 @@ package zdfapi
 
 import (
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"strings"
 	"testing"
 	"time"
 )
@@ -11,12 +13,45 @@ const maxEpisodes = 2
 
 func TestCreateZDFApi(t *testing.T) {
 	api := createAPI(t, map[string](string){
-		bearerTokenSourceURL: "../testdata/zdf-heute-journal.html"})
+		PlatformZDF.TokenSourceURL: "../testdata/zdf-heute-journal.html"})
 	if api.bearerToken != "playertoken" {
 		t.Fatalf("Expected the api token to be %v but got %v.", "playertoken", api.bearerToken)
 	}
 }
 
+func TestCreateZDFApiForPlatform(t *testing.T) {
+	fnGet := createFnGet(t, map[string](string){
+		"https://www.3sat.de": "../testdata/zdf-heute-journal.html",
+		"https://api.3sat.de/content/documents/3sat/wissen/nano": "../testdata/zdf-magazin-royale.json",
+	})
+	api, err := CreateZDFApiForPlatformWithFnGet(Platform3sat, maxEpisodes, fnGet)
+	if err != nil {
+		t.Fatalf("There should be no error.\n%v", err)
+	}
+	assertEquals(t, "playertoken", api.bearerToken)
+	if _, err = api.GetShow("wissen/nano"); err != nil {
+		t.Fatalf("There should be no error.\n%v", err)
+	}
+}
+
+func TestCreateZDFApiForPlatformsOnTheZDFAPI(t *testing.T) {
+	fnGet := createFnGet(t, map[string](string){
+		"https://www.phoenix.de": "../testdata/zdf-heute-journal.html",
+		"https://www.kika.de":    "../testdata/zdf-heute-journal.html",
+		"https://api.zdf.de/content/documents/phoenix/sendungen/phoenix-runde": "../testdata/zdf-magazin-royale.json",
+		"https://api.zdf.de/content/documents/kika/kikaninchen":                "../testdata/zdf-magazin-royale.json",
+	})
+	for platform, showPath := range map[Platform](string){PlatformPhoenix: "sendungen/phoenix-runde", PlatformKiKA: "kikaninchen"} {
+		api, err := CreateZDFApiForPlatformWithFnGet(platform, maxEpisodes, fnGet)
+		if err != nil {
+			t.Fatalf("There should be no error for %v.\n%v", platform.Name, err)
+		}
+		if _, err = api.GetShow(showPath); err != nil {
+			t.Fatalf("There should be no error for %v.\n%v", platform.Name, err)
+		}
+	}
+}
+
 func TestGetShow(t *testing.T) {
 	showParam := "comedy/zdf-magazin-royale"
 	api := createAPISimple(t, map[string](string){
@@ -76,32 +111,43 @@ func TestGetShowVideos(t *testing.T) {
 	assertEquals(t, "https://www.zdf.de/comedy/zdf-magazin-royale/zdf-magazin-royale-106.html", actual.Results[0].Video.URL)
 	assertEquals(t, 1888, actual.Results[0].Video.Streams.Streams.Duration)
 	assertEquals(t, "/tmd/2/{playerId}/vod/ptmd/mediathek/201218_2330_sendung_zmr", actual.Results[0].Video.Streams.Streams.URLTemplate)
+	assertEquals(t, "Normal", actual.Results[0].Video.Streams.Streams.Variants["default"].Label)
+	assertEqualsTime(t, time.Date(2020, 12, 18, 19, 0, 0, 0, time.UTC), actual.Results[0].Video.Streams.Streams.VisibleFrom)
+	assertEqualsTime(t, time.Date(2021, 3, 18, 22, 59, 0, 0, time.UTC), actual.Results[0].Video.Streams.Streams.VisibleTo)
+	assertEquals(t, "episode", actual.Results[0].Video.ContentType)
+	season, episode := actual.Results[1].Video.GetSeasonAndEpisode()
+	assertEquals(t, 2020, season)
+	assertEquals(t, 2, episode)
+	assertEquals(t, "6", actual.Results[0].Video.GetAgeRating())
+}
+
+func TestGetSeasonAndEpisodeWithoutProgrammeItem(t *testing.T) {
+	description := VideoDescription{}
+	season, episode := description.GetSeasonAndEpisode()
+	assertEquals(t, 0, season)
+	assertEquals(t, 0, episode)
+}
+
+func TestGetAgeRating(t *testing.T) {
+	description := VideoDescription{}
+	assertEquals(t, "", description.GetAgeRating())
+	description.Streams.Streams.FSK = "fsk12"
+	assertEquals(t, "fsk12", description.GetAgeRating())
 }
 
 func TestGetStream(t *testing.T) {
 	api := createAPISimple(t, map[string](string){
 		"https://api.zdf.de/tmd/2/" + playerID + "/vod/ptmd/mediathek/201218_2330_sendung_zmr": "../testdata/zdf-magazin-royale-stream.json",
 	})
-	description := VideoDescription{
-		Streams: struct {
-			Streams struct {
-				Duration    int    "json:\"duration\""
-				URLTemplate string "json:\"http://zdf.de/rels/streams/ptmd-template\""
-			} "json:\"http://zdf.de/rels/target\""
-		}{
-			Streams: struct {
-				Duration    int    "json:\"duration\""
-				URLTemplate string "json:\"http://zdf.de/rels/streams/ptmd-template\""
-			}{
-				URLTemplate: "/tmd/2/{playerId}/vod/ptmd/mediathek/201218_2330_sendung_zmr",
-			},
-		},
-	}
+	description := VideoDescription{}
+	description.Streams.Streams.URLTemplate = "/tmd/2/{playerId}/vod/ptmd/mediathek/201218_2330_sendung_zmr"
 	stream, err := api.GetStreams(description)
 	if err != nil {
 		t.Fatal("We did not expect an error.")
 	}
 	assertEquals(t, 5, len(stream.Streams))
+	assertEquals(t, "none", stream.Attributes.FSK.Value)
+	assertEquals(t, "none", stream.Attributes.GeoLocation.Value)
 	assertEquals(t, 1, len(stream.Streams[3].Formats))
 	actualFormat := stream.Streams[3].Formats[0]
 	assertEquals(t, 2, len(actualFormat.Qualities))
@@ -128,26 +174,86 @@ func TestGetSearchURL(t *testing.T) {
 			SearchURLTemplate: "/foo/bar.json?foo=bar&limit=0&bar=foo",
 		},
 	}
-	assertEquals(t, "https://api.zdf.de/foo/bar.json?foo=bar&limit=42&bar=foo", show.getSearchURL(42))
+	assertEquals(t, "https://api.zdf.de/foo/bar.json?foo=bar&limit=42&bar=foo", show.getSearchURL(PlatformZDF.APIBase, 42, 1, time.Time{}, time.Time{}))
+	assertEquals(t, "https://api.zdf.de/foo/bar.json?foo=bar&limit=42&bar=foo&page=3", show.getSearchURL(PlatformZDF.APIBase, 42, 3, time.Time{}, time.Time{}))
+
+	from := time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2020, 12, 31, 23, 59, 59, 999000000, time.UTC)
+	assertEquals(t, "https://api.zdf.de/foo/bar.json?foo=bar&limit=42&bar=foo&from=2020-12-01T00%3A00%3A00.000%2B0000&to=2020-12-31T23%3A59%3A59.999%2B0000", show.getSearchURL(PlatformZDF.APIBase, 42, 1, from, to))
+}
+
+func TestGetShowVideosPaginated(t *testing.T) {
+	const resultCount = 60
+	requestedURLs := []string{}
+	fnGet := func(api *ZDFApi, URL string, onlyPeek bool) (result []byte, err error) {
+		requestedURLs = append(requestedURLs, URL)
+		return createSearchResultPage(resultCount, searchPageSize), nil
+	}
+	api := ZDFApi{
+		platform:    PlatformZDF,
+		maxEpisodes: 55,
+		bearerToken: "empty",
+		fnGet:       fnGet,
+	}
+	show := Show{}
+	show.Search.SearchURLTemplate = "/search?limit=0"
+
+	actual, err := api.GetShowVideos(show)
+	if err != nil {
+		t.Fatal("We did not expect an error.")
+	}
+	assertEquals(t, resultCount, actual.ResultCount)
+	assertEquals(t, 55, len(actual.Results))
+	assertEquals(t, 3, len(requestedURLs))
+	assertEquals(t, "https://api.zdf.de/search?limit=25", requestedURLs[0])
+	assertEquals(t, "https://api.zdf.de/search?limit=25&page=2", requestedURLs[1])
+	assertEquals(t, "https://api.zdf.de/search?limit=25&page=3", requestedURLs[2])
+}
+
+func TestGetShowVideosStopsAtResultCount(t *testing.T) {
+	requests := 0
+	fnGet := func(api *ZDFApi, URL string, onlyPeek bool) (result []byte, err error) {
+		requests++
+		if requests == 1 {
+			return createSearchResultPage(30, searchPageSize), nil
+		}
+		return createSearchResultPage(30, 5), nil
+	}
+	api := ZDFApi{
+		platform:    PlatformZDF,
+		maxEpisodes: 50,
+		bearerToken: "empty",
+		fnGet:       fnGet,
+	}
+	show := Show{}
+	show.Search.SearchURLTemplate = "/search?limit=0"
+
+	actual, err := api.GetShowVideos(show)
+	if err != nil {
+		t.Fatal("We did not expect an error.")
+	}
+	assertEquals(t, 30, len(actual.Results))
+	assertEquals(t, 2, requests)
+}
+
+func createSearchResultPage(resultCount, pageSize int) []byte {
+	results := make([]string, pageSize)
+	for i := range results {
+		results[i] = fmt.Sprintf(`{"http://zdf.de/rels/target":{"id":"video-%v"}}`, i)
+	}
+	return []byte(fmt.Sprintf(`{"totalResultsCount":%v,"http://zdf.de/rels/search/results":[%v]}`, resultCount, strings.Join(results, ",")))
 }
 
 func TestGetStreamURL(t *testing.T) {
-	description := &VideoDescription{
-		Streams: struct {
-			Streams struct {
-				Duration    int    "json:\"duration\""
-				URLTemplate string "json:\"http://zdf.de/rels/streams/ptmd-template\""
-			} "json:\"http://zdf.de/rels/target\""
-		}{
-			Streams: struct {
-				Duration    int    "json:\"duration\""
-				URLTemplate string "json:\"http://zdf.de/rels/streams/ptmd-template\""
-			}{
-				URLTemplate: "/foo/{playerId}/bar.json",
-			},
-		},
+	description := &VideoDescription{}
+	description.Streams.Streams.URLTemplate = "/foo/{playerId}/bar.json"
+	description.Streams.Streams.Variants = map[string](VideoContentVariant){
+		"dgs": {Label: "Gebärdensprache", URLTemplate: "/foo/{playerId}/dgs.json"},
 	}
-	assertEquals(t, "https://api.zdf.de/foo/"+playerID+"/bar.json", description.getStreamsURL())
+	assertEquals(t, "https://api.zdf.de/foo/"+playerID+"/bar.json", description.getStreamsURL(PlatformZDF.APIBase, "default"))
+	assertEquals(t, "https://api.zdf.de/foo/"+playerID+"/dgs.json", description.getStreamsURL(PlatformZDF.APIBase, "dgs"))
+	assertEquals(t, "https://api.zdf.de/foo/"+playerID+"/bar.json", description.getStreamsURL(PlatformZDF.APIBase, "ad"))
+	assertEquals(t, "https://api.3sat.de/foo/"+playerID+"/bar.json", description.getStreamsURL(Platform3sat.APIBase, "default"))
 }
 
 func createAPI(t *testing.T, urlToFilename map[string](string)) ZDFApi {
@@ -162,6 +268,7 @@ func createAPI(t *testing.T, urlToFilename map[string](string)) ZDFApi {
 func createAPISimple(t *testing.T, urlToFilename map[string](string)) ZDFApi {
 	fnGet := createFnGet(t, urlToFilename)
 	return ZDFApi{
+		platform:    PlatformZDF,
 		maxEpisodes: maxEpisodes,
 		bearerToken: "empty",
 		fnGet:       fnGet,
@@ -0,0 +1,248 @@
+package zdffeed
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seiferma/docker_mediathek2rss/internal/zdfapi"
+)
+
+// DefaultURLSuffixes is the default ladder of URL suffixes of higher resolution MP4 streams ordered from worst to best.
+// All of them are the 720p streams that the ZDF offers besides the streams listed by its API.
+var DefaultURLSuffixes = []URLSuffix{
+	{"3256k_p15v12.mp4", 1280, 720},
+	{"3296k_p15v13.mp4", 1280, 720},
+	{"3328k_p36v12.mp4", 1280, 720},
+	{"3328k_p36v13.mp4", 1280, 720},
+	{"3328k_p36v14.mp4", 1280, 720},
+	{"3328k_p35v14.mp4", 1280, 720},
+	{"3360k_p36v15.mp4", 1280, 720},
+}
+
+// URLSuffix is a step of the ladder of URL suffixes together with the video dimensions of the streams ending with it.
+type URLSuffix struct {
+	Suffix string // e.g. 3360k_p36v15.mp4
+	Width  int
+	Height int
+}
+
+// VariantLimits restrict the variants that the prober considers. Zero values do not restrict the variants.
+type VariantLimits struct {
+	MinHeight  int // only variants with a larger height are considered
+	MaxHeight  int
+	MaxBitrate int
+}
+
+const maxRememberedVariants = 10000
+
+var streamSuffixRegex = regexp.MustCompile("_([0-9]+k_p[0-9]+v[0-9]+\\.mp4)$")
+var urlSuffixRegex = regexp.MustCompile("^([0-9]+k_p[0-9]+v[0-9]+\\.mp4)@([1-9][0-9]*)x([1-9][0-9]*)$")
+
+// ParseURLSuffixes parses a comma-separated ladder of URL suffixes ordered from worst to best. Each step consists of
+// the suffix and the video dimensions of the streams ending with it, e.g. 3360k_p36v15.mp4@1280x720. Suffixes must
+// have the form of the suffixes of ZDF streams, must not repeat and must not decrease in height.
+func ParseURLSuffixes(value string) (suffixes []URLSuffix, err error) {
+	knownSuffixes := map[string](bool){}
+	for _, step := range strings.Split(value, ",") {
+		step = strings.TrimSpace(step)
+		if step == "" {
+			continue
+		}
+		matches := urlSuffixRegex.FindStringSubmatch(step)
+		if matches == nil {
+			err = fmt.Errorf("the step %v does not consist of a suffix and video dimensions like 3360k_p36v15.mp4@1280x720", step)
+			return
+		}
+		suffix := URLSuffix{Suffix: matches[1]}
+		suffix.Width, _ = strconv.Atoi(matches[2])
+		suffix.Height, _ = strconv.Atoi(matches[3])
+		if knownSuffixes[suffix.Suffix] {
+			err = fmt.Errorf("the suffix %v is listed twice", suffix.Suffix)
+			return
+		}
+		if len(suffixes) > 0 && suffix.Height < suffixes[len(suffixes)-1].Height {
+			err = fmt.Errorf("the suffix %v has a lower height than the previous one, but the ladder must be ordered from worst to best", suffix.Suffix)
+			return
+		}
+		knownSuffixes[suffix.Suffix] = true
+		suffixes = append(suffixes, suffix)
+	}
+	if len(suffixes) == 0 {
+		err = fmt.Errorf("the ladder %v contains no suffixes", value)
+	}
+	return
+}
+
+// VariantProber finds higher resolution variants of ZDF MP4 streams, which the ZDF API does not list.
+//
+// The variants are guessed by replacing the suffix of a stream URL with the suffixes of a configurable ladder.
+// All suffixes are probed concurrently and the best available one wins. The winner is remembered per stream,
+// so refreshing a feed does not probe again, and per show, so the next episode of a show probes the suffix that
+// worked before first and then only the better suffixes.
+//
+// Users should always create this via CreateVariantProber to correctly initialize the prober.
+type VariantProber struct {
+	suffixes      []URLSuffix
+	entryDuration time.Duration
+	fnNow         func() time.Time
+
+	mutex        sync.Mutex
+	winners      map[string](rememberedVariant)
+	showSuffixes map[string](rememberedVariant)
+}
+
+type rememberedVariant struct {
+	ValidTo time.Time
+	Suffix  URLSuffix // empty if there is no better variant
+}
+
+// CreateVariantProber creates a prober for the given ladder of URL suffixes ordered from worst to best.
+// The probing results are remembered for the given cacheDuration.
+func CreateVariantProber(suffixes []URLSuffix, cacheDuration time.Duration) *VariantProber {
+	return CreateVariantProberWithNowFunction(suffixes, cacheDuration, time.Now)
+}
+
+// CreateVariantProberWithNowFunction creates a prober for the given ladder of URL suffixes with a user defined now function.
+func CreateVariantProberWithNowFunction(suffixes []URLSuffix, cacheDuration time.Duration, fnNow func() time.Time) *VariantProber {
+	return &VariantProber{
+		suffixes:      suffixes,
+		entryDuration: cacheDuration,
+		fnNow:         fnNow,
+		winners:       map[string](rememberedVariant){},
+		showSuffixes:  map[string](rememberedVariant){},
+	}
+}
+
+// FindHighestResolutionStream yields the URL and the video dimensions of the best available variant of the given
+// stream URL of a show within the given limits. If there is no better variant, the given URL and zero dimensions are
+// returned.
+func (prober *VariantProber) FindHighestResolutionStream(api *zdfapi.ZDFApi, showPath, URL string, limits VariantLimits) (variantURL string, width, height int) {
+	suffix := streamSuffixRegex.FindString(URL)
+	if suffix == "" {
+		return URL, 0, 0
+	}
+	urlPrefix := strings.TrimSuffix(URL, suffix) + "_"
+	suffixes := prober.getAllowedSuffixes(limits)
+	if len(suffixes) == 0 {
+		return URL, 0, 0
+	}
+	winnerKey := fmt.Sprintf("%v#%v#%v#%v", urlPrefix, limits.MinHeight, limits.MaxHeight, limits.MaxBitrate)
+
+	winner, found := prober.getWinner(winnerKey)
+	if !found {
+		winner = prober.probeStartingWithShowSuffix(api, showPath, urlPrefix, suffixes)
+		prober.remember(winnerKey, showPath, winner)
+	}
+
+	if winner.Suffix == "" {
+		return URL, 0, 0
+	}
+	return urlPrefix + winner.Suffix, winner.Width, winner.Height
+}
+
+func (prober *VariantProber) getAllowedSuffixes(limits VariantLimits) []URLSuffix {
+	allowed := make([]URLSuffix, 0, len(prober.suffixes))
+	for _, suffix := range prober.suffixes {
+		if suffix.Height <= limits.MinHeight || (limits.MaxHeight > 0 && suffix.Height > limits.MaxHeight) {
+			continue
+		}
+		if matches := bitrateRegex.FindStringSubmatch("_" + suffix.Suffix); limits.MaxBitrate > 0 && matches != nil {
+			if bitrate, _ := strconv.Atoi(matches[1]); bitrate > limits.MaxBitrate {
+				continue
+			}
+		}
+		allowed = append(allowed, suffix)
+	}
+	return allowed
+}
+
+// probeStartingWithShowSuffix probes the suffix that won for the last episode of the show first. If it is available,
+// only the better suffixes are probed in addition, because the worse ones cannot win. Otherwise, the remaining
+// suffixes are probed.
+func (prober *VariantProber) probeStartingWithShowSuffix(api *zdfapi.ZDFApi, showPath, urlPrefix string, suffixes []URLSuffix) URLSuffix {
+	prober.mutex.Lock()
+	showSuffix := prober.showSuffixes[showPath].Suffix.Suffix
+	prober.mutex.Unlock()
+	for i, suffix := range suffixes {
+		if suffix.Suffix != showSuffix {
+			continue
+		}
+		if _, err := api.Get(urlPrefix+suffix.Suffix, true); err != nil {
+			remaining := append(append([]URLSuffix{}, suffixes[:i]...), suffixes[i+1:]...)
+			return prober.probeAll(api, urlPrefix, remaining)
+		}
+		if better := prober.probeAll(api, urlPrefix, suffixes[i+1:]); better.Suffix != "" {
+			return better
+		}
+		return suffix
+	}
+	return prober.probeAll(api, urlPrefix, suffixes)
+}
+
+// probeAll probes all suffixes concurrently and returns the best available one.
+func (prober *VariantProber) probeAll(api *zdfapi.ZDFApi, urlPrefix string, suffixes []URLSuffix) URLSuffix {
+	available := make([]bool, len(suffixes))
+	var waitGroup sync.WaitGroup
+	for i, suffix := range suffixes {
+		waitGroup.Add(1)
+		go func(i int, suffix string) {
+			defer waitGroup.Done()
+			_, err := api.Get(urlPrefix+suffix, true)
+			available[i] = err == nil
+		}(i, suffix.Suffix)
+	}
+	waitGroup.Wait()
+
+	for i := len(suffixes) - 1; i >= 0; i-- {
+		if available[i] {
+			return suffixes[i]
+		}
+	}
+	return URLSuffix{}
+}
+
+func (prober *VariantProber) getWinner(key string) (suffix URLSuffix, found bool) {
+	prober.mutex.Lock()
+	defer prober.mutex.Unlock()
+	entry, found := prober.winners[key]
+	if !found {
+		return
+	}
+	if prober.fnNow().After(entry.ValidTo) {
+		delete(prober.winners, key)
+		return URLSuffix{}, false
+	}
+	return entry.Suffix, true
+}
+
+// remember stores the winner of a stream and show. Expired entries of streams and shows are removed if too many
+// streams are remembered.
+func (prober *VariantProber) remember(key, showPath string, suffix URLSuffix) {
+	prober.mutex.Lock()
+	defer prober.mutex.Unlock()
+	now := prober.fnNow()
+	if len(prober.winners) >= maxRememberedVariants {
+		for existingKey, entry := range prober.winners {
+			if now.After(entry.ValidTo) {
+				delete(prober.winners, existingKey)
+			}
+		}
+		for existingShowPath, entry := range prober.showSuffixes {
+			if now.After(entry.ValidTo) {
+				delete(prober.showSuffixes, existingShowPath)
+			}
+		}
+	}
+	entry := rememberedVariant{
+		ValidTo: now.Add(prober.entryDuration),
+		Suffix:  suffix,
+	}
+	prober.winners[key] = entry
+	if suffix.Suffix != "" {
+		prober.showSuffixes[showPath] = entry
+	}
+}
@@ -0,0 +1,190 @@
+package zdffeed
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seiferma/docker_mediathek2rss/internal/zdfapi"
+)
+
+const variantTestPrefix = "https://bla/sendung_zmr_"
+
+type requestRecorder struct {
+	mutex     sync.Mutex
+	requested []string
+}
+
+func (recorder *requestRecorder) createAPI(availableURLs ...string) *zdfapi.ZDFApi {
+	fnGet := func(api *zdfapi.ZDFApi, URL string, onlyPeek bool) (result []byte, err error) {
+		recorder.mutex.Lock()
+		recorder.requested = append(recorder.requested, URL)
+		recorder.mutex.Unlock()
+		for _, availableURL := range availableURLs {
+			if availableURL == URL {
+				return []byte{}, nil
+			}
+		}
+		return []byte{}, errors.New("test error")
+	}
+	api, _ := zdfapi.CreateZDFApiWithFnGet(1, fnGet)
+	// the creation of the API requests the token, which is not of interest
+	recorder.requested = nil
+	return &api
+}
+
+func TestVariantProberUsesConfiguredSuffixes(t *testing.T) {
+	recorder := requestRecorder{}
+	api := recorder.createAPI(variantTestPrefix+"2000k_p1v1.mp4", variantTestPrefix+"3000k_p2v1.mp4")
+	prober := CreateVariantProber(createLadder("2000k_p1v1.mp4", "3000k_p2v1.mp4", "4000k_p3v1.mp4"), time.Hour)
+
+	actual, _, _ := prober.FindHighestResolutionStream(api, "show", variantTestPrefix+"1628k_p13v15.mp4", VariantLimits{})
+	assertEquals(t, variantTestPrefix+"3000k_p2v1.mp4", actual)
+	assertEquals(t, 3, len(recorder.requested))
+}
+
+func TestVariantProberConsidersMaxBitrate(t *testing.T) {
+	recorder := requestRecorder{}
+	api := recorder.createAPI(variantTestPrefix+"2000k_p1v1.mp4", variantTestPrefix+"3000k_p2v1.mp4")
+	prober := CreateVariantProber(createLadder("2000k_p1v1.mp4", "3000k_p2v1.mp4"), time.Hour)
+
+	actual, _, _ := prober.FindHighestResolutionStream(api, "show", variantTestPrefix+"1628k_p13v15.mp4", VariantLimits{MaxBitrate: 2500})
+	assertEquals(t, variantTestPrefix+"2000k_p1v1.mp4", actual)
+	assertEquals(t, 1, len(recorder.requested))
+}
+
+func TestVariantProberRemembersWinner(t *testing.T) {
+	now := time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC)
+	recorder := requestRecorder{}
+	api := recorder.createAPI(variantTestPrefix + "2000k_p1v1.mp4")
+	prober := CreateVariantProberWithNowFunction(createLadder("2000k_p1v1.mp4", "3000k_p2v1.mp4"), time.Hour, func() time.Time { return now })
+	testURL := variantTestPrefix + "1628k_p13v15.mp4"
+
+	prober.FindHighestResolutionStream(api, "show", testURL, VariantLimits{})
+	actual, _, _ := prober.FindHighestResolutionStream(api, "show", testURL, VariantLimits{})
+	assertEquals(t, variantTestPrefix+"2000k_p1v1.mp4", actual)
+	assertEquals(t, 2, len(recorder.requested))
+
+	now = now.Add(2 * time.Hour)
+	prober.FindHighestResolutionStream(api, "show", testURL, VariantLimits{})
+	assertEquals(t, 4, len(recorder.requested))
+}
+
+func TestVariantProberForgetsExpiredShows(t *testing.T) {
+	now := time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC)
+	prober := CreateVariantProberWithNowFunction(createLadder("2000k_p1v1.mp4"), time.Hour, func() time.Time { return now })
+	suffix := URLSuffix{Suffix: "2000k_p1v1.mp4"}
+	for i := 0; i < maxRememberedVariants; i++ {
+		prober.remember(fmt.Sprint("stream", i), fmt.Sprint("show", i), suffix)
+	}
+	assertEquals(t, maxRememberedVariants, len(prober.showSuffixes))
+
+	now = now.Add(2 * time.Hour)
+	prober.remember("stream", "show", suffix)
+	assertEquals(t, 1, len(prober.winners))
+	assertEquals(t, 1, len(prober.showSuffixes))
+}
+
+func TestVariantProberRemembersMissingVariant(t *testing.T) {
+	recorder := requestRecorder{}
+	api := recorder.createAPI()
+	prober := CreateVariantProber(createLadder("2000k_p1v1.mp4"), time.Hour)
+	testURL := variantTestPrefix + "1628k_p13v15.mp4"
+
+	prober.FindHighestResolutionStream(api, "show", testURL, VariantLimits{})
+	actual, _, _ := prober.FindHighestResolutionStream(api, "show", testURL, VariantLimits{})
+	assertEquals(t, testURL, actual)
+	assertEquals(t, 1, len(recorder.requested))
+}
+
+func TestVariantProberTriesSuffixOfShowFirst(t *testing.T) {
+	recorder := requestRecorder{}
+	api := recorder.createAPI(variantTestPrefix+"3000k_p2v1.mp4", "https://bla/other_3000k_p2v1.mp4")
+	prober := CreateVariantProber(createLadder("2000k_p1v1.mp4", "3000k_p2v1.mp4"), time.Hour)
+
+	prober.FindHighestResolutionStream(api, "show", variantTestPrefix+"1628k_p13v15.mp4", VariantLimits{})
+	actual, _, _ := prober.FindHighestResolutionStream(api, "show", "https://bla/other_1628k_p13v15.mp4", VariantLimits{})
+	assertEquals(t, "https://bla/other_3000k_p2v1.mp4", actual)
+	assertEquals(t, 3, len(recorder.requested))
+}
+
+func TestVariantProberConsidersHeight(t *testing.T) {
+	recorder := requestRecorder{}
+	api := recorder.createAPI(variantTestPrefix+"2000k_p1v1.mp4", variantTestPrefix+"6000k_p2v1.mp4")
+	prober := CreateVariantProber([]URLSuffix{{"2000k_p1v1.mp4", 1280, 720}, {"6000k_p2v1.mp4", 1920, 1080}}, time.Hour)
+
+	actual, width, height := prober.FindHighestResolutionStream(api, "show", variantTestPrefix+"1628k_p13v15.mp4", VariantLimits{MinHeight: 576, MaxHeight: 900})
+	assertEquals(t, variantTestPrefix+"2000k_p1v1.mp4", actual)
+	assertEquals(t, 1280, width)
+	assertEquals(t, 720, height)
+	assertEquals(t, 1, len(recorder.requested))
+
+	actual, width, height = prober.FindHighestResolutionStream(api, "show", variantTestPrefix+"1628k_p13v15.mp4", VariantLimits{MinHeight: 576})
+	assertEquals(t, variantTestPrefix+"6000k_p2v1.mp4", actual)
+	assertEquals(t, 1920, width)
+	assertEquals(t, 1080, height)
+
+	recorder.requested = nil
+	actual, width, _ = prober.FindHighestResolutionStream(api, "show", variantTestPrefix+"1628k_p13v15.mp4", VariantLimits{MinHeight: 1080})
+	assertEquals(t, variantTestPrefix+"1628k_p13v15.mp4", actual)
+	assertEquals(t, 0, width)
+	assertEquals(t, 0, len(recorder.requested))
+}
+
+func TestVariantProberOnlyProbesBetterSuffixesThanSuffixOfShow(t *testing.T) {
+	recorder := requestRecorder{}
+	api := recorder.createAPI(variantTestPrefix+"2000k_p1v1.mp4", "https://bla/other_2000k_p1v1.mp4")
+	prober := CreateVariantProber(createLadder("1000k_p1v1.mp4", "2000k_p1v1.mp4", "3000k_p2v1.mp4", "4000k_p3v1.mp4"), time.Hour)
+
+	prober.FindHighestResolutionStream(api, "show", variantTestPrefix+"1628k_p13v15.mp4", VariantLimits{})
+	assertEquals(t, 4, len(recorder.requested))
+	recorder.requested = nil
+	actual, _, _ := prober.FindHighestResolutionStream(api, "show", "https://bla/other_1628k_p13v15.mp4", VariantLimits{})
+	assertEquals(t, "https://bla/other_2000k_p1v1.mp4", actual)
+	assertEquals(t, 3, len(recorder.requested))
+}
+
+func TestVariantProberIgnoresUnknownURLs(t *testing.T) {
+	recorder := requestRecorder{}
+	api := recorder.createAPI()
+	prober := CreateVariantProber(DefaultURLSuffixes, time.Hour)
+
+	actual, _, _ := prober.FindHighestResolutionStream(api, "show", "https://bla/video.mp4", VariantLimits{})
+	assertEquals(t, "https://bla/video.mp4", actual)
+	assertEquals(t, 0, len(recorder.requested))
+}
+
+func TestParseURLSuffixes(t *testing.T) {
+	suffixes, err := ParseURLSuffixes(" 3328k_p36v14.mp4@1280x720, 6628k_p61v17.mp4@1920x1080,")
+	if err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	assertEquals(t, 2, len(suffixes))
+	assertEquals(t, URLSuffix{"6628k_p61v17.mp4", 1920, 1080}, suffixes[1])
+
+	invalidValues := []string{
+		"",
+		"3328k_p36v14.mp4",
+		"3328k_p36v14.mp4@1280",
+		"3328k_p36v14.mp4@0x0",
+		"../../video.mp4@1280x720",
+		"3328k_p36v14.mp4@1280x720,3328k_p36v14.mp4@1280x720",
+		"6628k_p61v17.mp4@1920x1080,3328k_p36v14.mp4@1280x720",
+	}
+	for _, value := range invalidValues {
+		if _, err := ParseURLSuffixes(value); err == nil {
+			t.Fatalf("There should be an error for %v.", value)
+		}
+	}
+}
+
+// createLadder creates a ladder of 720p streams with the given suffixes.
+func createLadder(suffixes ...string) []URLSuffix {
+	ladder := make([]URLSuffix, 0, len(suffixes))
+	for _, suffix := range suffixes {
+		ladder = append(ladder, URLSuffix{suffix, 1280, 720})
+	}
+	return ladder
+}
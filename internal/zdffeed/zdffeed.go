@@ -1,93 +1,239 @@
 package zdffeed
 
 import (
+	"fmt"
+	"path"
 	"regexp"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/seiferma/docker_mediathek2rss/internal"
-	"github.com/seiferma/docker_mediathek2rss/internal/rssfeed"
+	"github.com/seiferma/docker_mediathek2rss/internal/manifest"
+	"github.com/seiferma/docker_mediathek2rss/internal/streamselect"
 	"github.com/seiferma/docker_mediathek2rss/internal/zdfapi"
 )
 
-const wantedMimeType = "video/mp4"
+const defaultLanguage = "deu"
+const mainClass = "main"
 
-// CreateZdfRssFeed creates an RSS feed for a given showPath and request parameters. The ZDFApi has to be passed as well.
-func CreateZdfRssFeed(showPath string, parameters internal.RequestParameters, api *zdfapi.ZDFApi) (result string, err error) {
-	var show zdfapi.Show
-	show, err = api.GetShow(showPath)
+// class of the audio tracks for each value of the variant request parameter
+var trackClassByVariant = map[string](string){
+	internal.VariantMain:             mainClass,
+	internal.VariantAudioDescription: "ad",
+	internal.VariantSignLanguage:     mainClass,
+	internal.VariantOriginalVersion:  "ot",
+}
+
+// key of the content variant in the ZDF API for each value of the variant request parameter that has separate streams
+var contentVariantByVariant = map[string](string){
+	internal.VariantSignLanguage: "dgs",
+}
+
+// MIME type of the subtitles for each caption format of the ZDF API
+var subtitleMimeTypeByFormat = map[string](string){
+	"webvtt":            internal.WebVTTMimeType,
+	"ebu-tt-d-basic-de": internal.TTMLMimeType,
+}
+
+// category of the Apple Podcasts directory for each section of the ZDF Mediathek
+var iTunesCategoryBySection = map[string](string){
+	"comedy":        "Comedy",
+	"nachrichten":   "News",
+	"politik":       "News",
+	"dokumentation": "Society & Culture",
+	"gesellschaft":  "Society & Culture",
+	"wissen":        "Science",
+	"sport":         "Sports",
+	"kinder":        "Kids & Family",
+}
+
+// category of the Apple Podcasts directory for each platform whose shows have a common topic
+var iTunesCategoryByPlatform = map[string](string){
+	zdfapi.PlatformKiKA.Name: "Kids & Family",
+}
+
+// value of itunes:episodeType for each content type of the ZDF API
+var episodeTypeByContentType = map[string](string){
+	"episode": "full",
+	"trailer": "trailer",
+	"clip":    "bonus",
+}
+
+var showPathRegex = regexp.MustCompile("^([a-zA-Z0-9-]+/)*[a-zA-Z0-9-]+$")
+
+// Provider offers the shows of the ZDF Mediathek or of another platform on the infrastructure of the ZDF, e.g. 3sat.
+// The shows are identified by their path in the Mediathek of the platform.
+type Provider struct {
+	platform      zdfapi.Platform
+	fnCreateAPI   func() (zdfapi.ZDFApi, error)
+	variantProber *VariantProber
+}
+
+// CreateProvider creates the provider of the ZDF Mediathek, which lists up to maxEpisodes episodes per show. The
+// prober finds higher resolution variants of the streams and may be nil.
+func CreateProvider(maxEpisodes int, variantProber *VariantProber) *Provider {
+	return CreatePlatformProvider(zdfapi.PlatformZDF, maxEpisodes, variantProber)
+}
+
+// CreatePlatformProvider creates the provider of a platform on the infrastructure of the ZDF, which lists up to
+// maxEpisodes episodes per show. The prober finds higher resolution variants of the streams and may be nil.
+func CreatePlatformProvider(platform zdfapi.Platform, maxEpisodes int, variantProber *VariantProber) *Provider {
+	return &Provider{
+		platform: platform,
+		fnCreateAPI: func() (zdfapi.ZDFApi, error) {
+			return zdfapi.CreateZDFApiForPlatform(platform, maxEpisodes)
+		},
+		variantProber: variantProber,
+	}
+}
+
+// zdfShow holds the API and the path of a show, which are needed to resolve the streams of the episodes.
+type zdfShow struct {
+	api           *zdfapi.ZDFApi
+	path          string
+	searchResults *zdfapi.ShowSearchResult
+}
+
+// ID yields the name of the platform, e.g. zdf or 3sat.
+func (zdfProvider *Provider) ID() string {
+	return zdfProvider.platform.Name
+}
+
+// Routes yields byPath, which serves the shows at /{platform}/show/byPath/{showPath}, e.g. /zdf/show/byPath/{showPath}.
+func (zdfProvider *Provider) Routes() []string {
+	return []string{"byPath"}
+}
+
+// IsValidShowID checks if the path of a show is a relative path like comedy/zdf-magazin-royale.
+func (zdfProvider *Provider) IsValidShowID(route, showPath string) bool {
+	return showPathRegex.MatchString(showPath)
+}
+
+// LookupShow yields the show with the given path. The episodes are already restricted to the requested range of
+// dates when searching them.
+func (zdfProvider *Provider) LookupShow(route, showPath string, parameters internal.RequestParameters) (show internal.Show, err error) {
+	var api zdfapi.ZDFApi
+	api, err = zdfProvider.fnCreateAPI()
+	if err != nil {
+		return
+	}
+	var zdfShowDetails zdfapi.Show
+	zdfShowDetails, err = api.GetShow(showPath)
 	if err != nil {
 		return
 	}
 	var searchResults zdfapi.ShowSearchResult
-	searchResults, err = api.GetShowVideos(show)
+	searchResults, err = api.GetShowVideosInRange(zdfShowDetails, parameters.Since, parameters.Until)
 	if err != nil {
 		return
 	}
 
-	feed := rssfeed.CreateFeed()
-	feed.Channel.Title = show.Title
-	feed.Channel.ITunesSubtitle = feed.Channel.Title
-	feed.Channel.Description = &rssfeed.FeedDescription{
-		Text: show.GetDescription(),
-	}
-	feed.Channel.ITunesSummary = &rssfeed.ITunesSummary{
-		Text: feed.Channel.Description.Text,
+	show = internal.Show{
+		Title:       zdfShowDetails.Title,
+		Description: zdfShowDetails.GetDescription(),
+		Link:        zdfShowDetails.URL,
+		Image:       internal.Image{URL: findBestMatchingImageURL(&zdfShowDetails.Image), Title: zdfShowDetails.Image.Alt},
+		Author:      getAuthor(&searchResults, zdfProvider.platform.Author),
+		Category:    getITunesCategory(zdfProvider.platform.Name, showPath),
+		Details:     &zdfShow{api: &api, path: showPath, searchResults: &searchResults},
 	}
-	feed.Channel.Link = show.URL
-	feed.Channel.Image = &rssfeed.Image{
-		Title: show.Image.Alt,
-		Link:  show.URL,
-		URL:   findBestMatchingImageURL(&show.Image),
-	}
-	feed.Channel.ITunesImage = &rssfeed.ITunesImage{
-		URL: feed.Channel.Image.URL,
-	}
-	now := time.Now()
-	feed.Channel.LastBuildDate = &now
-	feed.Channel.FeedItems = make([]rssfeed.FeedItem, 0)
-
-	for _, result := range searchResults.Results {
+	return
+}
 
-		if result.Video.Streams.Streams.Duration < parameters.MinimumLengthInSeconds {
-			continue
+// ListEpisodes yields the episodes found by the search for the episodes of the show.
+func (zdfProvider *Provider) ListEpisodes(show *internal.Show, parameters internal.RequestParameters, fnVisit func(internal.Episode) bool) error {
+	for _, result := range show.Details.(*zdfShow).searchResults.Results {
+		video := result.Video
+		content := &video.Streams.Streams
+		seasonNumber, episodeNumber := video.GetSeasonAndEpisode()
+		episode := internal.Episode{
+			ID:                video.ID,
+			Title:             video.Title,
+			Description:       video.Description,
+			Link:              video.URL,
+			Image:             internal.Image{URL: findBestMatchingImageURL(&video.Image), Title: video.Image.Alt},
+			Date:              video.Date,
+			DurationInSeconds: content.Duration,
+			AvailableFrom:     content.VisibleFrom,
+			AvailableTo:       content.VisibleTo,
+			Season:            seasonNumber,
+			Number:            episodeNumber,
+			Type:              episodeTypeByContentType[video.ContentType],
+			Restrictions:      internal.CreateRestrictions(content.GeoLocation, video.GetAgeRating()),
+			Details:           &video,
 		}
+		if !fnVisit(episode) {
+			break
+		}
+	}
+	return nil
+}
 
-		var streams zdfapi.VideoStreams
-		streams, _ = api.GetStreams(result.Video)
-		videoURL := findBestMatchingVideoStreamURL(api, &streams)
+// ResolveMedia fetches the streams of the requested variant of an episode, selects the one that matches the request
+// parameters best and adds the subtitles of the variant. The restrictions of the streams replace the ones of the
+// search, which are mostly none.
+func (zdfProvider *Provider) ResolveMedia(show *internal.Show, episode *internal.Episode, parameters internal.RequestParameters, services *internal.MediaServices) (err error) {
+	details := show.Details.(*zdfShow)
+	streams, streamsErr := details.api.GetStreamsOfVariant(*episode.Details.(*zdfapi.VideoDescription), contentVariantByVariant[parameters.Variant])
+	if streamsErr != nil {
+		err = fmt.Errorf("streams not available: %v", streamsErr)
+		return
+	}
+	proberShowPath := path.Join(zdfProvider.platform.Name, details.path)
+	URL, mimeType := findBestMatchingStream(details.api, zdfProvider.variantProber, proberShowPath, &streams, parameters, services)
+	episode.Media = &internal.MediaVariant{URL: URL, MimeType: mimeType}
+	episode.Subtitles = getSubtitles(&streams)
+	episode.Restrictions = getRestrictions(&streams, episode.Restrictions)
+	return
+}
 
-		feed.Channel.FeedItems = append(feed.Channel.FeedItems, rssfeed.FeedItem{})
-		item := &feed.Channel.FeedItems[len(feed.Channel.FeedItems)-1]
+// getRestrictions yields the restrictions given by the attributes of the streams. Restrictions that the attributes
+// do not state are taken from the given restrictions of the search.
+func getRestrictions(streams *zdfapi.VideoStreams, searchRestrictions internal.Restrictions) internal.Restrictions {
+	restrictions := internal.CreateRestrictions(streams.Attributes.GeoLocation.Value, streams.Attributes.FSK.Value)
+	if restrictions.GeoRegion == "" {
+		restrictions.GeoRegion = searchRestrictions.GeoRegion
+	}
+	if restrictions.MinimumAge == 0 {
+		restrictions.MinimumAge = searchRestrictions.MinimumAge
+	}
+	return restrictions
+}
 
-		item.Title = result.Video.Title
-		item.ITunesTitle = item.Title
-		item.Description = &rssfeed.FeedDescription{
-			Text: result.Video.Description,
-		}
-		item.ITunesSummary = &rssfeed.ItunesSummary{
-			Text: item.Description.Text,
+// getSubtitles yields the subtitles of the streams in the formats that podcast clients understand.
+func getSubtitles(streams *zdfapi.VideoStreams) []internal.Subtitle {
+	var subtitles []internal.Subtitle
+	for _, caption := range streams.Captions {
+		if mimeType, found := subtitleMimeTypeByFormat[caption.Format]; found {
+			subtitles = append(subtitles, internal.Subtitle{URL: caption.URL, MimeType: mimeType, Language: caption.Language})
 		}
-		item.GUID = &rssfeed.FeedGUID{
-			Text: result.Video.ID,
-		}
-		item.ITunesDurationString = rssfeed.CreateItunesDurationStringFromSeconds(result.Video.Streams.Streams.Duration)
-		pubDate := make([]time.Time, 1)
-		pubDate[0] = result.Video.Date
-		item.PubDate = &pubDate[0]
-		item.ITunesImage = &rssfeed.ITunesImage{
-			URL: findBestMatchingImageURL(&result.Video.Image),
-		}
-		item.Link = result.Video.URL
-		item.Enclosure = &rssfeed.FeedItemEnclosure{
-			URL:  videoURL,
-			Type: wantedMimeType,
+	}
+	return subtitles
+}
+
+// getAuthor yields the television channel that broadcasts the episodes of a show, e.g. ZDF or ZDFneo. The default
+// author is used if no episode names the television channel.
+func getAuthor(searchResults *zdfapi.ShowSearchResult, defaultAuthor string) string {
+	for _, result := range searchResults.Results {
+		if result.Video.TVService != "" {
+			return result.Video.TVService
 		}
 	}
+	return defaultAuthor
+}
 
-	result, err = feed.SerializeToString()
-	return
+// getITunesCategory yields the category of the Apple Podcasts directory for the section of the Mediathek that
+// contains the show, e.g. comedy for comedy/zdf-magazin-royale. Shows of other sections get the category of the
+// platform.
+func getITunesCategory(platformName, showPath string) string {
+	section := strings.SplitN(showPath, "/", 2)[0]
+	if category, found := iTunesCategoryBySection[section]; found {
+		return category
+	}
+	if category, found := iTunesCategoryByPlatform[platformName]; found {
+		return category
+	}
+	return internal.DefaultITunesCategory
 }
 
 func findBestMatchingImageURL(images *zdfapi.ZDFTeaserImage) string {
@@ -126,78 +272,160 @@ func getDimensions(resolution string) (width, height int) {
 	return
 }
 
-func findBestMatchingVideoStreamURL(api *zdfapi.ZDFApi, streams *zdfapi.VideoStreams) string {
-	const adaptive = false
-	const mimeType = wantedMimeType
-	const lang = "deu"
-	const class = "main"
+func findBestMatchingStream(api *zdfapi.ZDFApi, variantProber *VariantProber, showPath string, streams *zdfapi.VideoStreams, parameters internal.RequestParameters, services *internal.MediaServices) (URL, mimeType string) {
+	fnRefineVideo := func(candidate streamselect.Candidate, constraints streamselect.Constraints) streamselect.Candidate {
+		return refineVideoCandidate(api, variantProber, showPath, candidate, constraints)
+	}
+	var candidates []streamselect.Candidate
+	for _, filter := range getTrackFilters(parameters) {
+		candidates = createCandidates(streams, filter)
+		if len(candidates) > 0 {
+			break
+		}
+	}
+	return streamselect.Resolve(candidates, parameters, services, fnRefineVideo)
+}
 
-	qualityToURL := map[string](string){}
+// trackFilter selects audio tracks by language and class. An empty language matches all languages.
+type trackFilter struct {
+	language string
+	class    string
+}
+
+func (filter trackFilter) matches(language, class string) bool {
+	return (filter.language == "" || filter.language == language) && filter.class == class
+}
+
+// getTrackFilters yields the filters for the audio tracks in the order of preference. If there is no track of the
+// requested variant in the requested language, the variant is used in any language. If there is no track of the
+// variant at all, the main tracks in the requested language, in German and in any language are used.
+// The original version is used in any language unless a language is requested.
+func getTrackFilters(parameters internal.RequestParameters) []trackFilter {
+	class, found := trackClassByVariant[parameters.Variant]
+	if !found {
+		class = mainClass
+	}
+	fallbackLanguage := parameters.Language
+	if fallbackLanguage == "" {
+		fallbackLanguage = defaultLanguage
+	}
+	language := fallbackLanguage
+	if parameters.Language == "" && parameters.Variant == internal.VariantOriginalVersion {
+		language = ""
+	}
+	filters := []trackFilter{{language, class}}
+	if class != mainClass {
+		filters = append(filters, trackFilter{"", class})
+	}
+	return append(filters,
+		trackFilter{fallbackLanguage, mainClass},
+		trackFilter{defaultLanguage, mainClass},
+		trackFilter{"", mainClass},
+	)
+}
+
+type qualityDimensions struct {
+	rank   int
+	width  int
+	height int
+}
+
+// rank and approximate video dimensions of the qualities of the ZDF API
+var dimensionsByQuality = map[string](qualityDimensions){
+	"low":      {1, 480, 270},
+	"med":      {2, 640, 360},
+	"high":     {3, 768, 432},
+	"veryhigh": {4, 1024, 576},
+	"hd":       {5, 1280, 720},
+	"fhd":      {6, 1920, 1080},
+	"uhd":      {7, 3840, 2160},
+}
+
+var bitrateRegex = regexp.MustCompile("_([0-9]+)k_p[0-9]+v[0-9]+\\.")
+
+// createCandidates collects the streams whose audio track matches the filter. If there are multiple streams with
+// the same quality and MIME type, the last one wins.
+func createCandidates(streams *zdfapi.VideoStreams, filter trackFilter) []streamselect.Candidate {
+	candidates := make([]streamselect.Candidate, 0)
+	candidateIndex := map[string](int){}
 	for _, stream := range streams.Streams {
 		for _, format := range stream.Formats {
-			if adaptive != format.IsAdaptive || mimeType != format.MimeType {
-				continue
-			}
 			for _, quality := range format.Qualities {
-				qualityString := quality.Quality
 				for _, track := range quality.Audio.Tracks {
-					if lang != track.Language || class != track.Class {
+					if !filter.matches(track.Language, track.Class) {
 						continue
 					}
-					qualityToURL[qualityString] = track.URL
+					candidate := createCandidate(format.MimeType, format.IsAdaptive, quality.Quality, quality.MimeCodec, track.URL)
+					key := fmt.Sprintf("%v#%v#%v", format.MimeType, format.IsAdaptive, quality.Quality)
+					if index, ok := candidateIndex[key]; ok {
+						candidates[index] = candidate
+					} else {
+						candidateIndex[key] = len(candidates)
+						candidates = append(candidates, candidate)
+					}
 				}
 			}
 		}
 	}
+	return candidates
+}
 
-	url, ok := qualityToURL["veryhigh"]
-	if ok {
-		return findHighestResolutionStream(api, url)
+func createCandidate(mimeType string, isAdaptive bool, quality, mimeCodec, URL string) streamselect.Candidate {
+	dimensions := dimensionsByQuality[quality]
+	candidate := streamselect.Candidate{
+		URL:        URL,
+		MimeType:   mimeType,
+		Codec:      streamselect.GetCodec(mimeCodec),
+		IsAdaptive: isAdaptive,
+		Rank:       dimensions.rank,
 	}
-
-	url, ok = qualityToURL["high"]
-	if ok {
-		return url
+	if !internal.IsAudioMimeType(mimeType) {
+		candidate.Width = dimensions.width
+		candidate.Height = dimensions.height
 	}
-
-	url, ok = qualityToURL["low"]
-	if ok {
-		return url
+	switch mimeType {
+	case "video/mp4":
+		candidate.Container = "mp4"
+	case "video/webm":
+		candidate.Container = "webm"
+	case manifest.HLSMimeType:
+		candidate.Container = "m3u8"
+	case manifest.DASHMimeType:
+		candidate.Container = "mpd"
 	}
-
-	for _, value := range qualityToURL {
-		return value
+	if matches := bitrateRegex.FindStringSubmatch(URL); matches != nil {
+		candidate.Bitrate, _ = strconv.Atoi(matches[1])
 	}
-
-	return ""
+	return candidate
 }
 
-var urlSuffixes = []string{
-	"3256k_p15v12.mp4",
-	"3296k_p15v13.mp4",
-	"3328k_p36v12.mp4",
-	"3328k_p36v13.mp4",
-	"3328k_p36v14.mp4",
-	"3328k_p35v14.mp4",
-	"3360k_p36v15.mp4",
-}
-
-func findHighestResolutionStream(api *zdfapi.ZDFApi, URL string) string {
-	byteURL := []byte(URL)
-	regex := regexp.MustCompile("_[0-9]+k_p[0-9]+v[0-9]+.mp4$")
-	if !regex.Match(byteURL) {
-		return URL
+// refineVideoCandidate tries to find a stream with a higher resolution than the best stream the ZDF API offers
+// if the constraints ask for a larger stream. The refined stream has the dimensions of its step of the suffix ladder.
+func refineVideoCandidate(api *zdfapi.ZDFApi, variantProber *VariantProber, showPath string, candidate streamselect.Candidate, constraints streamselect.Constraints) streamselect.Candidate {
+	if constraints.Prefer == streamselect.PreferSmallest {
+		return candidate
+	}
+	if constraints.Prefer == streamselect.PreferClosestWidth && constraints.Width <= candidate.Width {
+		return candidate
 	}
-	suffix := string(regex.Find(byteURL))
 
-	urlPrefix := strings.TrimSuffix(URL, suffix) + "_"
-	for j := len(urlSuffixes) - 1; j >= 0; j-- {
-		tryURL := urlPrefix + urlSuffixes[j]
-		_, err := api.Get(tryURL, true)
-		if err == nil {
-			return tryURL
-		}
+	if variantProber == nil {
+		return candidate
 	}
 
-	return URL
+	limits := VariantLimits{
+		MinHeight:  candidate.Height,
+		MaxHeight:  constraints.MaxHeight,
+		MaxBitrate: constraints.MaxBitrate,
+	}
+	URL, width, height := variantProber.FindHighestResolutionStream(api, showPath, candidate.URL, limits)
+	if URL == candidate.URL {
+		return candidate
+	}
+	refined := createCandidate(candidate.MimeType, candidate.IsAdaptive, "", "", URL)
+	refined.Width = width
+	refined.Height = height
+	refined.Rank = candidate.Rank
+	refined.Codec = candidate.Codec
+	return refined
 }
@@ -1,21 +1,31 @@
 package zdffeed
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"regexp"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/seiferma/docker_mediathek2rss/internal"
+	"github.com/seiferma/docker_mediathek2rss/internal/provider"
+	"github.com/seiferma/docker_mediathek2rss/internal/rssfeed"
+	"github.com/seiferma/docker_mediathek2rss/internal/streamselect"
 	"github.com/seiferma/docker_mediathek2rss/internal/zdfapi"
 )
 
 var defaultParameters internal.RequestParameters = internal.RequestParameters{
-	Width: 1080,
+	Width:        1080,
+	MaxAgeRating: 18,
 }
 
+// testNow lies within the availability of the episodes in the test data
+var testNow = time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
 func TestCreateRssFeedValid(t *testing.T) {
 	urlToFilename := map[string](string){}
 	urlToFilename["https://www.zdf.de/nachrichten/heute-journal"] = "zdf-heute-journal.html"
@@ -24,10 +34,13 @@ func TestCreateRssFeedValid(t *testing.T) {
 	urlToFilename["https://api.zdf.de/tmd/2/ngplayer_2_4/vod/ptmd/mediathek/201218_2330_sendung_zmr"] = "zdf-magazin-royale-stream.json"
 	urlToFilename["https://api.zdf.de/tmd/2/ngplayer_2_4/vod/ptmd/mediathek/201211_2300_sendung_zmr"] = "zdf-magazin-royale-stream2.json"
 
-	result, err := createRssFeedMocked("comedy/zdf-magazin-royale", 2, defaultParameters, urlToFilename, CreateZdfRssFeed)
+	result, err := createRssFeedMocked("comedy/zdf-magazin-royale", 2, defaultParameters, urlToFilename)
 	if err != nil {
 		t.Fatalf("There should not be an error.\n%v", err)
 	}
+	if report := rssfeed.Validate([]byte(result)); !report.IsValid() {
+		t.Fatalf("The created feed is not valid.\n%v", report)
+	}
 	expectedBytes, _ := ioutil.ReadFile("../testdata/zdf-magazin-royale.xml")
 	expected := string(expectedBytes)
 
@@ -52,7 +65,7 @@ func TestCreateRssFeedValidWithLengthFilter(t *testing.T) {
 	parameters := defaultParameters
 	parameters.MinimumLengthInSeconds = 31 * 60
 
-	result, err := createRssFeedMocked("comedy/zdf-magazin-royale", 2, parameters, urlToFilename, CreateZdfRssFeed)
+	result, err := createRssFeedMocked("comedy/zdf-magazin-royale", 2, parameters, urlToFilename)
 	if err != nil {
 		t.Fatalf("There should not be an error.\n%v", err)
 	}
@@ -69,7 +82,116 @@ func TestCreateRssFeedValidWithLengthFilter(t *testing.T) {
 	}
 }
 
-func createRssFeedMocked(showID string, maxEpisodes int, parameters internal.RequestParameters, urlToFilename map[string](string), fnCreate func(showID string, parameters internal.RequestParameters, zdfAPI *zdfapi.ZDFApi) (result string, err error)) (result string, err error) {
+func TestCreateRssFeedWithMissingStreams(t *testing.T) {
+	urlToFilename := map[string](string){}
+	urlToFilename["https://www.zdf.de/nachrichten/heute-journal"] = "zdf-heute-journal.html"
+	urlToFilename["https://api.zdf.de/content/documents/zdf/comedy/zdf-magazin-royale"] = "zdf-magazin-royale.json"
+	urlToFilename["https://api.zdf.de/search/documents/zdf/comedy/zdf-magazin-royale?q=*&limit=2&types=page-video&hasVideo=true"] = "zdf-magazin-royale-search.json"
+	urlToFilename["https://api.zdf.de/tmd/2/ngplayer_2_4/vod/ptmd/mediathek/201218_2330_sendung_zmr"] = "zdf-magazin-royale-stream.json"
+
+	parameters := defaultParameters
+	parameters.OnMissingMedia = internal.MissingMediaSkip
+	result, err := createRssFeedMocked("comedy/zdf-magazin-royale", 2, parameters, urlToFilename)
+	if err != nil {
+		t.Fatalf("There should not be an error.\n%v", err)
+	}
+	assertEquals(t, 1, strings.Count(result, "<item>"))
+
+	parameters.OnMissingMedia = internal.MissingMediaLink
+	result, _ = createRssFeedMocked("comedy/zdf-magazin-royale", 2, parameters, urlToFilename)
+	assertEquals(t, 2, strings.Count(result, "<item>"))
+	assertEquals(t, 1, strings.Count(result, "<enclosure"))
+}
+
+func TestCreateRssFeedConsidersAvailability(t *testing.T) {
+	urlToFilename := map[string](string){}
+	urlToFilename["https://www.zdf.de/nachrichten/heute-journal"] = "zdf-heute-journal.html"
+	urlToFilename["https://api.zdf.de/content/documents/zdf/comedy/zdf-magazin-royale"] = "zdf-magazin-royale.json"
+	urlToFilename["https://api.zdf.de/search/documents/zdf/comedy/zdf-magazin-royale?q=*&limit=2&types=page-video&hasVideo=true"] = "zdf-magazin-royale-search.json"
+	urlToFilename["https://api.zdf.de/tmd/2/ngplayer_2_4/vod/ptmd/mediathek/201218_2330_sendung_zmr"] = "zdf-magazin-royale-stream.json"
+	urlToFilename["https://api.zdf.de/tmd/2/ngplayer_2_4/vod/ptmd/mediathek/201211_2300_sendung_zmr"] = "zdf-magazin-royale-stream2.json"
+
+	parameters := defaultParameters
+	parameters.AnnotateExpiry = true
+	result, _ := createRssFeedMocked("comedy/zdf-magazin-royale", 2, parameters, urlToFilename)
+	assertEquals(t, 2, strings.Count(result, "<item>"))
+	assertEquals(t, true, strings.Contains(result, "Verfügbar bis 18.03.2021, 23:59 Uhr"))
+
+	now := time.Date(2021, 3, 15, 0, 0, 0, 0, time.UTC)
+	result, _ = createRssFeedMockedAt(now, "comedy/zdf-magazin-royale", 2, parameters, urlToFilename)
+	assertEquals(t, 1, strings.Count(result, "<item>"))
+
+	now = time.Date(2020, 12, 12, 0, 0, 0, 0, time.UTC)
+	result, _ = createRssFeedMockedAt(now, "comedy/zdf-magazin-royale", 2, parameters, urlToFilename)
+	assertEquals(t, 1, strings.Count(result, "<item>"))
+}
+
+func TestCreateRssFeedConsidersRestrictions(t *testing.T) {
+	urlToFilename := map[string](string){}
+	urlToFilename["https://www.zdf.de/nachrichten/heute-journal"] = "zdf-heute-journal.html"
+	urlToFilename["https://api.zdf.de/content/documents/zdf/comedy/zdf-magazin-royale"] = "zdf-magazin-royale.json"
+	urlToFilename["https://api.zdf.de/search/documents/zdf/comedy/zdf-magazin-royale?q=*&limit=2&types=page-video&hasVideo=true"] = "zdf-magazin-royale-search.json"
+	urlToFilename["https://api.zdf.de/tmd/2/ngplayer_2_4/vod/ptmd/mediathek/201218_2330_sendung_zmr"] = "zdf-magazin-royale-stream.json"
+	urlToFilename["https://api.zdf.de/tmd/2/ngplayer_2_4/vod/ptmd/mediathek/201211_2300_sendung_zmr"] = "zdf-magazin-royale-stream-fsk16.json"
+
+	result, _ := createRssFeedMocked("comedy/zdf-magazin-royale", 2, defaultParameters, urlToFilename)
+	assertEquals(t, 2, strings.Count(result, "<item>"))
+	assertEquals(t, true, strings.Contains(result, "[FSK 16, Nur in Deutschland, Österreich und der Schweiz verfügbar]"))
+
+	// the programme items of both episodes are rated FSK 6
+	parameters := defaultParameters
+	parameters.MaxAgeRating = 0
+	result, _ = createRssFeedMocked("comedy/zdf-magazin-royale", 2, parameters, urlToFilename)
+	assertEquals(t, 0, strings.Count(result, "<item>"))
+
+	parameters.MaxAgeRating = 12
+	result, _ = createRssFeedMocked("comedy/zdf-magazin-royale", 2, parameters, urlToFilename)
+	assertEquals(t, 1, strings.Count(result, "<item>"))
+	assertEquals(t, false, strings.Contains(result, "FSK 16"))
+
+	parameters = defaultParameters
+	parameters.ExcludedGeoRegions = []string{"dach"}
+	result, _ = createRssFeedMocked("comedy/zdf-magazin-royale", 2, parameters, urlToFilename)
+	assertEquals(t, 1, strings.Count(result, "<item>"))
+}
+
+func TestCreateRssFeedConsidersEpisodeFilters(t *testing.T) {
+	urlToFilename := map[string](string){}
+	urlToFilename["https://www.zdf.de/nachrichten/heute-journal"] = "zdf-heute-journal.html"
+	urlToFilename["https://api.zdf.de/content/documents/zdf/comedy/zdf-magazin-royale"] = "zdf-magazin-royale.json"
+	urlToFilename["https://api.zdf.de/search/documents/zdf/comedy/zdf-magazin-royale?q=*&limit=2&types=page-video&hasVideo=true"] = "zdf-magazin-royale-search.json"
+	urlToFilename["https://api.zdf.de/tmd/2/ngplayer_2_4/vod/ptmd/mediathek/201218_2330_sendung_zmr"] = "zdf-magazin-royale-stream.json"
+	urlToFilename["https://api.zdf.de/tmd/2/ngplayer_2_4/vod/ptmd/mediathek/201211_2300_sendung_zmr"] = "zdf-magazin-royale-stream2.json"
+
+	parameters := defaultParameters
+	parameters.Include = regexp.MustCompile("^Corona")
+	result, _ := createRssFeedMocked("comedy/zdf-magazin-royale", 2, parameters, urlToFilename)
+	assertEquals(t, 1, strings.Count(result, "<item>"))
+	assertEquals(t, true, strings.Contains(result, "Corona-Unternehmer"))
+
+	parameters = defaultParameters
+	parameters.Exclude = regexp.MustCompile("^Corona")
+	result, _ = createRssFeedMocked("comedy/zdf-magazin-royale", 2, parameters, urlToFilename)
+	assertEquals(t, 1, strings.Count(result, "<item>"))
+	assertEquals(t, false, strings.Contains(result, "Corona-Unternehmer"))
+
+	parameters = defaultParameters
+	parameters.Season = 2021
+	result, _ = createRssFeedMocked("comedy/zdf-magazin-royale", 2, parameters, urlToFilename)
+	assertEquals(t, 0, strings.Count(result, "<item>"))
+
+	parameters = defaultParameters
+	parameters.Limit = 1
+	result, _ = createRssFeedMocked("comedy/zdf-magazin-royale", 2, parameters, urlToFilename)
+	assertEquals(t, 1, strings.Count(result, "<item>"))
+}
+
+func createRssFeedMocked(showID string, maxEpisodes int, parameters internal.RequestParameters, urlToFilename map[string](string)) (result string, err error) {
+	return createRssFeedMockedAt(testNow, showID, maxEpisodes, parameters, urlToFilename)
+}
+
+// createRssFeedMockedAt creates the feed as if it was built at the given time.
+func createRssFeedMockedAt(now time.Time, showID string, maxEpisodes int, parameters internal.RequestParameters, urlToFilename map[string](string)) (result string, err error) {
 	fnGetHTTP := func(api *zdfapi.ZDFApi, URL string, onlyPeek bool) (result []byte, err error) {
 		filename, ok := urlToFilename[URL]
 		if !ok {
@@ -79,14 +201,39 @@ func createRssFeedMocked(showID string, maxEpisodes int, parameters internal.Req
 		result, err = ioutil.ReadFile("../testdata/" + filename)
 		return
 	}
-	zdfAPI, err := zdfapi.CreateZDFApiWithFnGet(maxEpisodes, fnGetHTTP)
-	if err != nil {
-		return
+	zdfProvider := &Provider{
+		platform: zdfapi.PlatformZDF,
+		fnCreateAPI: func() (zdfapi.ZDFApi, error) {
+			return zdfapi.CreateZDFApiWithFnGet(maxEpisodes, fnGetHTTP)
+		},
+		variantProber: CreateVariantProber(DefaultURLSuffixes, time.Hour),
 	}
-	result, err = fnCreate(showID, parameters, &zdfAPI)
+	result, err = provider.CreateFeed(zdfProvider, "byPath", showID, "", parameters, &internal.MediaServices{}, now)
 	return
 }
 
+func TestIsValidShowID(t *testing.T) {
+	zdfProvider := CreateProvider(1, nil)
+	assertEquals(t, true, zdfProvider.IsValidShowID("byPath", "comedy/zdf-magazin-royale"))
+	assertEquals(t, true, zdfProvider.IsValidShowID("byPath", "heute-journal"))
+	assertEquals(t, false, zdfProvider.IsValidShowID("byPath", ""))
+	assertEquals(t, false, zdfProvider.IsValidShowID("byPath", "comedy/"))
+	assertEquals(t, false, zdfProvider.IsValidShowID("byPath", "../comedy"))
+}
+
+func TestGetITunesCategory(t *testing.T) {
+	assertEquals(t, "Comedy", getITunesCategory("zdf", "comedy/zdf-magazin-royale"))
+	assertEquals(t, "News", getITunesCategory("zdf", "nachrichten/heute-journal"))
+	assertEquals(t, "TV & Film", getITunesCategory("zdf", "serien/soko-leipzig"))
+	assertEquals(t, "Kids & Family", getITunesCategory("kika", "serien/die-sendung-mit-dem-elefanten"))
+}
+
+func TestCreatePlatformProvider(t *testing.T) {
+	zdfProvider := CreatePlatformProvider(zdfapi.Platform3sat, 10, nil)
+	assertEquals(t, "3sat", zdfProvider.ID())
+	assertEquals(t, "zdf", CreateProvider(10, nil).ID())
+}
+
 func TestFindBestMatchingImageURL(t *testing.T) {
 	assertFindBestMatchingImageURL(t, "2", map[string]string{
 		"10x10": "1",
@@ -115,10 +262,146 @@ func TestFindHighestResolutionStream(t *testing.T) {
 		return []byte{}, errors.New("test error")
 	}
 	api, _ := zdfapi.CreateZDFApiWithFnGet(1, fnGet)
-	actual := findHighestResolutionStream(&api, testURL)
+	prober := CreateVariantProber(DefaultURLSuffixes, time.Hour)
+	actual, _, _ := prober.FindHighestResolutionStream(&api, "show", testURL, VariantLimits{})
 	assertEquals(t, expectedURL, actual)
 }
 
+func TestRefineVideoCandidateConsidersMaxHeight(t *testing.T) {
+	const prefix = "https://bla/sendung_zmr_"
+	var mutex sync.Mutex
+	requests := 0
+	fnGet := func(api *zdfapi.ZDFApi, URL string, onlyPeek bool) (result []byte, err error) {
+		mutex.Lock()
+		requests++
+		mutex.Unlock()
+		if URL == prefix+"3360k_p36v15.mp4" {
+			return []byte{}, nil
+		}
+		return []byte{}, errors.New("test error")
+	}
+	api, _ := zdfapi.CreateZDFApiWithFnGet(1, fnGet)
+	prober := CreateVariantProber(DefaultURLSuffixes, time.Hour)
+	candidate := createCandidate("video/mp4", false, "veryhigh", "", prefix+"1628k_p13v15.mp4")
+
+	requests = 0
+	refined := refineVideoCandidate(&api, prober, "show", candidate, streamselect.Constraints{Width: 1920, MaxHeight: 700})
+	assertEquals(t, candidate.URL, refined.URL)
+	assertEquals(t, 0, requests)
+
+	refined = refineVideoCandidate(&api, prober, "show", candidate, streamselect.Constraints{Width: 1920, MaxHeight: 800})
+	assertEquals(t, prefix+"3360k_p36v15.mp4", refined.URL)
+	assertEquals(t, 1280, refined.Width)
+	assertEquals(t, 720, refined.Height)
+	assertEquals(t, 3360, refined.Bitrate)
+}
+
+func TestFindBestMatchingStreamAudioOnlyFallsBackToLowestVideo(t *testing.T) {
+	streams := readStreams(t, "../testdata/zdf-magazin-royale-stream.json")
+	parameters := defaultParameters
+	parameters.AudioOnly = true
+
+	actualURL, actualMimeType := findBestMatchingStream(nil, nil, "", &streams, parameters, &internal.MediaServices{})
+	assertEquals(t, "https://nrodlzdf-a.akamaihd.net/none/zdf/20/12/201218_2330_sendung_zmr/3/201218_2330_sendung_zmr_508k_p9v15.mp4", actualURL)
+	assertEquals(t, "video/mp4", actualMimeType)
+}
+
+func TestFindBestMatchingStreamConsidersWidth(t *testing.T) {
+	streams := readStreams(t, "../testdata/zdf-magazin-royale-stream.json")
+	parameters := defaultParameters
+	parameters.Width = 700
+
+	actualURL, actualMimeType := findBestMatchingStream(nil, nil, "", &streams, parameters, &internal.MediaServices{})
+	assertEquals(t, "https://rodlzdf-a.akamaihd.net/none/zdf/20/12/201218_2330_sendung_zmr/3/201218_2330_sendung_zmr_808k_p11v15.mp4", actualURL)
+	assertEquals(t, "video/mp4", actualMimeType)
+}
+
+func TestFindBestMatchingStreamConsidersMaxHeight(t *testing.T) {
+	streams := readStreams(t, "../testdata/zdf-magazin-royale-stream.json")
+	parameters := defaultParameters
+	parameters.MaxHeight = 400
+
+	actualURL, _ := findBestMatchingStream(nil, nil, "", &streams, parameters, &internal.MediaServices{})
+	assertEquals(t, "https://nrodlzdf-a.akamaihd.net/none/zdf/20/12/201218_2330_sendung_zmr/3/201218_2330_sendung_zmr_508k_p9v15.mp4", actualURL)
+}
+
+func TestFindBestMatchingStreamAudioOnly(t *testing.T) {
+	var streams zdfapi.VideoStreams
+	err := json.Unmarshal([]byte(`{"priorityList":[{"formitaeten":[
+		{"isAdaptive":false,"mimeType":"video/mp4","qualities":[{"quality":"low","audio":{"tracks":[{"class":"main","language":"deu","uri":"https://foo/video.mp4"}]}}]},
+		{"isAdaptive":false,"mimeType":"audio/mp4","qualities":[
+			{"quality":"low","audio":{"tracks":[{"class":"main","language":"deu","uri":"https://foo/audio_low.m4a"}]}},
+			{"quality":"high","audio":{"tracks":[{"class":"main","language":"deu","uri":"https://foo/audio_high.m4a"}]}}
+		]}
+	]}]}`), &streams)
+	if err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	parameters := defaultParameters
+	parameters.AudioOnly = true
+
+	actualURL, actualMimeType := findBestMatchingStream(nil, nil, "", &streams, parameters, &internal.MediaServices{})
+	assertEquals(t, "https://foo/audio_high.m4a", actualURL)
+	assertEquals(t, "audio/mp4", actualMimeType)
+}
+
+const variantStreams = `{"priorityList":[{"formitaeten":[
+	{"isAdaptive":false,"mimeType":"video/mp4","qualities":[{"quality":"hd","audio":{"tracks":[
+		{"class":"main","language":"deu","uri":"https://foo/main_deu.mp4"},
+		{"class":"ad","language":"deu","uri":"https://foo/ad_deu.mp4"},
+		{"class":"ot","language":"eng","uri":"https://foo/ot_eng.mp4"},
+		{"class":"main","language":"fra","uri":"https://foo/main_fra.mp4"}
+	]}}]}
+]}]}`
+
+func TestFindBestMatchingStreamConsidersVariantAndLanguage(t *testing.T) {
+	var streams zdfapi.VideoStreams
+	err := json.Unmarshal([]byte(variantStreams), &streams)
+	if err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	assertVariantAndLanguage(t, &streams, internal.VariantMain, "", "https://foo/main_deu.mp4")
+	assertVariantAndLanguage(t, &streams, internal.VariantAudioDescription, "", "https://foo/ad_deu.mp4")
+	assertVariantAndLanguage(t, &streams, internal.VariantOriginalVersion, "", "https://foo/ot_eng.mp4")
+	assertVariantAndLanguage(t, &streams, internal.VariantMain, "fra", "https://foo/main_fra.mp4")
+}
+
+func TestFindBestMatchingStreamFallsBackToMainTracks(t *testing.T) {
+	var streams zdfapi.VideoStreams
+	err := json.Unmarshal([]byte(variantStreams), &streams)
+	if err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	assertVariantAndLanguage(t, &streams, internal.VariantAudioDescription, "eng", "https://foo/ad_deu.mp4")
+	assertVariantAndLanguage(t, &streams, internal.VariantOriginalVersion, "fra", "https://foo/ot_eng.mp4")
+	assertVariantAndLanguage(t, &streams, internal.VariantSignLanguage, "", "https://foo/main_deu.mp4")
+	assertVariantAndLanguage(t, &streams, internal.VariantMain, "ita", "https://foo/main_deu.mp4")
+
+	realStreams := readStreams(t, "../testdata/zdf-magazin-royale-stream.json")
+	assertVariantAndLanguage(t, &realStreams, internal.VariantAudioDescription, "", "https://rodlzdf-a.akamaihd.net/none/zdf/20/12/201218_2330_sendung_zmr/3/201218_2330_sendung_zmr_808k_p11v15.mp4")
+}
+
+func assertVariantAndLanguage(t *testing.T, streams *zdfapi.VideoStreams, variant, language, expectedURL string) {
+	parameters := defaultParameters
+	parameters.Width = 700
+	parameters.Variant = variant
+	parameters.Language = language
+	actualURL, _ := findBestMatchingStream(nil, nil, "", streams, parameters, &internal.MediaServices{})
+	assertEquals(t, expectedURL, actualURL)
+}
+
+func readStreams(t *testing.T, filename string) (streams zdfapi.VideoStreams) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	err = json.Unmarshal(content, &streams)
+	if err != nil {
+		t.Fatalf("We did not expect an error.\n%v", err)
+	}
+	return
+}
+
 func assertFindBestMatchingImageURL(t *testing.T, expected string, images map[string]string) {
 	image := &zdfapi.ZDFTeaserImage{
 		Images: images,
@@ -127,7 +410,7 @@ func assertFindBestMatchingImageURL(t *testing.T, expected string, images map[st
 	assertEquals(t, expected, actual)
 }
 
-func assertEquals(t *testing.T, expected, actual string) {
+func assertEquals(t *testing.T, expected, actual interface{}) {
 	if expected != actual {
 		t.Fatalf("Expected %v but got %v.", expected, actual)
 	}